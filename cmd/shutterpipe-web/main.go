@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/On-Jun9/ShutterPipe/internal/web"
 )
@@ -11,12 +13,63 @@ var (
 	version = "dev" // set by ldflags during build
 )
 
+// pathList collects a flag that may be given several times.
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pathList) Set(v string) error {
+	*p = append(*p, v)
+	return nil
+}
+
 func main() {
 	addr := flag.String("addr", "localhost:8080", "HTTP server address")
+	password := flag.String("password", os.Getenv("SHUTTERPIPE_PASSWORD"), "Admin login password (env SHUTTERPIPE_PASSWORD)")
+	viewerPassword := flag.String("viewer-password", os.Getenv("SHUTTERPIPE_VIEWER_PASSWORD"), "Read-only login password (env SHUTTERPIPE_VIEWER_PASSWORD)")
+	token := flag.String("token", os.Getenv("SHUTTERPIPE_TOKEN"), "API bearer token with admin access (env SHUTTERPIPE_TOKEN)")
+	sessionTTL := flag.Duration("session-ttl", web.DefaultSessionTTL, "Idle time before a login session expires")
+	var sourceRoots, destRoots pathList
+	flag.Var(&sourceRoots, "source-root", "Allowed source root, e.g. a card mount point (repeatable)")
+	flag.Var(&destRoots, "dest-root", "Allowed destination root, e.g. a NAS share (repeatable)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file (PEM) to serve HTTPS")
+	tlsKey := flag.String("tls-key", "", "TLS private key file (PEM)")
+	tlsSelfSigned := flag.Bool("tls-self-signed", false, "Serve HTTPS with a self-signed certificate kept in ~/.shutterpipe/tls")
+	var tlsHosts pathList
+	flag.Var(&tlsHosts, "tls-host", "Extra host name or IP address for the self-signed certificate (repeatable)")
+	httpRedirect := flag.String("http-redirect", "", "Also listen on this address and redirect HTTP to HTTPS, e.g. :80")
+	jobsFile := flag.String("jobs-file", web.DefaultJobsFile(), "File the job queue is kept in")
+	staticDir := flag.String("static-dir", "", "Serve the UI from this directory instead of the embedded files (for UI development)")
 	flag.Parse()
 
 	server := web.NewServer()
 	server.SetVersion(version)
+	server.SetAuth(web.AuthConfig{
+		Password:       *password,
+		ViewerPassword: *viewerPassword,
+		Token:          *token,
+		SessionTTL:     *sessionTTL,
+	})
+	if err := server.EnableJobs(*jobsFile); err != nil {
+		log.Fatal(err)
+	}
+	if err := server.SetStaticDir(*staticDir); err != nil {
+		log.Fatal(err)
+	}
+	if err := server.SetRoots(web.PathRoots{Source: sourceRoots, Dest: destRoots}); err != nil {
+		log.Fatal(err)
+	}
+	if err := server.SetTLS(web.TLSConfig{
+		CertFile:     *tlsCert,
+		KeyFile:      *tlsKey,
+		SelfSigned:   *tlsSelfSigned,
+		Hosts:        tlsHosts,
+		RedirectAddr: *httpRedirect,
+	}); err != nil {
+		log.Fatal(err)
+	}
 
 	if err := server.Start(*addr); err != nil {
 		log.Fatal(err)
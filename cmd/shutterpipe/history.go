@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/report"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyDir    string
+	historyLimit  int
+	historyAction string
+	historyError  string
+	reportFormat  string
+	reportOutput  string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past runs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runs, err := history.NewStore(historyDir).List()
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			fmt.Println("No runs recorded")
+			return nil
+		}
+
+		if historyLimit > 0 && len(runs) > historyLimit {
+			runs = runs[:historyLimit]
+		}
+		for _, r := range runs {
+			dry := ""
+			if r.DryRun {
+				dry = " (dry-run)"
+			}
+			fmt.Printf("%s  %s  %s -> %s%s\n", r.ID, r.Summary.StartTime.Format("2006-01-02 15:04"), r.Source, r.Dest, dry)
+			fmt.Printf("    copied %d, skipped %d, renamed %d, failed %d, %s\n",
+				r.Summary.Copied, r.Summary.Skipped, r.Summary.Renamed, r.Summary.Failed, r.Summary.Duration.Round(time.Second))
+		}
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a run and its file results",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rec, err := history.NewStore(historyDir).Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		s := rec.Summary
+		fmt.Printf("Run:        %s\n", rec.ID)
+		fmt.Printf("Started:    %s\n", s.StartTime.Format(time.RFC3339))
+		fmt.Printf("Source:     %s\n", rec.Source)
+		fmt.Printf("Dest:       %s\n", rec.Dest)
+		fmt.Printf("Policy:     dedup=%s conflict=%s organize=%s\n", rec.Config.DedupMethod, rec.Config.ConflictPolicy, rec.Config.OrganizeStrategy)
+		fmt.Printf("Dry run:    %v\n", rec.DryRun)
+		fmt.Printf("Result:     %d copied, %d skipped, %d renamed, %d overwritten, %d quarantined, %d failed\n",
+			s.Copied, s.Skipped, s.Renamed, s.Overwritten, s.Quarantined, s.Failed)
+		fmt.Printf("Duration:   %s\n", s.Duration.Round(time.Second))
+
+		filter := history.Filter{Action: types.CopyAction(historyAction), Error: historyError}
+		files := filter.Apply(rec.Files)
+		fmt.Printf("\nFiles (%d of %d):\n", len(files), len(rec.Files))
+		for _, f := range files {
+			fmt.Printf("[%s] %s -> %s\n", f.Action, f.Source, f.Dest)
+			if f.MetadataSource != "" {
+				fmt.Printf("    metadata: %s\n", f.MetadataSource)
+			}
+			if f.Error != "" {
+				fmt.Printf("    error:    %s\n", f.Error)
+			}
+		}
+		return nil
+	},
+}
+
+var historyReportCmd = &cobra.Command{
+	Use:   "report <id>",
+	Short: "Export the ingest report of a run",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rec, err := history.NewStore(historyDir).Get(args[0])
+		if err != nil {
+			return err
+		}
+		r := report.Build(rec)
+
+		if reportOutput == "" || reportOutput == "-" {
+			return r.Write(os.Stdout, reportFormat)
+		}
+
+		f, err := os.Create(reportOutput)
+		if err != nil {
+			return err
+		}
+		if err := r.Write(f, reportFormat); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyShowCmd, historyReportCmd)
+
+	historyCmd.PersistentFlags().StringVar(&historyDir, "dir", history.DefaultDir(), "run history directory")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "number of runs to list (0=all)")
+	historyShowCmd.Flags().StringVar(&historyAction, "action", "", "only show files with this action (copied, skipped, failed, ...)")
+	historyShowCmd.Flags().StringVar(&historyError, "error", "", "only show files whose error contains this text ('*' for any error)")
+	historyReportCmd.Flags().StringVarP(&reportFormat, "format", "f", report.FormatHTML, "report format: html, csv, json")
+	historyReportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "output file (default stdout)")
+}
@@ -16,16 +16,35 @@ var (
 	source         string
 	dest           string
 	includeExt     []string
+	includeGlobs   []string
+	excludeGlobs   []string
+	minSize        int64
+	maxSize        int64
+	cardMode       bool
 	jobs           int
 	dedupMethod    string
 	conflictPolicy string
 	unclassified   string
 	quarantine     string
+	reviewDir      string
+	reportFormats  []string
+	reportDir      string
+	perceptualDist int
 	stateFile      string
+	runHistoryDir  string
 	logFile        string
 	logJSON        bool
 	dryRun         bool
 	hashVerify     bool
+	undoRetention  int
+	skipPreflight  bool
+	spaceMargin    int64
+	bwLimit        int64
+	bwPerWorker    int64
+	fsyncPolicy    string
+	preserveAttrs  []string
+	bufferSize     int
+	retryAttempts  int
 )
 
 func main() {
@@ -64,16 +83,35 @@ func init() {
 	runCmd.Flags().StringVarP(&source, "source", "s", "", "source directory (SD card)")
 	runCmd.Flags().StringVarP(&dest, "dest", "d", "", "destination directory (NAS)")
 	runCmd.Flags().StringSliceVarP(&includeExt, "include-ext", "e", nil, "file extensions to include")
+	runCmd.Flags().StringSliceVar(&includeGlobs, "include", nil, "only include files matching these globs (e.g. 'DCIM/**/*.ARW')")
+	runCmd.Flags().StringSliceVar(&excludeGlobs, "exclude", nil, "skip files and directories matching these globs")
+	runCmd.Flags().Int64Var(&minSize, "min-size", 0, "skip files smaller than this many bytes")
+	runCmd.Flags().Int64Var(&maxSize, "max-size", 0, "skip files larger than this many bytes")
+	runCmd.Flags().BoolVar(&cardMode, "card-mode", false, "only scan DCIM and known camera video folders")
 	runCmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "number of concurrent workers (0=auto)")
-	runCmd.Flags().StringVar(&dedupMethod, "dedup", "", "dedup method: name-size, hash")
-	runCmd.Flags().StringVar(&conflictPolicy, "conflict", "", "conflict policy: skip, rename, overwrite, quarantine")
+	runCmd.Flags().StringVar(&dedupMethod, "dedup", "", "dedup method: name-size, hash, perceptual")
+	runCmd.Flags().StringVar(&conflictPolicy, "conflict", "", "conflict policy: skip, rename, overwrite, quarantine, keep-newer, keep-larger, rename-hash, identical-skip-else-rename")
 	runCmd.Flags().StringVar(&unclassified, "unclassified-dir", "", "directory for files without capture date")
 	runCmd.Flags().StringVar(&quarantine, "quarantine-dir", "", "directory for conflicting files")
+	runCmd.Flags().StringVar(&reviewDir, "review-dir", "", "directory for near-duplicates flagged by perceptual dedup")
+	runCmd.Flags().StringSliceVar(&reportFormats, "report", nil, "report formats written to the destination: html, csv, json (default none)")
+	runCmd.Flags().StringVar(&reportDir, "report-dir", "", "directory for ingest reports inside the destination")
+	runCmd.Flags().IntVar(&perceptualDist, "perceptual-threshold", 0, "max Hamming distance for perceptual dedup, 0-64 (default 10)")
 	runCmd.Flags().StringVar(&stateFile, "state-file", "", "state file for resume")
+	runCmd.Flags().StringVar(&runHistoryDir, "history-dir", "", "run history and journal directory (default ~/.shutterpipe/runs)")
 	runCmd.Flags().StringVar(&logFile, "log-file", "", "log file path")
 	runCmd.Flags().BoolVar(&logJSON, "log-json", false, "output JSON logs")
 	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "simulate without copying")
 	runCmd.Flags().BoolVar(&hashVerify, "hash-verify", false, "verify copies with hash")
+	runCmd.Flags().IntVar(&undoRetention, "undo-retention-days", 0, "days to keep backups of overwritten files for undo (0=default 30)")
+	runCmd.Flags().Int64Var(&bwLimit, "bwlimit", 0, "limit total copy throughput in bytes per second (0=unlimited)")
+	runCmd.Flags().Int64Var(&bwPerWorker, "bwlimit-worker", 0, "limit each worker's copy throughput in bytes per second")
+	runCmd.Flags().StringVar(&fsyncPolicy, "fsync", "", "flush copied files to disk: always, batch, never (default batch)")
+	runCmd.Flags().StringSliceVar(&preserveAttrs, "preserve", nil, "attributes to keep besides mtime: mode, atime, xattrs")
+	runCmd.Flags().IntVar(&bufferSize, "buffer-size", 0, "buffer size in bytes for copies that can't use reflink or copy_file_range (0=1 MiB)")
+	runCmd.Flags().IntVar(&retryAttempts, "retries", 0, "attempts per file for transient read/write errors (0=default 3)")
+	runCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "skip destination space, permission and filename checks")
+	runCmd.Flags().Int64Var(&spaceMargin, "space-margin", -1, "bytes to keep free on each destination filesystem (-1=default 1 GiB)")
 }
 
 func runPipeline(cmd *cobra.Command, args []string) error {
@@ -98,6 +136,21 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	if len(includeExt) > 0 {
 		cfg.IncludeExtensions = includeExt
 	}
+	if len(includeGlobs) > 0 {
+		cfg.IncludePatterns = includeGlobs
+	}
+	if len(excludeGlobs) > 0 {
+		cfg.ExcludePatterns = excludeGlobs
+	}
+	if minSize > 0 {
+		cfg.MinFileSize = minSize
+	}
+	if maxSize > 0 {
+		cfg.MaxFileSize = maxSize
+	}
+	if cardMode {
+		cfg.CardMode = true
+	}
 	if jobs > 0 {
 		cfg.Jobs = jobs
 	}
@@ -113,9 +166,27 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	if quarantine != "" {
 		cfg.QuarantineDir = quarantine
 	}
+	if reviewDir != "" {
+		cfg.ReviewDir = reviewDir
+	}
+	if len(reportFormats) > 0 {
+		cfg.ReportFormats = reportFormats
+		if len(reportFormats) == 1 && reportFormats[0] == "none" {
+			cfg.ReportFormats = nil
+		}
+	}
+	if reportDir != "" {
+		cfg.ReportDir = reportDir
+	}
+	if cmd.Flags().Changed("perceptual-threshold") {
+		cfg.PerceptualThreshold = &perceptualDist
+	}
 	if stateFile != "" {
 		cfg.StateFile = stateFile
 	}
+	if runHistoryDir != "" {
+		cfg.HistoryDir = runHistoryDir
+	}
 	if logFile != "" {
 		cfg.LogFile = logFile
 	}
@@ -128,8 +199,35 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	if hashVerify {
 		cfg.HashVerify = true
 	}
+	if undoRetention > 0 {
+		cfg.UndoRetentionDays = undoRetention
+	}
+	if bwLimit > 0 {
+		cfg.Bandwidth.Limit = bwLimit
+	}
+	if bwPerWorker > 0 {
+		cfg.Bandwidth.PerWorker = bwPerWorker
+	}
+	if fsyncPolicy != "" {
+		cfg.Fsync = types.FsyncPolicy(fsyncPolicy)
+	}
+	if len(preserveAttrs) > 0 {
+		cfg.Preserve = preserveAttrs
+	}
+	if bufferSize > 0 {
+		cfg.CopyBufferSize = bufferSize
+	}
+	if retryAttempts > 0 {
+		cfg.RetryAttempts = retryAttempts
+	}
+	if skipPreflight {
+		cfg.SkipPreflight = true
+	}
+	if spaceMargin >= 0 {
+		cfg.SpaceMargin = spaceMargin
+	}
 
-	if err := cfg.Validate(); err != nil {
+	if err := pipeline.Validate(cfg); err != nil {
 		return err
 	}
 
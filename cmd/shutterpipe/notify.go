@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/notify"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	notifyConfigFile string
+	notifyPreset     string
+	notifyFailed     bool
+)
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "notify-test",
+	Short: "Send a sample notification with a preset's or config file's notifiers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var cfg *config.Config
+		switch {
+		case notifyPreset != "":
+			pm, err := config.NewPresetManager()
+			if err != nil {
+				return err
+			}
+			preset, err := pm.LoadPreset(notifyPreset)
+			if err != nil {
+				return err
+			}
+			cfg = config.PresetToConfig(preset)
+		case notifyConfigFile != "":
+			var err error
+			if cfg, err = config.LoadFromFile(notifyConfigFile); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+		default:
+			return fmt.Errorf("--preset or --config is required")
+		}
+
+		if cfg.Notify == nil {
+			return fmt.Errorf("no notifiers configured")
+		}
+		if err := notify.Validate(cfg.Notify); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		n := notify.Notification{
+			Status: notify.StatusCompleted,
+			RunID:  "test",
+			Source: cfg.Source,
+			Dest:   cfg.Dest,
+			Summary: &types.RunSummary{
+				RunID:     "test",
+				Copied:    42,
+				Skipped:   3,
+				StartTime: now.Add(-time.Minute),
+				EndTime:   now,
+				Duration:  time.Minute,
+			},
+		}
+		if notifyFailed {
+			n.Status = notify.StatusFailed
+			n.Error = "test failure"
+		}
+
+		// Deliver regardless of the when condition
+		test := *cfg.Notify
+		test.When = types.NotifyAlways
+
+		errs := notify.Send(&test, n)
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d notifications failed", len(errs))
+		}
+		fmt.Println("Notifications sent")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notifyTestCmd)
+	notifyTestCmd.Flags().StringVarP(&notifyConfigFile, "config", "c", "", "config file path")
+	notifyTestCmd.Flags().StringVarP(&notifyPreset, "preset", "p", "", "preset name")
+	notifyTestCmd.Flags().BoolVar(&notifyFailed, "failed", false, "send a failure notification")
+}
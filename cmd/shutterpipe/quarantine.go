@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	qmanifest "github.com/On-Jun9/ShutterPipe/internal/quarantine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	quarantineDest    string
+	quarantineDirName string
+	showAllQuarantine bool
+)
+
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "Review and resolve quarantined files",
+}
+
+var quarantineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List quarantined files",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := loadQuarantineManifest()
+		if err != nil {
+			return err
+		}
+
+		entries := m.List(!showAllQuarantine)
+		if len(entries) == 0 {
+			fmt.Println("No quarantined files")
+			return nil
+		}
+
+		for _, e := range entries {
+			fmt.Printf("#%d [%s] %s\n", e.ID, e.Status, e.QuarantinePath)
+			fmt.Printf("    intended: %s\n", e.DestPath)
+			fmt.Printf("    source:   %s\n", e.SourcePath)
+			fmt.Printf("    conflict: %s\n", e.ConflictPath)
+			if e.Reason != "" {
+				fmt.Printf("    reason:   %s\n", e.Reason)
+			}
+		}
+		return nil
+	},
+}
+
+var quarantineAcceptCmd = &cobra.Command{
+	Use:   "accept <id>...",
+	Short: "Move files to their intended location, keeping the existing file",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return resolveQuarantine(args, (*qmanifest.Manifest).Accept)
+	},
+}
+
+var quarantineRejectCmd = &cobra.Command{
+	Use:   "reject <id>...",
+	Short: "Delete quarantined files",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return resolveQuarantine(args, (*qmanifest.Manifest).Reject)
+	},
+}
+
+var quarantineReplaceCmd = &cobra.Command{
+	Use:   "replace <id>...",
+	Short: "Move files to their intended location, overwriting the existing file",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return resolveQuarantine(args, (*qmanifest.Manifest).Replace)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(quarantineCmd)
+	quarantineCmd.AddCommand(quarantineListCmd, quarantineAcceptCmd, quarantineRejectCmd, quarantineReplaceCmd)
+
+	quarantineCmd.PersistentFlags().StringVarP(&quarantineDest, "dest", "d", "", "destination directory (NAS)")
+	quarantineCmd.PersistentFlags().StringVar(&quarantineDirName, "quarantine-dir", "quarantine", "quarantine directory name")
+	quarantineListCmd.Flags().BoolVar(&showAllQuarantine, "all", false, "include resolved entries")
+}
+
+func loadQuarantineManifest() (*qmanifest.Manifest, error) {
+	if quarantineDest == "" {
+		return nil, fmt.Errorf("--dest is required")
+	}
+	return qmanifest.Load(filepath.Join(quarantineDest, quarantineDirName))
+}
+
+func resolveQuarantine(args []string, fn func(*qmanifest.Manifest, int) (qmanifest.Entry, error)) error {
+	if quarantineDest == "" {
+		return fmt.Errorf("--dest is required")
+	}
+
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	var firstErr error
+	err := qmanifest.Update(filepath.Join(quarantineDest, quarantineDirName), func(m *qmanifest.Manifest) error {
+		for _, id := range ids {
+			e, err := fn(m, id)
+			if err != nil {
+				fmt.Println(err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			fmt.Printf("#%d %s %s\n", e.ID, e.Status, e.ResolvedPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save quarantine manifest: %w", err)
+	}
+	return firstErr
+}
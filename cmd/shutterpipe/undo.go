@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/journal"
+	qmanifest "github.com/On-Jun9/ShutterPipe/internal/quarantine"
+	"github.com/On-Jun9/ShutterPipe/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var undoDir string
+
+var undoCmd = &cobra.Command{
+	Use:   "undo <run-id>",
+	Short: "Reverse the changes a run made to the destination",
+	Long: `Deletes the files a run copied, restores files it overwrote and removes
+directories it created, using the run's copy journal. Files modified since the
+run are left in place. Undone files are removed from the state file so the
+next run copies them again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		rec, err := history.NewStore(undoDir).Get(id)
+		if err != nil {
+			return err
+		}
+		if rec.DryRun {
+			return fmt.Errorf("run %s was a dry run, nothing to undo", id)
+		}
+
+		entries, err := journal.Load(journal.Path(undoDir, id))
+		if os.IsNotExist(err) {
+			return fmt.Errorf("run %s has no copy journal", id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		res := journal.Undo(entries, journal.BackupDir(rec.Dest, id))
+
+		if len(res.Quarantined) > 0 {
+			err := qmanifest.Update(filepath.Join(rec.Config.Dest, rec.Config.QuarantineDir), func(m *qmanifest.Manifest) error {
+				m.Remove(res.Quarantined...)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save quarantine manifest: %w", err)
+			}
+		}
+
+		if len(res.Reverted) > 0 && rec.Config.StateFile != "" {
+			st, err := state.Load(rec.Config.StateFile)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+			st.Remove(res.Reverted...)
+			if err := st.Save(); err != nil {
+				return fmt.Errorf("failed to save state: %w", err)
+			}
+		}
+
+		for _, path := range res.Removed {
+			fmt.Printf("removed   %s\n", path)
+		}
+		for _, path := range res.Restored {
+			fmt.Printf("restored  %s\n", path)
+		}
+		for _, r := range res.Refused {
+			fmt.Printf("kept      %s: %s\n", r.Path, r.Reason)
+		}
+		fmt.Printf("\n%d removed, %d restored, %d directories removed, %d kept\n",
+			len(res.Removed), len(res.Restored), len(res.Dirs), len(res.Refused))
+
+		if len(res.Refused) > 0 {
+			return fmt.Errorf("%d changes could not be undone", len(res.Refused))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+	undoCmd.Flags().StringVar(&undoDir, "dir", history.DefaultDir(), "run history directory")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchConfigFile string
+	watchPreset     string
+	watchInterval   time.Duration
+	watchMetrics    string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Ingest cards automatically when they are mounted",
+	Long: `Polls /proc/self/mountinfo for volumes under /media, /run/media and /mnt.
+When a volume matches a rule (label, DCIM presence or card UUID), the rule's
+preset is run with the volume as source. Each ingest writes its own log.`,
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringVarP(&watchConfigFile, "config", "c", "", "watch config file (YAML)")
+	watchCmd.Flags().StringVarP(&watchPreset, "preset", "p", "", "preset for any volume with a DCIM folder (when no rules are configured)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 0, "mount polling interval (e.g. 5s)")
+	watchCmd.Flags().StringVar(&watchMetrics, "metrics-addr", "", "serve Prometheus metrics at /metrics on this address (e.g. 127.0.0.1:9464)")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	cfg := watch.DefaultConfig()
+	if watchConfigFile != "" {
+		var err error
+		cfg, err = watch.LoadConfig(watchConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load watch config: %w", err)
+		}
+	}
+	if watchInterval > 0 {
+		cfg.Interval = watchInterval
+	}
+	if watchMetrics != "" {
+		cfg.MetricsAddr = watchMetrics
+	}
+	if len(cfg.Rules) == 0 {
+		if watchPreset == "" {
+			return fmt.Errorf("no watch rules configured: use --config or --preset")
+		}
+		cfg.Rules = []watch.Rule{{Name: "dcim", RequireDCIM: true, Preset: watchPreset, CardMode: true}}
+	}
+
+	w, err := watch.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		fmt.Println("[watch] stopping...")
+		close(stop)
+	}()
+
+	fmt.Printf("[watch] watching %v every %s\n", cfg.MountRoots, cfg.Interval)
+	return w.Run(stop)
+}
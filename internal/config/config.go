@@ -4,30 +4,66 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/On-Jun9/ShutterPipe/pkg/types"
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultPerceptualThreshold is the Hamming distance (out of 64 bits) up to
+// which two images are considered near-duplicates.
+const DefaultPerceptualThreshold = 10
+
+// DefaultSpaceMargin is kept free on every destination filesystem.
+const DefaultSpaceMargin int64 = 1 << 30
+
+// DefaultUndoRetentionDays is how long backups of overwritten files are kept
+// in the destination for undo.
+const DefaultUndoRetentionDays = 30
+
+// MaxPerceptualThreshold is the largest Hamming distance between two hashes.
+const MaxPerceptualThreshold = 64
+
 type Config struct {
-	Source            string                 `yaml:"source" json:"source"`
-	Dest              string                 `yaml:"dest" json:"dest"`
-	IncludeExtensions []string               `yaml:"include_extensions" json:"include_extensions"`
-	Jobs              int                    `yaml:"jobs" json:"jobs"`
-	DedupMethod       types.DedupMethod      `yaml:"dedup_method" json:"dedup_method"`
-	ConflictPolicy    types.ConflictPolicy   `yaml:"conflict_policy" json:"conflict_policy"`
-	OrganizeStrategy  types.OrganizeStrategy `yaml:"organize_strategy" json:"organize_strategy"`
-	EventName         string                 `yaml:"event_name" json:"event_name"`
-	UnclassifiedDir   string                 `yaml:"unclassified_dir" json:"unclassified_dir"`
-	QuarantineDir     string                 `yaml:"quarantine_dir" json:"quarantine_dir"`
-	StateFile         string                 `yaml:"state_file" json:"state_file"`
-	LogFile           string                 `yaml:"log_file" json:"log_file"`
-	LogJSON           bool                   `yaml:"log_json" json:"log_json"`
-	DryRun            bool                   `yaml:"dry_run" json:"dry_run"`
-	HashVerify        bool                   `yaml:"hash_verify" json:"hash_verify"`
-	IgnoreState       bool                   `yaml:"ignore_state" json:"ignore_state"`
-	DateFilterStart   string                 `yaml:"date_filter_start,omitempty" json:"date_filter_start,omitempty"`
-	DateFilterEnd     string                 `yaml:"date_filter_end,omitempty" json:"date_filter_end,omitempty"`
+	Source              string                 `yaml:"source" json:"source"`
+	Dest                string                 `yaml:"dest" json:"dest"`
+	IncludeExtensions   []string               `yaml:"include_extensions" json:"include_extensions"`
+	IncludePatterns     []string               `yaml:"include_patterns,omitempty" json:"include_patterns,omitempty"`
+	ExcludePatterns     []string               `yaml:"exclude_patterns,omitempty" json:"exclude_patterns,omitempty"`
+	MinFileSize         int64                  `yaml:"min_file_size,omitempty" json:"min_file_size,omitempty"`
+	MaxFileSize         int64                  `yaml:"max_file_size,omitempty" json:"max_file_size,omitempty"`
+	CardMode            bool                   `yaml:"card_mode" json:"card_mode"`
+	Jobs                int                    `yaml:"jobs" json:"jobs"`
+	DedupMethod         types.DedupMethod      `yaml:"dedup_method" json:"dedup_method"`
+	ConflictPolicy      types.ConflictPolicy   `yaml:"conflict_policy" json:"conflict_policy"`
+	OrganizeStrategy    types.OrganizeStrategy `yaml:"organize_strategy" json:"organize_strategy"`
+	EventName           string                 `yaml:"event_name" json:"event_name"`
+	UnclassifiedDir     string                 `yaml:"unclassified_dir" json:"unclassified_dir"`
+	QuarantineDir       string                 `yaml:"quarantine_dir" json:"quarantine_dir"`
+	ReviewDir           string                 `yaml:"review_dir" json:"review_dir"`
+	ReportDir           string                 `yaml:"report_dir" json:"report_dir"`
+	ReportFormats       []string               `yaml:"report_formats" json:"report_formats"`
+	PerceptualThreshold *int                   `yaml:"perceptual_threshold,omitempty" json:"perceptual_threshold,omitempty"`
+	StateFile           string                 `yaml:"state_file" json:"state_file"`
+	HistoryDir          string                 `yaml:"history_dir" json:"-"`
+	LogFile             string                 `yaml:"log_file" json:"log_file"`
+	LogJSON             bool                   `yaml:"log_json" json:"log_json"`
+	DryRun              bool                   `yaml:"dry_run" json:"dry_run"`
+	HashVerify          bool                   `yaml:"hash_verify" json:"hash_verify"`
+	UndoRetentionDays   int                    `yaml:"undo_retention_days" json:"undo_retention_days"`
+	SpaceMargin         int64                  `yaml:"space_margin" json:"space_margin"`
+	Bandwidth           types.BandwidthConfig  `yaml:"bandwidth,omitempty" json:"bandwidth,omitempty"`
+	Fsync               types.FsyncPolicy      `yaml:"fsync" json:"fsync"`
+	CopyBufferSize      int                    `yaml:"copy_buffer_size" json:"copy_buffer_size"`
+	RetryAttempts       int                    `yaml:"retry_attempts" json:"retry_attempts"`
+	RetryDelaySec       int                    `yaml:"retry_delay_sec" json:"retry_delay_sec"`
+	Preserve            []string               `yaml:"preserve,omitempty" json:"preserve,omitempty"`
+	SkipPreflight       bool                   `yaml:"skip_preflight,omitempty" json:"skip_preflight,omitempty"`
+	IgnoreState         bool                   `yaml:"ignore_state" json:"ignore_state"`
+	DateFilterStart     string                 `yaml:"date_filter_start,omitempty" json:"date_filter_start,omitempty"`
+	DateFilterEnd       string                 `yaml:"date_filter_end,omitempty" json:"date_filter_end,omitempty"`
+	Hooks               types.HooksConfig      `yaml:"hooks,omitempty" json:"-"`
+	Notify              *types.NotifyConfig    `yaml:"notify,omitempty" json:"notify,omitempty"`
 }
 
 func DefaultConfig() *Config {
@@ -44,19 +80,29 @@ func DefaultConfig() *Config {
 			"jpg", "jpeg", "heic", "heif", "png", "raw", "arw", "cr2", "nef", "dng",
 			"mp4", "mov", "avi", "mkv", "mxf", "xml",
 		},
-		Jobs:             jobs,
-		DedupMethod:      types.DedupMethodNameSize,
-		ConflictPolicy:   types.ConflictPolicySkip,
-		OrganizeStrategy: types.OrganizeByDate,
-		EventName:        "",
-		UnclassifiedDir:  "unclassified",
-		QuarantineDir:    "quarantine",
-		StateFile:        filepath.Join(stateDir, "state.json"),
-		LogFile:          filepath.Join(stateDir, "shutterpipe.log"),
-		LogJSON:          false,
-		DryRun:           false,
-		HashVerify:       false,
-		IgnoreState:      false,
+		Jobs:                jobs,
+		DedupMethod:         types.DedupMethodNameSize,
+		ConflictPolicy:      types.ConflictPolicySkip,
+		OrganizeStrategy:    types.OrganizeByDate,
+		EventName:           "",
+		UnclassifiedDir:     "unclassified",
+		QuarantineDir:       "quarantine",
+		ReviewDir:           "review",
+		ReportDir:           "reports",
+		PerceptualThreshold: intPtr(DefaultPerceptualThreshold),
+		StateFile:           filepath.Join(stateDir, "state.json"),
+		HistoryDir:          filepath.Join(stateDir, "runs"),
+		LogFile:             filepath.Join(stateDir, "shutterpipe.log"),
+		LogJSON:             false,
+		DryRun:              false,
+		HashVerify:          false,
+		UndoRetentionDays:   DefaultUndoRetentionDays,
+		SpaceMargin:         DefaultSpaceMargin,
+		Fsync:               types.FsyncBatch,
+		CopyBufferSize:      types.DefaultCopyBufferSize,
+		RetryAttempts:       types.DefaultRetryAttempts,
+		RetryDelaySec:       int(types.DefaultRetryDelay / time.Second),
+		IgnoreState:         false,
 	}
 }
 
@@ -75,6 +121,9 @@ func LoadFromFile(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// Validate checks the settings and fills in defaults. Hooks, notifiers and
+// copier settings are checked by the packages using them; see
+// pipeline.Validate.
 func (c *Config) Validate() error {
 	if c.Source == "" {
 		return &ValidationError{Field: "source", Message: "source path is required"}
@@ -95,16 +144,57 @@ func (c *Config) Validate() error {
 	if c.StateFile == "" {
 		c.StateFile = filepath.Join(stateDir, "state.json")
 	}
+	if c.HistoryDir == "" {
+		c.HistoryDir = filepath.Join(stateDir, "runs")
+	}
 	if c.UnclassifiedDir == "" {
 		c.UnclassifiedDir = "unclassified"
 	}
 	if c.QuarantineDir == "" {
 		c.QuarantineDir = "quarantine"
 	}
+	if c.ReviewDir == "" {
+		c.ReviewDir = "review"
+	}
+	if c.ReportDir == "" {
+		c.ReportDir = "reports"
+	}
+	for _, f := range c.ReportFormats {
+		if f != "html" && f != "csv" && f != "json" {
+			return &ValidationError{Field: "report_formats", Message: "unknown report format: " + f}
+		}
+	}
+	if c.RetryAttempts <= 0 {
+		c.RetryAttempts = types.DefaultRetryAttempts
+	}
+	if c.RetryDelaySec <= 0 {
+		c.RetryDelaySec = int(types.DefaultRetryDelay / time.Second)
+	}
+	if c.CopyBufferSize <= 0 {
+		c.CopyBufferSize = types.DefaultCopyBufferSize
+	}
+	if c.Fsync == "" {
+		c.Fsync = types.FsyncBatch
+	}
+	if c.SpaceMargin < 0 {
+		return &ValidationError{Field: "space_margin", Message: "space margin must not be negative"}
+	}
+	if c.UndoRetentionDays <= 0 {
+		c.UndoRetentionDays = DefaultUndoRetentionDays
+	}
+	if c.PerceptualThreshold == nil {
+		c.PerceptualThreshold = intPtr(DefaultPerceptualThreshold)
+	} else if *c.PerceptualThreshold < 0 || *c.PerceptualThreshold > MaxPerceptualThreshold {
+		return &ValidationError{Field: "perceptual_threshold", Message: "perceptual threshold must be between 0 and 64"}
+	}
 
 	return nil
 }
 
+func intPtr(v int) *int {
+	return &v
+}
+
 type ValidationError struct {
 	Field   string
 	Message string
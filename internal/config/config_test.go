@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestValidate_PerceptualThreshold(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *int
+		want  int
+		ok    bool
+	}{
+		{"unset", nil, DefaultPerceptualThreshold, true},
+		{"exact match", intPtr(0), 0, true},
+		{"maximum", intPtr(64), 64, true},
+		{"negative", intPtr(-1), 0, false},
+		{"too large", intPtr(65), 0, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Source: "/card", Dest: "/nas", PerceptualThreshold: tt.value}
+		err := cfg.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && *cfg.PerceptualThreshold != tt.want {
+			t.Errorf("%s: threshold = %d, want %d", tt.name, *cfg.PerceptualThreshold, tt.want)
+		}
+	}
+}
@@ -32,34 +32,65 @@ func NewPresetManager() (*PresetManager, error) {
 
 // ConfigToPreset converts a Config to a ConfigPreset.
 func ConfigToPreset(cfg *Config, name, description string) *types.ConfigPreset {
+	spaceMargin := cfg.SpaceMargin
 	return &types.ConfigPreset{
-		Name:              name,
-		Description:       description,
-		Source:            cfg.Source,
-		Dest:              cfg.Dest,
-		IncludeExtensions: cfg.IncludeExtensions,
-		Jobs:              cfg.Jobs,
-		DedupMethod:       cfg.DedupMethod,
-		ConflictPolicy:    cfg.ConflictPolicy,
-		OrganizeStrategy:  cfg.OrganizeStrategy,
-		EventName:         cfg.EventName,
-		UnclassifiedDir:   cfg.UnclassifiedDir,
-		QuarantineDir:     cfg.QuarantineDir,
-		DryRun:            cfg.DryRun,
-		HashVerify:        cfg.HashVerify,
-		IgnoreState:       cfg.IgnoreState,
-		DateFilterStart:   cfg.DateFilterStart,
-		DateFilterEnd:     cfg.DateFilterEnd,
-		CreatedAt:         time.Now(),
+		Name:                name,
+		Description:         description,
+		Source:              cfg.Source,
+		Dest:                cfg.Dest,
+		IncludeExtensions:   cfg.IncludeExtensions,
+		IncludePatterns:     cfg.IncludePatterns,
+		ExcludePatterns:     cfg.ExcludePatterns,
+		MinFileSize:         cfg.MinFileSize,
+		MaxFileSize:         cfg.MaxFileSize,
+		CardMode:            cfg.CardMode,
+		Jobs:                cfg.Jobs,
+		DedupMethod:         cfg.DedupMethod,
+		ConflictPolicy:      cfg.ConflictPolicy,
+		OrganizeStrategy:    cfg.OrganizeStrategy,
+		EventName:           cfg.EventName,
+		UnclassifiedDir:     cfg.UnclassifiedDir,
+		QuarantineDir:       cfg.QuarantineDir,
+		ReviewDir:           cfg.ReviewDir,
+		ReportFormats:       cfg.ReportFormats,
+		Notify:              cfg.Notify,
+		Bandwidth:           bandwidthPreset(cfg.Bandwidth),
+		Fsync:               cfg.Fsync,
+		CopyBufferSize:      cfg.CopyBufferSize,
+		RetryAttempts:       cfg.RetryAttempts,
+		RetryDelaySec:       cfg.RetryDelaySec,
+		Preserve:            cfg.Preserve,
+		SpaceMargin:         &spaceMargin,
+		SkipPreflight:       cfg.SkipPreflight,
+		PerceptualThreshold: cfg.PerceptualThreshold,
+		DryRun:              cfg.DryRun,
+		HashVerify:          cfg.HashVerify,
+		UndoRetentionDays:   cfg.UndoRetentionDays,
+		IgnoreState:         cfg.IgnoreState,
+		DateFilterStart:     cfg.DateFilterStart,
+		DateFilterEnd:       cfg.DateFilterEnd,
+		CreatedAt:           time.Now(),
 	}
 }
 
+func bandwidthPreset(bw types.BandwidthConfig) *types.BandwidthConfig {
+	if bw.Limit == 0 && bw.PerWorker == 0 && len(bw.Schedule) == 0 {
+		return nil
+	}
+	return &bw
+}
+
 // PresetToConfig converts a ConfigPreset to a Config.
 func PresetToConfig(preset *types.ConfigPreset) *Config {
 	cfg := DefaultConfig()
 	cfg.Source = preset.Source
 	cfg.Dest = preset.Dest
 	cfg.IncludeExtensions = preset.IncludeExtensions
+	cfg.IncludePatterns = preset.IncludePatterns
+	cfg.ExcludePatterns = preset.ExcludePatterns
+	cfg.MinFileSize = preset.MinFileSize
+	cfg.MaxFileSize = preset.MaxFileSize
+	cfg.CardMode = preset.CardMode
 	cfg.Jobs = preset.Jobs
 	cfg.DedupMethod = preset.DedupMethod
 	cfg.ConflictPolicy = preset.ConflictPolicy
@@ -67,8 +98,39 @@ func PresetToConfig(preset *types.ConfigPreset) *Config {
 	cfg.EventName = preset.EventName
 	cfg.UnclassifiedDir = preset.UnclassifiedDir
 	cfg.QuarantineDir = preset.QuarantineDir
+	if preset.ReviewDir != "" {
+		cfg.ReviewDir = preset.ReviewDir
+	}
+	if preset.ReportFormats != nil {
+		cfg.ReportFormats = preset.ReportFormats
+	}
+	cfg.Notify = preset.Notify
+	if preset.Bandwidth != nil {
+		cfg.Bandwidth = *preset.Bandwidth
+	}
+	if preset.Fsync != "" {
+		cfg.Fsync = preset.Fsync
+	}
+	cfg.Preserve = preset.Preserve
+	if preset.SpaceMargin != nil {
+		cfg.SpaceMargin = *preset.SpaceMargin
+	}
+	cfg.SkipPreflight = preset.SkipPreflight
+	if preset.RetryAttempts > 0 {
+		cfg.RetryAttempts = preset.RetryAttempts
+	}
+	if preset.RetryDelaySec > 0 {
+		cfg.RetryDelaySec = preset.RetryDelaySec
+	}
+	if preset.CopyBufferSize > 0 {
+		cfg.CopyBufferSize = preset.CopyBufferSize
+	}
+	cfg.PerceptualThreshold = preset.PerceptualThreshold
 	cfg.DryRun = preset.DryRun
 	cfg.HashVerify = preset.HashVerify
+	if preset.UndoRetentionDays > 0 {
+		cfg.UndoRetentionDays = preset.UndoRetentionDays
+	}
 	cfg.IgnoreState = preset.IgnoreState
 	cfg.DateFilterStart = preset.DateFilterStart
 	cfg.DateFilterEnd = preset.DateFilterEnd
@@ -87,7 +149,11 @@ func (pm *PresetManager) SavePreset(preset *types.ConfigPreset) error {
 		return fmt.Errorf("failed to marshal preset: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	// Presets may hold notifier credentials
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		return fmt.Errorf("failed to write preset file: %w", err)
+	}
+	if err := os.Chmod(filename, 0600); err != nil {
 		return fmt.Errorf("failed to write preset file: %w", err)
 	}
 
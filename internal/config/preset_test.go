@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestPresetRoundTrip_ScannerFilters(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.IncludePatterns = []string{"DCIM/**"}
+	cfg.ExcludePatterns = []string{"*.tmp"}
+	cfg.MinFileSize = 1024
+	cfg.MaxFileSize = 1 << 30
+	cfg.CardMode = true
+
+	got := PresetToConfig(ConfigToPreset(cfg, "card", ""))
+	if !reflect.DeepEqual(got.IncludePatterns, cfg.IncludePatterns) ||
+		!reflect.DeepEqual(got.ExcludePatterns, cfg.ExcludePatterns) ||
+		got.MinFileSize != cfg.MinFileSize ||
+		got.MaxFileSize != cfg.MaxFileSize ||
+		!got.CardMode {
+		t.Errorf("scanner filters were not kept: %+v", got)
+	}
+}
+
+func TestPresetRoundTrip_ExactPerceptualThreshold(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PerceptualThreshold = intPtr(0)
+
+	got := PresetToConfig(ConfigToPreset(cfg, "exact", ""))
+	if got.PerceptualThreshold == nil || *got.PerceptualThreshold != 0 {
+		t.Errorf("threshold = %v, want 0", got.PerceptualThreshold)
+	}
+}
+
+func TestPresetRoundTrip_Preflight(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SpaceMargin = 0
+	cfg.SkipPreflight = true
+
+	got := PresetToConfig(ConfigToPreset(cfg, "nas", ""))
+	if got.SpaceMargin != 0 || !got.SkipPreflight {
+		t.Errorf("preflight settings were not kept: margin %d, skip %v", got.SpaceMargin, got.SkipPreflight)
+	}
+
+	// Presets saved before the margin was stored keep the default
+	if got := PresetToConfig(&types.ConfigPreset{}); got.SpaceMargin != DefaultConfig().SpaceMargin {
+		t.Errorf("margin of an old preset = %d, want the default", got.SpaceMargin)
+	}
+}
+
+func TestSavePreset_Private(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	pm, err := NewPresetManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(pm.presetsDir, "card.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.SavePreset(ConfigToPreset(DefaultConfig(), "card", "")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("preset mode = %v, want 0600", perm)
+	}
+}
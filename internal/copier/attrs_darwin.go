@@ -0,0 +1,23 @@
+package copier
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// The syscall package has no xattr calls on macOS.
+const xattrSupported = false
+
+// accessTime returns the last access time recorded in fi.
+func accessTime(fi os.FileInfo) (time.Time, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec), true
+}
+
+func copyXattrs(src, dst string) error {
+	return errXattrUnsupported
+}
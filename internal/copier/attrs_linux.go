@@ -0,0 +1,95 @@
+package copier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const xattrSupported = true
+
+// accessTime returns the last access time recorded in fi.
+func accessTime(fi os.FileInfo) (time.Time, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Atim.Sec, st.Atim.Nsec), true
+}
+
+// copyXattrs copies the extended attributes of src to dst. Nothing is copied
+// if either filesystem doesn't support xattrs, and attributes the process
+// may not set (security., trusted., system.) are skipped.
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+
+	for _, name := range names {
+		value, err := getXattr(src, name)
+		if err != nil {
+			return fmt.Errorf("failed to read xattr %s: %w", name, err)
+		}
+		if err := syscall.Setxattr(dst, name, value, 0); err != nil {
+			if errors.Is(err, syscall.ENOTSUP) {
+				return nil
+			}
+			if errors.Is(err, syscall.EPERM) && !strings.HasPrefix(name, "user.") {
+				continue
+			}
+			return fmt.Errorf("failed to write xattr %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func listXattrs(path string) ([]string, error) {
+	for {
+		size, err := syscall.Listxattr(path, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		size, err = syscall.Listxattr(path, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			// Attributes were added in between
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, name := range strings.Split(string(buf[:size]), "\x00") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		size, err = syscall.Getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	}
+}
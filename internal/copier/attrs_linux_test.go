@@ -0,0 +1,33 @@
+package copier
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestCopier_PreserveXattrs(t *testing.T) {
+	dir := t.TempDir()
+	task := copyTask(t, dir)
+
+	tags := []byte("Red\n6")
+	if err := syscall.Setxattr(task.Source.Path, "user.xdg.tags", tags, 0); err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			t.Skip("filesystem has no user xattrs")
+		}
+		t.Fatal(err)
+	}
+
+	c := New(1, false, false)
+	c.SetDurability(types.FsyncNever, []string{PreserveXattrs})
+	if result := c.copyOne(task, newWorker(0, 0)); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	got, err := getXattr(task.DestPath, "user.xdg.tags")
+	if err != nil || string(got) != string(tags) {
+		t.Errorf("xattr not copied: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package copier
+
+import (
+	"os"
+	"time"
+)
+
+const xattrSupported = false
+
+func accessTime(fi os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func copyXattrs(src, dst string) error {
+	return errXattrUnsupported
+}
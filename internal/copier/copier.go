@@ -1,18 +1,51 @@
 package copier
 
 import (
-	"io"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/On-Jun9/ShutterPipe/internal/journal"
 	"github.com/On-Jun9/ShutterPipe/pkg/types"
 )
 
+// ErrCancelled is returned for tasks that were not started because the copy
+// was cancelled (e.g. the source card was removed).
+var ErrCancelled = errors.New("copy cancelled")
+
 type Copier struct {
 	workers    int
 	dryRun     bool
 	hashVerify bool
+	cancelled  atomic.Bool
+	journal    *journal.Journal
+
+	bandwidth types.BandwidthConfig
+	global    *Limiter
+	// override is set once limits are changed live; the schedule no
+	// longer applies after that.
+	override   atomic.Bool
+	mu         sync.Mutex
+	perWorker  int64
+	workerList []*worker
+	meter      rateMeter
+
+	bufferSize int
+
+	retryAttempts int
+	retryDelay    time.Duration
+
+	fsync    types.FsyncPolicy
+	preserve map[string]bool
+	syncMu   sync.Mutex
+	pending  []string
+	syncErrs []*SyncError
 }
 
 func New(workers int, dryRun, hashVerify bool) *Copier {
@@ -20,6 +53,10 @@ func New(workers int, dryRun, hashVerify bool) *Copier {
 		workers:    workers,
 		dryRun:     dryRun,
 		hashVerify: hashVerify,
+		global:     NewLimiter(0),
+
+		retryAttempts: types.DefaultRetryAttempts,
+		retryDelay:    types.DefaultRetryDelay,
 	}
 }
 
@@ -31,16 +68,32 @@ type CopyResult struct {
 func (c *Copier) CopyAll(tasks []types.CopyTask, resultChan chan<- CopyResult) {
 	taskChan := make(chan types.CopyTask, len(tasks))
 
+	c.mu.Lock()
+	if !c.override.Load() {
+		c.global.SetLimit(ScheduledLimit(c.bandwidth, time.Now()))
+		c.perWorker = c.bandwidth.PerWorker
+	}
+	c.workerList = make([]*worker, c.workers)
+	for i := range c.workerList {
+		c.workerList[i] = newWorker(i, c.perWorker)
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go c.monitor(done)
+
 	var wg sync.WaitGroup
 	for i := 0; i < c.workers; i++ {
 		wg.Add(1)
-		go func() {
+		go func(w *worker) {
 			defer wg.Done()
 			for task := range taskChan {
-				result := c.copyOne(task)
+				start := time.Now()
+				result := c.copyOne(task, w)
+				result.Task.Duration = time.Since(start)
 				resultChan <- result
 			}
-		}()
+		}(c.workerList[i])
 	}
 
 	for _, task := range tasks {
@@ -49,60 +102,248 @@ func (c *Copier) CopyAll(tasks []types.CopyTask, resultChan chan<- CopyResult) {
 	close(taskChan)
 
 	wg.Wait()
+	close(done)
 	close(resultChan)
 }
 
-func (c *Copier) copyOne(task types.CopyTask) CopyResult {
+// monitor samples the total and per-worker throughput and applies the
+// bandwidth schedule every second until done is closed.
+func (c *Copier) monitor(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			c.meter.sample(now.Sub(last))
+			for _, w := range c.workerList {
+				w.meter.sample(now.Sub(last))
+			}
+			last = now
+			if !c.override.Load() && len(c.bandwidth.Schedule) > 0 {
+				c.global.SetLimit(ScheduledLimit(c.bandwidth, now))
+			}
+		}
+	}
+}
+
+// SetBandwidth configures the throughput limits and schedule. Call it
+// before CopyAll.
+func (c *Copier) SetBandwidth(cfg types.BandwidthConfig) {
+	c.bandwidth = cfg
+}
+
+// SetLimits changes the global and per-worker limits while copying. The
+// bandwidth schedule stops applying for the rest of the run.
+func (c *Copier) SetLimits(limit, perWorker int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.override.Store(true)
+	c.global.SetLimit(limit)
+	c.perWorker = perWorker
+	for _, w := range c.workerList {
+		w.limiter.SetLimit(perWorker)
+	}
+}
+
+// Limits returns the current global and per-worker limits.
+func (c *Copier) Limits() (limit, perWorker int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.global.Limit(), c.perWorker
+}
+
+// Throughput returns the smoothed copy rate in bytes per second.
+func (c *Copier) Throughput() float64 {
+	return c.meter.value()
+}
+
+// SetJournal records every change made to the destination in j.
+func (c *Copier) SetJournal(j *journal.Journal) {
+	c.journal = j
+}
+
+// Cancel makes remaining tasks fail with ErrCancelled. Copies already in
+// progress finish or fail on their own.
+func (c *Copier) Cancel() {
+	c.cancelled.Store(true)
+}
+
+// copyOne copies a task on worker w, retrying transient failures with
+// exponential backoff up to the attempt limit.
+func (c *Copier) copyOne(task types.CopyTask, w *worker) CopyResult {
+	if c.cancelled.Load() {
+		task.Status = types.TaskStatusFailed
+		task.Action = types.CopyActionFailed
+		task.Error = ErrCancelled.Error()
+		task.ErrorClass = types.ErrorClassCancelled
+		return CopyResult{Task: task, Error: ErrCancelled}
+	}
+
 	if c.dryRun {
 		task.Status = types.TaskStatusCompleted
 		task.Action = types.CopyActionCopied
 		return CopyResult{Task: task}
 	}
 
+	defer w.begin(nil)
+	for attempt := 1; ; attempt++ {
+		// Each attempt copies the file from the start
+		w.begin(&task)
+		result := c.copyAttempt(task, w)
+		result.Task.Attempts = attempt
+		if result.Error == nil {
+			return result
+		}
+		result.Task.ErrorClass = Classify(result.Error)
+
+		// A file that failed to sync after the rename is already in place
+		var syncErr *SyncError
+		if !Retryable(result.Task.ErrorClass) || errors.As(result.Error, &syncErr) || attempt >= c.retryAttempts {
+			return result
+		}
+		if !c.sleep(c.backoff(attempt)) {
+			return result
+		}
+	}
+}
+
+func (c *Copier) copyAttempt(task types.CopyTask, w *worker) CopyResult {
+	if c.journal != nil {
+		if err := c.journal.RecordDirs(filepath.Dir(task.DestPath)); err != nil {
+			return failTask(task, fmt.Errorf("failed to write journal: %w", err))
+		}
+	}
+
 	if err := os.MkdirAll(filepath.Dir(task.DestPath), 0755); err != nil {
-		task.Status = types.TaskStatusFailed
-		task.Error = err.Error()
-		return CopyResult{Task: task, Error: err}
+		return failTask(task, err)
+	}
+
+	// Keep the file about to be replaced so the run can be undone
+	entry := journal.Entry{Op: journal.OpCreate, Path: task.DestPath, Source: task.Source.Path}
+	if task.Action == types.CopyActionQuarantined {
+		entry.Op = journal.OpQuarantine
+	}
+	if c.journal != nil {
+		if _, err := os.Lstat(task.DestPath); err == nil {
+			backup, err := c.journal.Backup(task.DestPath)
+			if err != nil {
+				return failTask(task, fmt.Errorf("failed to back up overwritten file: %w", err))
+			}
+			entry.Op = journal.OpOverwrite
+			entry.Backup = backup
+		}
 	}
 
 	partPath := task.DestPath + ".part"
 
-	if err := c.atomicCopy(task.Source.Path, partPath, task.DestPath); err != nil {
+	var h hash.Hash
+	if c.hashVerify {
+		h = sha256.New()
+	}
+
+	method, err := c.atomicCopy(task.Source.Path, partPath, task.DestPath, h, w)
+	task.Method = method
+	if err != nil {
+		// After a sync failure the file has already replaced the old one, so
+		// the backup is journaled to let undo restore it
+		var syncErr *SyncError
+		if errors.As(err, &syncErr) {
+			if jerr := c.record(entry, task); jerr != nil {
+				return failTask(task, fmt.Errorf("failed to write journal: %w", jerr))
+			}
+			return failTask(task, err)
+		}
 		os.Remove(partPath)
-		task.Status = types.TaskStatusFailed
-		task.Error = err.Error()
-		return CopyResult{Task: task, Error: err}
+		if entry.Backup != "" {
+			os.Remove(entry.Backup)
+		}
+		return failTask(task, err)
+	}
+
+	if h != nil {
+		task.Hash = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	if err := c.record(entry, task); err != nil {
+		return failTask(task, fmt.Errorf("failed to write journal: %w", err))
 	}
 
 	task.Status = types.TaskStatusCompleted
 	return CopyResult{Task: task}
 }
 
-func (c *Copier) atomicCopy(src, partDest, finalDest string) error {
+// record adds the written file to the undo journal, if there is one.
+func (c *Copier) record(entry journal.Entry, task types.CopyTask) error {
+	if c.journal == nil {
+		return nil
+	}
+	if info, err := os.Stat(task.DestPath); err == nil {
+		entry.Size = info.Size()
+		entry.ModTime = info.ModTime()
+	}
+	entry.Hash = task.Hash
+	return c.journal.Record(entry)
+}
+
+func failTask(task types.CopyTask, err error) CopyResult {
+	task.Status = types.TaskStatusFailed
+	task.Error = err.Error()
+	return CopyResult{Task: task, Error: err}
+}
+
+// atomicCopy copies src to partDest and renames it to finalDest, returning
+// the copy mechanism used. If h is set it receives the copied content. The
+// fsync policy decides whether the file and its directory are synced here,
+// batched, or left to the OS.
+func (c *Copier) atomicCopy(src, partDest, finalDest string, h hash.Hash, w *worker) (types.CopyMethod, error) {
 	srcFile, err := os.Open(src)
 	if err != nil {
-		return err
+		return "", &sourceError{err}
 	}
 	defer srcFile.Close()
 
+	// Stat before reading so the access time is the original one
+	info, err := srcFile.Stat()
+	if err != nil {
+		return "", &sourceError{err}
+	}
+
 	dstFile, err := os.Create(partDest)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	_, err = io.Copy(dstFile, srcFile)
+	method, err := c.copyData(dstFile, srcFile, h, w)
+	if err == nil && c.fsync == types.FsyncAlways {
+		err = dstFile.Sync()
+	}
 	if closeErr := dstFile.Close(); closeErr != nil && err == nil {
 		err = closeErr
 	}
 	if err != nil {
-		return err
+		return method, err
 	}
 
-	// Preserve modification time
-	info, err := srcFile.Stat()
-	if err == nil {
-		os.Chtimes(partDest, info.ModTime(), info.ModTime())
+	if err := c.preserveAttrs(info, src, partDest); err != nil {
+		return method, fmt.Errorf("failed to preserve attributes: %w", err)
+	}
+
+	if err := os.Rename(partDest, finalDest); err != nil {
+		return method, err
 	}
 
-	return os.Rename(partDest, finalDest)
+	switch c.fsync {
+	case types.FsyncAlways:
+		if err := syncDir(filepath.Dir(finalDest)); err != nil {
+			return method, &SyncError{Path: finalDest, Err: err}
+		}
+	case types.FsyncBatch:
+		c.queueSync(finalDest)
+	}
+	return method, nil
 }
@@ -0,0 +1,192 @@
+package copier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Attributes that can be preserved besides the modification time.
+const (
+	PreserveMode   = "mode"
+	PreserveAtime  = "atime"
+	PreserveXattrs = "xattrs"
+)
+
+// syncBatchSize is how many copied files FsyncBatch collects before syncing.
+const syncBatchSize = 64
+
+var errXattrUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// SyncError reports a copied file that could not be flushed to disk.
+type SyncError struct {
+	Path string
+	Err  error
+}
+
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("failed to sync %s: %v", e.Path, e.Err)
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateFsync checks the fsync policy.
+func ValidateFsync(policy types.FsyncPolicy) error {
+	switch policy {
+	case "", types.FsyncAlways, types.FsyncBatch, types.FsyncNever:
+		return nil
+	}
+	return fmt.Errorf("unknown fsync policy %q", policy)
+}
+
+// ValidatePreserve checks the attributes to preserve.
+func ValidatePreserve(attrs []string) error {
+	for _, attr := range attrs {
+		switch attr {
+		case PreserveMode, PreserveAtime:
+		case PreserveXattrs:
+			if !xattrSupported {
+				return errXattrUnsupported
+			}
+		default:
+			return fmt.Errorf("unknown attribute %q", attr)
+		}
+	}
+	return nil
+}
+
+// SetDurability sets the fsync policy and the attributes copied from the
+// source. Call it before CopyAll.
+func (c *Copier) SetDurability(policy types.FsyncPolicy, preserve []string) {
+	c.fsync = policy
+	c.preserve = make(map[string]bool, len(preserve))
+	for _, attr := range preserve {
+		c.preserve[attr] = true
+	}
+}
+
+// preserveAttrs applies the source's modification time and the selected
+// attributes to dst.
+func (c *Copier) preserveAttrs(info os.FileInfo, src, dst string) error {
+	if c.preserve[PreserveXattrs] {
+		if err := copyXattrs(src, dst); err != nil {
+			return err
+		}
+	}
+	if c.preserve[PreserveMode] {
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	atime := info.ModTime()
+	if c.preserve[PreserveAtime] {
+		if t, ok := accessTime(info); ok {
+			atime = t
+		}
+	}
+	// Filesystems that can't store times are not worth failing the copy
+	os.Chtimes(dst, atime, info.ModTime())
+	return nil
+}
+
+// queueSync records a copied file for FsyncBatch and syncs the batch once
+// it is full.
+func (c *Copier) queueSync(path string) {
+	c.syncMu.Lock()
+	c.pending = append(c.pending, path)
+	if len(c.pending) < syncBatchSize {
+		c.syncMu.Unlock()
+		return
+	}
+	batch := c.pending
+	c.pending = nil
+	c.syncMu.Unlock()
+
+	errs := syncFiles(batch)
+
+	c.syncMu.Lock()
+	c.syncErrs = append(c.syncErrs, errs...)
+	c.syncMu.Unlock()
+}
+
+// Flush syncs the files FsyncBatch has not synced yet and returns every file
+// that failed to sync since the last Flush. Call it before recording copied
+// files as processed.
+func (c *Copier) Flush() []*SyncError {
+	c.syncMu.Lock()
+	batch := c.pending
+	c.pending = nil
+	c.syncMu.Unlock()
+
+	errs := syncFiles(batch)
+
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	errs = append(c.syncErrs, errs...)
+	c.syncErrs = nil
+	return errs
+}
+
+// syncFiles syncs each file and then each distinct parent directory.
+func syncFiles(paths []string) []*SyncError {
+	var errs []*SyncError
+	dirs := make(map[string][]string)
+	var order []string
+
+	for _, path := range paths {
+		if err := syncFile(path); err != nil {
+			errs = append(errs, &SyncError{Path: path, Err: err})
+			continue
+		}
+		dir := filepath.Dir(path)
+		if _, ok := dirs[dir]; !ok {
+			order = append(order, dir)
+		}
+		dirs[dir] = append(dirs[dir], path)
+	}
+
+	for _, dir := range order {
+		if err := syncDir(dir); err != nil {
+			for _, path := range dirs[dir] {
+				errs = append(errs, &SyncError{Path: path, Err: err})
+			}
+		}
+	}
+	return errs
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// syncDir persists the directory entries of dir, such as a rename into it.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	// Some network filesystems can't sync directories
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package copier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func copyTask(t *testing.T, dir string) types.CopyTask {
+	t.Helper()
+	src := filepath.Join(dir, "src.jpg")
+	if err := os.WriteFile(src, []byte("image data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return types.CopyTask{
+		Source:   types.FileEntry{Path: src, Name: "src.jpg", Size: 10},
+		DestPath: filepath.Join(dir, "dest", "2025", "src.jpg"),
+		Action:   types.CopyActionCopied,
+	}
+}
+
+func TestCopier_PreserveModeAndTimes(t *testing.T) {
+	dir := t.TempDir()
+	task := copyTask(t, dir)
+
+	mtime := time.Date(2025, 12, 31, 10, 0, 0, 0, time.UTC)
+	atime := time.Date(2026, 1, 2, 8, 30, 0, 0, time.UTC)
+	os.Chtimes(task.Source.Path, atime, mtime)
+
+	c := New(1, false, false)
+	c.SetDurability(types.FsyncAlways, []string{PreserveMode, PreserveAtime})
+	if result := c.copyOne(task, newWorker(0, 0)); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	info, err := os.Stat(task.DestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode not preserved: %v", info.Mode())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime not preserved: %v", info.ModTime())
+	}
+	if got, ok := accessTime(info); ok && !got.Equal(atime) {
+		t.Errorf("atime not preserved: %v", got)
+	}
+}
+
+func TestCopier_FsyncBatch(t *testing.T) {
+	dir := t.TempDir()
+	task := copyTask(t, dir)
+
+	c := New(1, false, false)
+	c.SetDurability(types.FsyncBatch, nil)
+	if result := c.copyOne(task, newWorker(0, 0)); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if len(c.pending) != 1 {
+		t.Fatalf("expected one pending sync, got %v", c.pending)
+	}
+
+	// A file removed before the flush is reported
+	c.pending = append(c.pending, filepath.Join(dir, "missing.jpg"))
+	errs := c.Flush()
+	if len(errs) != 1 || errs[0].Path != filepath.Join(dir, "missing.jpg") {
+		t.Errorf("unexpected sync errors: %v", errs)
+	}
+	if len(c.pending) != 0 || len(c.Flush()) != 0 {
+		t.Error("flush should clear pending files and errors")
+	}
+}
+
+func TestValidateDurability(t *testing.T) {
+	if err := ValidateFsync("sometimes"); err == nil {
+		t.Error("expected error for unknown fsync policy")
+	}
+	if err := ValidatePreserve([]string{"owner"}); err == nil {
+		t.Error("expected error for unknown attribute")
+	}
+	if err := ValidatePreserve([]string{PreserveMode, PreserveAtime}); err != nil {
+		t.Error(err)
+	}
+}
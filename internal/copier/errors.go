@@ -0,0 +1,82 @@
+package copier
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// maxRetryDelay caps the exponential backoff.
+const maxRetryDelay = time.Minute
+
+// sourceError marks a failure reading the source file.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+// Classify returns the class of a copy failure. Errors that aren't
+// recognized count as source read or destination I/O errors depending on
+// the side they came from.
+func Classify(err error) types.ErrorClass {
+	var srcErr *sourceError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrCancelled):
+		return types.ErrorClassCancelled
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return types.ErrorClassNoSpace
+	case errors.Is(err, syscall.ENAMETOOLONG):
+		return types.ErrorClassPathTooLong
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.EROFS):
+		return types.ErrorClassPermission
+	case errors.As(err, &srcErr):
+		return types.ErrorClassSourceRead
+	}
+	return types.ErrorClassDestIO
+}
+
+// Retryable reports whether failures of class may be transient.
+func Retryable(class types.ErrorClass) bool {
+	return class == types.ErrorClassSourceRead || class == types.ErrorClassDestIO
+}
+
+// SetRetry sets how many times a file is attempted and the delay before the
+// first retry, which doubles for every further retry. Call it before
+// CopyAll.
+func (c *Copier) SetRetry(attempts int, delay time.Duration) {
+	c.retryAttempts = attempts
+	c.retryDelay = delay
+}
+
+// backoff returns the delay after the given failed attempt.
+func (c *Copier) backoff(attempt int) time.Duration {
+	delay := c.retryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// sleep waits for d and reports false if the copy was cancelled meanwhile.
+func (c *Copier) sleep(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if c.cancelled.Load() {
+			return false
+		}
+		time.Sleep(min(100*time.Millisecond, time.Until(deadline)))
+	}
+	return !c.cancelled.Load()
+}
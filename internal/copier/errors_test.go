@@ -0,0 +1,111 @@
+package copier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want types.ErrorClass
+	}{
+		{&sourceError{syscall.EIO}, types.ErrorClassSourceRead},
+		{syscall.EIO, types.ErrorClassDestIO},
+		{fmt.Errorf("write: %w", syscall.ENOSPC), types.ErrorClassNoSpace},
+		{&os.PathError{Op: "open", Path: "/nas/a.jpg", Err: syscall.EACCES}, types.ErrorClassPermission},
+		{&sourceError{syscall.EACCES}, types.ErrorClassPermission},
+		{syscall.EROFS, types.ErrorClassPermission},
+		{&os.LinkError{Op: "rename", Err: syscall.ENAMETOOLONG}, types.ErrorClassPathTooLong},
+		{ErrCancelled, types.ErrorClassCancelled},
+		{errors.New("boom"), types.ErrorClassDestIO},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCopier_RetriesTransientErrors(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "late.jpg")
+	task := types.CopyTask{
+		Source:   types.FileEntry{Path: src, Name: "late.jpg"},
+		DestPath: filepath.Join(dir, "dest", "late.jpg"),
+		Action:   types.CopyActionCopied,
+	}
+
+	// The source shows up while the copier backs off after the first try
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(src, []byte("data"), 0644)
+	}()
+
+	c := New(1, false, false)
+	c.SetRetry(3, 300*time.Millisecond)
+	result := c.copyOne(task, newWorker(0, 0))
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if result.Task.Attempts != 2 {
+		t.Errorf("expected success on attempt 2, got %d", result.Task.Attempts)
+	}
+}
+
+func TestCopier_GivesUpAfterAttemptLimit(t *testing.T) {
+	dir := t.TempDir()
+	task := types.CopyTask{
+		Source:   types.FileEntry{Path: filepath.Join(dir, "missing.jpg"), Name: "missing.jpg"},
+		DestPath: filepath.Join(dir, "dest", "missing.jpg"),
+	}
+
+	c := New(1, false, false)
+	c.SetRetry(3, time.Millisecond)
+	result := c.copyOne(task, newWorker(0, 0))
+	if result.Error == nil {
+		t.Fatal("expected error")
+	}
+	if result.Task.Attempts != 3 || result.Task.ErrorClass != types.ErrorClassSourceRead {
+		t.Errorf("unexpected result: attempts=%d class=%s", result.Task.Attempts, result.Task.ErrorClass)
+	}
+}
+
+func TestCopier_NoRetryForPermanentErrors(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+	dir := t.TempDir()
+	task := copyTask(t, dir)
+	os.MkdirAll(filepath.Dir(task.DestPath), 0755)
+	os.Chmod(filepath.Dir(task.DestPath), 0555)
+	defer os.Chmod(filepath.Dir(task.DestPath), 0755)
+
+	c := New(1, false, false)
+	c.SetRetry(3, time.Second)
+	result := c.copyOne(task, newWorker(0, 0))
+	if result.Task.ErrorClass != types.ErrorClassPermission || result.Task.Attempts != 1 {
+		t.Errorf("unexpected result: attempts=%d class=%s", result.Task.Attempts, result.Task.ErrorClass)
+	}
+}
+
+func TestCopier_Backoff(t *testing.T) {
+	c := New(1, false, false)
+	c.SetRetry(10, time.Second)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for i, d := range want {
+		if got := c.backoff(i + 1); got != d {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, d)
+		}
+	}
+	if got := c.backoff(20); got != maxRetryDelay {
+		t.Errorf("backoff should be capped, got %s", got)
+	}
+}
@@ -0,0 +1,89 @@
+package copier
+
+import (
+	"errors"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// copyRangeChunk is how much one copy_file_range call may copy, so the
+// limiters can still pace kernel-side copies.
+const copyRangeChunk = 8 << 20
+
+var errNoFastPath = errors.New("fast copy not supported")
+
+// SetBufferSize sets the buffer size for buffered copies. Call it before
+// CopyAll.
+func (c *Copier) SetBufferSize(size int) {
+	c.bufferSize = size
+}
+
+// copyData copies src to dst, trying a reflink first, then copy_file_range,
+// and only then a buffered copy through user space. copy_file_range is
+// skipped when h needs the content. It returns the mechanism that was used.
+func (c *Copier) copyData(dst, src *os.File, h hash.Hash, w *worker) (types.CopyMethod, error) {
+	limiters := []*Limiter{c.global, w.limiter}
+
+	if err := reflink(dst, src); err == nil {
+		// Shared extents cost no bandwidth, only the hash needs the data
+		if h != nil {
+			if _, err := io.Copy(h, src); err != nil {
+				return types.CopyMethodReflink, &sourceError{err}
+			}
+		}
+		if info, err := dst.Stat(); err == nil {
+			c.count(w, info.Size())
+		}
+		return types.CopyMethodReflink, nil
+	} else if !fastPathUnsupported(err) {
+		return types.CopyMethodReflink, err
+	}
+
+	if h == nil {
+		copied := false
+		for {
+			n, err := copyFileRange(dst, src, copyRangeChunk)
+			if err != nil {
+				// Finish a partial copy from the current offsets
+				if fastPathUnsupported(err) {
+					break
+				}
+				return types.CopyMethodCopyFileRange, err
+			}
+			if n == 0 {
+				if copied {
+					return types.CopyMethodCopyFileRange, nil
+				}
+				// Some filesystems report 0 instead of an error, so let
+				// the buffered copy make sure nothing is left
+				break
+			}
+			copied = true
+			for _, l := range limiters {
+				l.WaitN(n)
+			}
+			c.count(w, int64(n))
+		}
+	}
+
+	size := c.bufferSize
+	if size <= 0 {
+		size = types.DefaultCopyBufferSize
+	}
+
+	var out io.Writer = writerOnly{dst}
+	if h != nil {
+		out = io.MultiWriter(dst, h)
+	}
+	r := &throttledReader{r: src, limiters: limiters, counters: c.counters(w)}
+	_, err := io.CopyBuffer(out, r, make([]byte, size))
+	return types.CopyMethodBuffered, err
+}
+
+// writerOnly hides os.File's ReadFrom so io.CopyBuffer uses our buffer.
+type writerOnly struct {
+	io.Writer
+}
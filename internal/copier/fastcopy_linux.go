@@ -0,0 +1,85 @@
+package copier
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// The syscall package doesn't define these, so they are listed per
+// architecture. Unknown architectures use the buffered copy.
+var (
+	sysCopyFileRange = map[string]uintptr{
+		"amd64":    326,
+		"386":      377,
+		"arm":      391,
+		"arm64":    285,
+		"riscv64":  285,
+		"loong64":  285,
+		"ppc64":    379,
+		"ppc64le":  379,
+		"s390x":    375,
+		"mips":     4360,
+		"mipsle":   4360,
+		"mips64":   5320,
+		"mips64le": 5320,
+	}[runtime.GOARCH]
+
+	// FICLONE is _IOW(0x94, 9, int); the direction bits differ on PowerPC
+	// and MIPS.
+	ficlone = map[string]uintptr{
+		"ppc64":    0x80049409,
+		"ppc64le":  0x80049409,
+		"mips":     0x80049409,
+		"mipsle":   0x80049409,
+		"mips64":   0x80049409,
+		"mips64le": 0x80049409,
+	}[runtime.GOARCH]
+)
+
+func init() {
+	if ficlone == 0 {
+		ficlone = 0x40049409
+	}
+}
+
+// reflink makes dst share src's extents (Btrfs, XFS, bcachefs, ...).
+func reflink(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// copyFileRange copies up to n bytes from the current offset of src to the
+// current offset of dst inside the kernel.
+func copyFileRange(dst, src *os.File, n int) (int, error) {
+	if sysCopyFileRange == 0 {
+		return 0, errNoFastPath
+	}
+	for {
+		r, _, errno := syscall.Syscall6(sysCopyFileRange, src.Fd(), 0, dst.Fd(), 0, uintptr(n), 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+		return int(r), nil
+	}
+}
+
+// fastPathUnsupported reports whether err means the fast path can't be used
+// for these files, as opposed to an I/O error.
+func fastPathUnsupported(err error) bool {
+	return errors.Is(err, errNoFastPath) ||
+		errors.Is(err, syscall.EXDEV) ||
+		errors.Is(err, syscall.ENOSYS) ||
+		errors.Is(err, syscall.EOPNOTSUPP) ||
+		errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOTTY) ||
+		errors.Is(err, syscall.EBADF) ||
+		errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package copier
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(dst, src *os.File) error {
+	return errNoFastPath
+}
+
+func copyFileRange(dst, src *os.File, n int) (int, error) {
+	return 0, errNoFastPath
+}
+
+func fastPathUnsupported(err error) bool {
+	return errors.Is(err, errNoFastPath)
+}
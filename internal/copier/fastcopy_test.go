@@ -0,0 +1,73 @@
+package copier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func writeRandom(t *testing.T, path string, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.Read(data)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestAtomicCopy_FastPath(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "clip.mp4")
+	data := writeRandom(t, src, 3<<20+17)
+
+	c := New(1, false, false)
+	dest := filepath.Join(dir, "out.mp4")
+	method, err := c.atomicCopy(src, dest+".part", dest, nil, newWorker(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method == "" {
+		t.Error("copy method not reported")
+	}
+
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Errorf("content mismatch after %s copy", method)
+	}
+	if c.meter.bytes.Load() != int64(len(data)) {
+		t.Errorf("counted %d bytes after %s copy", c.meter.bytes.Load(), method)
+	}
+}
+
+func TestAtomicCopy_HashSkipsCopyFileRange(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.jpg")
+	data := writeRandom(t, src, 100<<10)
+
+	c := New(1, false, true)
+	c.SetBufferSize(4096)
+	dest := filepath.Join(dir, "b.jpg")
+	h := sha256.New()
+	method, err := c.atomicCopy(src, dest+".part", dest, h, newWorker(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method == types.CopyMethodCopyFileRange {
+		t.Error("copy_file_range can't feed the hash")
+	}
+
+	if want := fmt.Sprintf("%x", sha256.Sum256(data)); fmt.Sprintf("%x", h.Sum(nil)) != want {
+		t.Errorf("hash mismatch after %s copy", method)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Errorf("content mismatch after %s copy", method)
+	}
+}
@@ -0,0 +1,77 @@
+package copier
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// worker is a copy goroutine: its bandwidth limiter and the file it is
+// copying.
+type worker struct {
+	id      int
+	limiter *Limiter
+	meter   rateMeter
+
+	mu   sync.Mutex
+	task *types.CopyTask
+	// done counts the bytes of the current attempt.
+	done atomic.Int64
+}
+
+func newWorker(id int, limit int64) *worker {
+	return &worker{id: id, limiter: NewLimiter(limit)}
+}
+
+// begin marks task as the file in progress, or the worker idle for nil.
+func (w *worker) begin(task *types.CopyTask) {
+	w.mu.Lock()
+	w.task = task
+	w.mu.Unlock()
+	w.done.Store(0)
+}
+
+// progress returns the file in progress, if any.
+func (w *worker) progress() (types.FileProgress, bool) {
+	w.mu.Lock()
+	task := w.task
+	w.mu.Unlock()
+	if task == nil {
+		return types.FileProgress{}, false
+	}
+	return types.FileProgress{
+		Worker:     w.id + 1,
+		Filename:   task.Source.Name,
+		Size:       task.Source.Size,
+		Done:       min(w.done.Load(), task.Source.Size),
+		Throughput: w.meter.value(),
+	}, true
+}
+
+// counters are the byte counters a copy by w adds to.
+func (c *Copier) counters(w *worker) []*atomic.Int64 {
+	return []*atomic.Int64{&c.meter.bytes, &w.meter.bytes, &w.done}
+}
+
+// count adds n copied bytes to the counters of w.
+func (c *Copier) count(w *worker, n int64) {
+	for _, counter := range c.counters(w) {
+		counter.Add(n)
+	}
+}
+
+// Progress returns the files being copied, in worker order.
+func (c *Copier) Progress() []types.FileProgress {
+	c.mu.Lock()
+	workers := c.workerList
+	c.mu.Unlock()
+
+	files := make([]types.FileProgress, 0, len(workers))
+	for _, w := range workers {
+		if fp, ok := w.progress(); ok {
+			files = append(files, fp)
+		}
+	}
+	return files
+}
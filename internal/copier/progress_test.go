@@ -0,0 +1,53 @@
+package copier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestCopier_Progress(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "clip.mp4")
+	if err := os.WriteFile(src, make([]byte, 300<<10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task := types.CopyTask{
+		Source:   types.FileEntry{Path: src, Name: "clip.mp4", Size: 300 << 10},
+		DestPath: filepath.Join(dir, "dest", "clip.mp4"),
+		Action:   types.CopyActionCopied,
+	}
+
+	// Hashing keeps the copy in user space, where the per-worker limit
+	// paces it in small steps
+	c := New(1, false, true)
+	c.SetBufferSize(16 << 10)
+	c.SetBandwidth(types.BandwidthConfig{PerWorker: 100 << 10})
+
+	results := make(chan CopyResult, 1)
+	go c.CopyAll([]types.CopyTask{task}, results)
+
+	var seen types.FileProgress
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if files := c.Progress(); len(files) == 1 && files[0].Done > 0 && files[0].Done < files[0].Size {
+			seen = files[0]
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if seen.Filename != "clip.mp4" || seen.Worker != 1 || seen.Size != 300<<10 {
+		t.Errorf("in-flight progress = %+v", seen)
+	}
+
+	result := <-results
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if files := c.Progress(); len(files) != 0 {
+		t.Errorf("progress after copy = %+v", files)
+	}
+}
@@ -0,0 +1,162 @@
+package copier
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Limiter is a token bucket capping throughput in bytes per second. A limit
+// of zero or less means unlimited. It allows bursts of up to one second.
+type Limiter struct {
+	mu     sync.Mutex
+	limit  int64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter with the given bytes/sec limit.
+func NewLimiter(limit int64) *Limiter {
+	return &Limiter{limit: limit, last: time.Now()}
+}
+
+// SetLimit changes the limit. Waiting readers pick it up on their next read.
+func (l *Limiter) SetLimit(limit int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit != l.limit {
+		l.limit = limit
+		l.tokens = 0
+		l.last = time.Now()
+	}
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// WaitN blocks until n bytes may pass.
+func (l *Limiter) WaitN(n int) {
+	l.mu.Lock()
+	if l.limit <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	rate := float64(l.limit)
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*rate, rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// throttledReader passes reads through the limiters and adds the bytes to
+// the counters.
+type throttledReader struct {
+	r        io.Reader
+	limiters []*Limiter
+	counters []*atomic.Int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		for _, l := range t.limiters {
+			l.WaitN(n)
+		}
+		for _, counter := range t.counters {
+			counter.Add(int64(n))
+		}
+	}
+	if err != nil && err != io.EOF {
+		err = &sourceError{err}
+	}
+	return n, err
+}
+
+// rateMeter tracks smoothed throughput from a byte counter sampled every
+// second.
+type rateMeter struct {
+	bytes atomic.Int64
+	rate  atomic.Uint64
+	last  int64
+}
+
+// sample updates the rate from the bytes counted since the last sample.
+func (m *rateMeter) sample(elapsed time.Duration) {
+	total := m.bytes.Load()
+	current := float64(total-m.last) / elapsed.Seconds()
+	m.last = total
+
+	prev := math.Float64frombits(m.rate.Load())
+	m.rate.Store(math.Float64bits(0.5*prev + 0.5*current))
+}
+
+func (m *rateMeter) value() float64 {
+	return math.Float64frombits(m.rate.Load())
+}
+
+// ScheduledLimit returns the global limit that applies at t: the limit of
+// the first matching schedule window, or cfg.Limit.
+func ScheduledLimit(cfg types.BandwidthConfig, t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range cfg.Schedule {
+		start, err1 := parseClock(w.Start)
+		end, err2 := parseClock(w.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if start <= end {
+			if minute >= start && minute < end {
+				return w.Limit
+			}
+		} else if minute >= start || minute < end {
+			return w.Limit
+		}
+	}
+	return cfg.Limit
+}
+
+// ValidateBandwidth checks limits and schedule times.
+func ValidateBandwidth(cfg types.BandwidthConfig) error {
+	if cfg.Limit < 0 || cfg.PerWorker < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	for _, w := range cfg.Schedule {
+		if _, err := parseClock(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return err
+		}
+		if w.Limit < 0 {
+			return fmt.Errorf("limits must not be negative")
+		}
+	}
+	return nil
+}
+
+// parseClock returns minutes since midnight for "HH:MM".
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,85 @@
+package copier
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestLimiter_Throttles(t *testing.T) {
+	l := NewLimiter(100 << 10)
+	var counter atomic.Int64
+	r := &throttledReader{r: bytes.NewReader(make([]byte, 150<<10)), limiters: []*Limiter{l}, counters: []*atomic.Int64{&counter}}
+
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	// 150 KiB at 100 KiB/s with an empty bucket takes about 1.5s
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("copy finished too fast: %s", elapsed)
+	}
+	if counter.Load() != 150<<10 {
+		t.Errorf("counted %d bytes", counter.Load())
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := NewLimiter(1)
+	l.SetLimit(0)
+
+	start := time.Now()
+	l.WaitN(1 << 30)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("unlimited limiter should not wait")
+	}
+}
+
+func TestScheduledLimit(t *testing.T) {
+	cfg := types.BandwidthConfig{
+		Limit: 10,
+		Schedule: []types.BandwidthWindow{
+			{Start: "20:00", End: "07:00", Limit: 0},
+			{Start: "12:00", End: "13:00", Limit: 50},
+		},
+	}
+	at := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return t
+	}
+
+	tests := map[string]int64{
+		"09:00": 10,
+		"12:30": 50,
+		"13:00": 10,
+		"20:00": 0,
+		"23:59": 0,
+		"06:59": 0,
+		"07:00": 10,
+	}
+	for clock, want := range tests {
+		if got := ScheduledLimit(cfg, at(clock)); got != want {
+			t.Errorf("ScheduledLimit at %s = %d, want %d", clock, got, want)
+		}
+	}
+}
+
+func TestValidateBandwidth(t *testing.T) {
+	bad := []types.BandwidthConfig{
+		{Limit: -1},
+		{Schedule: []types.BandwidthWindow{{Start: "8am", End: "20:00"}}},
+		{Schedule: []types.BandwidthWindow{{Start: "08:00", End: "24:30"}}},
+	}
+	for _, cfg := range bad {
+		if err := ValidateBandwidth(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+	if err := ValidateBandwidth(types.BandwidthConfig{Limit: 1 << 20}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package history stores a record of every pipeline run: the config it ran
+// with, its summary and the outcome of each file.
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// FileResult is the outcome of one file in a run.
+type FileResult struct {
+	Source         string           `json:"source"`
+	Size           int64            `json:"size"`
+	Dest           string           `json:"dest,omitempty"`
+	Action         types.CopyAction `json:"action"`
+	Status         types.TaskStatus `json:"status"`
+	Error          string           `json:"error,omitempty"`
+	CaptureTime    *time.Time       `json:"capture_time,omitempty"`
+	MetadataSource string           `json:"metadata_source,omitempty"`
+	MetadataError  string           `json:"metadata_error,omitempty"`
+	ConflictPath   string           `json:"conflict_path,omitempty"`
+	ConflictReason string           `json:"conflict_reason,omitempty"`
+	Hash           string           `json:"hash,omitempty"`
+	Method         types.CopyMethod `json:"method,omitempty"`
+	ErrorClass     types.ErrorClass `json:"error_class,omitempty"`
+	Attempts       int              `json:"attempts,omitempty"`
+}
+
+// ResultFromTask converts a finished copy task into a FileResult.
+func ResultFromTask(task types.CopyTask) FileResult {
+	return FileResult{
+		Source:         task.Source.Path,
+		Size:           task.Source.Size,
+		Dest:           task.DestPath,
+		Action:         task.Action,
+		Status:         task.Status,
+		Error:          task.Error,
+		CaptureTime:    task.Metadata.CaptureTime,
+		MetadataSource: task.Metadata.Source,
+		MetadataError:  task.Metadata.Error,
+		ConflictPath:   task.ConflictPath,
+		ConflictReason: task.ConflictReason,
+		Hash:           task.Hash,
+		Method:         task.Method,
+		ErrorClass:     task.ErrorClass,
+		Attempts:       task.Attempts,
+	}
+}
+
+// Run is the index entry for a run.
+type Run struct {
+	ID      string           `json:"id"`
+	Source  string           `json:"source"`
+	Dest    string           `json:"dest"`
+	DryRun  bool             `json:"dry_run"`
+	Summary types.RunSummary `json:"summary"`
+}
+
+// Record is a complete run with its config snapshot and file results.
+type Record struct {
+	Run
+	Config config.Config `json:"config"`
+	Files  []FileResult  `json:"files"`
+}
+
+// Filter selects file results. Empty fields match everything.
+type Filter struct {
+	Action types.CopyAction
+	// Error matches files with an error containing this text, or any error
+	// if it is "*".
+	Error string
+}
+
+// Apply returns the results matching the filter.
+func (f Filter) Apply(files []FileResult) []FileResult {
+	out := make([]FileResult, 0, len(files))
+	for _, r := range files {
+		if f.Action != "" && r.Action != f.Action {
+			continue
+		}
+		if f.Error == "*" && r.Error == "" {
+			continue
+		}
+		if f.Error != "" && f.Error != "*" && !strings.Contains(strings.ToLower(r.Error), strings.ToLower(f.Error)) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Store keeps run records as one JSON file per run plus an index.
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// DefaultDir returns ~/.shutterpipe/runs.
+func DefaultDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".shutterpipe", "runs")
+}
+
+// NewStore creates a store in dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the directory the store writes to.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// NewID returns a sortable run ID such as "20251231-153000-1a2b".
+func NewID(t time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// Save writes a record and adds it to the index.
+func (s *Store) Save(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec.ID == "" {
+		rec.ID = NewID(rec.Summary.StartTime)
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	if err := writeJSON(filepath.Join(s.dir, rec.ID+".json"), rec); err != nil {
+		return err
+	}
+
+	runs, err := s.readIndex()
+	if err != nil {
+		return err
+	}
+	runs = append(runs, rec.Run)
+	return writeJSON(s.indexPath(), runs)
+}
+
+// List returns all runs, newest first.
+func (s *Store) List() ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Summary.StartTime.After(runs[j].Summary.StartTime)
+	})
+	return runs, nil
+}
+
+// Get loads a full record.
+func (s *Store) Get(id string) (*Record, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, fmt.Errorf("invalid run id %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("run %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+func (s *Store) indexPath() string {
+	return filepath.Join(s.dir, "index.json")
+}
+
+func (s *Store) readIndex() ([]Run, error) {
+	data, err := os.ReadFile(s.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse run index: %w", err)
+	}
+	return runs, nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return nil
+}
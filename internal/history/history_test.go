@@ -0,0 +1,82 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestStore_SaveListGet(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	first := time.Date(2025, 12, 30, 10, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+
+	for _, start := range []time.Time{first, second} {
+		rec := &Record{
+			Run: Run{Source: "/card", Dest: "/nas", Summary: types.RunSummary{StartTime: start, Copied: 1}},
+			Files: []FileResult{
+				{Source: "/card/a.jpg", Action: types.CopyActionCopied, MetadataSource: "EXIF:DateTimeOriginal"},
+			},
+		}
+		if err := store.Save(rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.ID == "" {
+			t.Fatal("expected an ID to be assigned")
+		}
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(runs))
+	}
+	if !runs[0].Summary.StartTime.Equal(second) {
+		t.Errorf("expected newest run first, got %v", runs[0].Summary.StartTime)
+	}
+
+	rec, err := store.Get(runs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Files) != 1 || rec.Files[0].MetadataSource != "EXIF:DateTimeOriginal" {
+		t.Errorf("unexpected files: %+v", rec.Files)
+	}
+
+	if _, err := store.Get("../state"); err == nil {
+		t.Error("expected error for path traversal")
+	}
+	if _, err := store.Get("missing"); err == nil {
+		t.Error("expected error for unknown run")
+	}
+}
+
+func TestFilter_Apply(t *testing.T) {
+	files := []FileResult{
+		{Source: "a", Action: types.CopyActionCopied},
+		{Source: "b", Action: types.CopyActionSkipped},
+		{Source: "c", Action: types.CopyActionFailed, Error: "no space left on device"},
+		{Source: "d", Action: types.CopyActionFailed, Error: "permission denied"},
+	}
+
+	tests := []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{}, 4},
+		{Filter{Action: types.CopyActionSkipped}, 1},
+		{Filter{Error: "*"}, 2},
+		{Filter{Error: "SPACE"}, 1},
+		{Filter{Action: types.CopyActionCopied, Error: "*"}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := len(tt.filter.Apply(files)); got != tt.want {
+			t.Errorf("%+v: expected %d files, got %d", tt.filter, tt.want, got)
+		}
+	}
+}
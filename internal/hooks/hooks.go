@@ -0,0 +1,175 @@
+// Package hooks runs user commands around a pipeline run. Each command gets
+// a JSON payload describing the event on stdin.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Hook events.
+const (
+	PreRun   = "pre_run"
+	PostFile = "post_file"
+	PostRun  = "post_run"
+	OnError  = "on_error"
+)
+
+// DefaultTimeout applies to hooks without a timeout.
+const DefaultTimeout = 30 * time.Second
+
+// Validate checks that every hook has a command and a known failure policy.
+func Validate(cfg types.HooksConfig) error {
+	for event, hooks := range byEvent(cfg) {
+		for _, h := range hooks {
+			if strings.TrimSpace(h.Command) == "" {
+				return fmt.Errorf("%s hook has no command", event)
+			}
+			switch h.OnFailure {
+			case "", types.HookFailureContinue, types.HookFailureAbort:
+			default:
+				return fmt.Errorf("%s hook: unknown failure policy %q", event, h.OnFailure)
+			}
+		}
+	}
+	return nil
+}
+
+func byEvent(cfg types.HooksConfig) map[string][]types.Hook {
+	return map[string][]types.Hook{
+		PreRun:   cfg.PreRun,
+		PostFile: cfg.PostFile,
+		PostRun:  cfg.PostRun,
+		OnError:  cfg.OnError,
+	}
+}
+
+// Task is the JSON form of a copy task.
+type Task struct {
+	Source         string           `json:"source"`
+	Dest           string           `json:"dest"`
+	Size           int64            `json:"size"`
+	Action         types.CopyAction `json:"action"`
+	Status         types.TaskStatus `json:"status"`
+	Error          string           `json:"error,omitempty"`
+	ErrorClass     types.ErrorClass `json:"error_class,omitempty"`
+	CaptureTime    *time.Time       `json:"capture_time,omitempty"`
+	MetadataSource string           `json:"metadata_source,omitempty"`
+	IsVideo        bool             `json:"is_video"`
+	Hash           string           `json:"hash,omitempty"`
+}
+
+// NewTask converts a copy task for a payload.
+func NewTask(t *types.CopyTask) *Task {
+	return &Task{
+		Source:         t.Source.Path,
+		Dest:           t.DestPath,
+		Size:           t.Source.Size,
+		Action:         t.Action,
+		Status:         t.Status,
+		Error:          t.Error,
+		ErrorClass:     t.ErrorClass,
+		CaptureTime:    t.Metadata.CaptureTime,
+		MetadataSource: t.Metadata.Source,
+		IsVideo:        t.Source.IsVideo,
+		Hash:           t.Hash,
+	}
+}
+
+// Payload is written to the hook's stdin.
+type Payload struct {
+	Event   string            `json:"event"`
+	RunID   string            `json:"run_id,omitempty"`
+	Source  string            `json:"source"`
+	Dest    string            `json:"dest"`
+	DryRun  bool              `json:"dry_run"`
+	Task    *Task             `json:"task,omitempty"`
+	Summary *types.RunSummary `json:"summary,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Runner executes the configured hooks.
+type Runner struct {
+	cfg  types.HooksConfig
+	warn func(msg string, err error)
+}
+
+// NewRunner creates a runner. warn receives failures of hooks that don't
+// abort the run.
+func NewRunner(cfg types.HooksConfig, warn func(msg string, err error)) *Runner {
+	return &Runner{cfg: cfg, warn: warn}
+}
+
+// Has reports whether any hook is configured for event.
+func (r *Runner) Has(event string) bool {
+	return len(byEvent(r.cfg)[event]) > 0
+}
+
+// Fire runs the hooks for the payload's event in order. It returns an error
+// if a hook with the abort policy fails; other failures go to warn.
+func (r *Runner) Fire(payload Payload) error {
+	hooks := byEvent(r.cfg)[payload.Event]
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hooks {
+		if err := run(h, payload, data); err != nil {
+			err = fmt.Errorf("%s hook %q failed: %w", payload.Event, h.Command, err)
+			if h.OnFailure == types.HookFailureAbort {
+				return err
+			}
+			if r.warn != nil {
+				r.warn("Hook failed", err)
+			}
+		}
+	}
+	return nil
+}
+
+func run(h types.Hook, payload Payload, stdin []byte) error {
+	timeout := DefaultTimeout
+	if h.TimeoutSec > 0 {
+		timeout = time.Duration(h.TimeoutSec) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(),
+		"SHUTTERPIPE_EVENT="+payload.Event,
+		"SHUTTERPIPE_RUN_ID="+payload.RunID,
+	)
+	// Don't let a backgrounded child holding stdout keep us past the timeout
+	cmd.WaitDelay = time.Second
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
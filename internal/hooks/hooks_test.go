@@ -0,0 +1,97 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestRunner_Fire_Payload(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "payload.json")
+	r := NewRunner(types.HooksConfig{
+		PostFile: []types.Hook{{Command: `cat > "` + out + `"; echo "$SHUTTERPIPE_EVENT" >> "` + out + `.event"`}},
+	}, nil)
+
+	task := &types.CopyTask{
+		Source:   types.FileEntry{Path: "/card/a.jpg", Size: 42},
+		DestPath: "/nas/2025/12/31/a.jpg",
+		Action:   types.CopyActionCopied,
+	}
+	if err := r.Fire(Payload{Event: PostFile, RunID: "run1", Task: NewTask(task)}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Payload
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RunID != "run1" || got.Task == nil || got.Task.Dest != "/nas/2025/12/31/a.jpg" || got.Task.Size != 42 {
+		t.Errorf("unexpected payload: %s", data)
+	}
+
+	event, _ := os.ReadFile(out + ".event")
+	if strings.TrimSpace(string(event)) != PostFile {
+		t.Errorf("expected SHUTTERPIPE_EVENT=%s, got %q", PostFile, event)
+	}
+}
+
+func TestRunner_Fire_FailurePolicies(t *testing.T) {
+	var warned []error
+	warn := func(msg string, err error) { warned = append(warned, err) }
+
+	r := NewRunner(types.HooksConfig{
+		PreRun: []types.Hook{{Command: "echo boom >&2; exit 3"}},
+		PostRun: []types.Hook{
+			{Command: "exit 1", OnFailure: types.HookFailureAbort},
+			{Command: "touch should-not-run"},
+		},
+	}, warn)
+
+	if err := r.Fire(Payload{Event: PreRun}); err != nil {
+		t.Errorf("continue policy should not return an error: %v", err)
+	}
+	if len(warned) != 1 || !strings.Contains(warned[0].Error(), "boom") {
+		t.Errorf("expected warning with stderr, got %v", warned)
+	}
+
+	if err := r.Fire(Payload{Event: PostRun}); err == nil {
+		t.Error("expected abort policy to return an error")
+	}
+	if _, err := os.Stat("should-not-run"); err == nil {
+		os.Remove("should-not-run")
+		t.Error("hooks after an aborting hook should not run")
+	}
+}
+
+func TestRunner_Fire_Timeout(t *testing.T) {
+	r := NewRunner(types.HooksConfig{
+		OnError: []types.Hook{{Command: "sleep 5", TimeoutSec: 1, OnFailure: types.HookFailureAbort}},
+	}, nil)
+
+	start := time.Now()
+	err := r.Fire(Payload{Event: OnError})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Error("hook was not killed at the timeout")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(types.HooksConfig{PostRun: []types.Hook{{Command: "true", OnFailure: "explode"}}}); err == nil {
+		t.Error("expected error for unknown failure policy")
+	}
+	if err := Validate(types.HooksConfig{PreRun: []types.Hook{{Command: " "}}}); err == nil {
+		t.Error("expected error for empty command")
+	}
+}
@@ -0,0 +1,213 @@
+// Package journal records the filesystem changes a run makes to the
+// destination so the run can be undone.
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op is the kind of change recorded.
+type Op string
+
+const (
+	// OpMkdir is a directory created by the run.
+	OpMkdir Op = "mkdir"
+	// OpCreate is a new file.
+	OpCreate Op = "create"
+	// OpOverwrite is a file that replaced an existing one.
+	OpOverwrite Op = "overwrite"
+	// OpQuarantine is a file written to the quarantine directory.
+	OpQuarantine Op = "quarantine"
+)
+
+// Entry is one recorded change.
+type Entry struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+	// Source is the source file, used to revert its state entry.
+	Source string `json:"source,omitempty"`
+	// Size, ModTime and Hash describe the file as the run left it.
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+	// Backup is a hard link to or a copy of the overwritten file.
+	Backup string    `json:"backup,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// link creates the hard link of a backup; tests replace it.
+var link = os.Link
+
+// Journal appends entries to a JSON-lines file as they happen, so a crash
+// mid-run still leaves a usable journal.
+type Journal struct {
+	mu        sync.Mutex
+	file      *os.File
+	enc       *json.Encoder
+	root      string
+	backupDir string
+}
+
+// Path returns the journal file of a run in the history directory.
+func Path(historyDir, runID string) string {
+	return filepath.Join(historyDir, runID+".journal")
+}
+
+// Create opens a new journal. Overwritten files under root are backed up
+// into backupDir, which must be on the same filesystem as root.
+func Create(path, root, backupDir string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{file: f, enc: json.NewEncoder(f), root: root, backupDir: backupDir}, nil
+}
+
+// Record appends an entry.
+func (j *Journal) Record(e Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	return j.enc.Encode(e)
+}
+
+// RecordDirs records the directories MkdirAll(dir) is about to create,
+// outermost first.
+func (j *Journal) RecordDirs(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := j.Record(Entry{Op: OpMkdir, Path: missing[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup preserves a file that is about to be overwritten. It is hard-linked
+// into the backup directory, or copied there where hard links aren't
+// supported (e.g. exFAT or SMB). The file must not be overwritten if this
+// fails, as undo could not restore it.
+func (j *Journal) Backup(path string) (string, error) {
+	rel, err := filepath.Rel(j.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	backup := filepath.Join(j.backupDir, rel)
+
+	if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+		return "", err
+	}
+	if err := link(path, backup); err == nil {
+		return backup, nil
+	}
+	if err := copyFile(path, backup); err != nil {
+		os.Remove(backup)
+		return "", err
+	}
+	return backup, nil
+}
+
+// copyFile copies src to dst with its mode and modification time, so the
+// restored file looks like the one that was overwritten.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Load reads all entries of a journal.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A crash can leave a truncated last line
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// HashFile returns the SHA-256 of a file.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
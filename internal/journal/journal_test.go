@@ -0,0 +1,183 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// copyWithJournal mimics the copier: record dirs, back up, write, record.
+func copyWithJournal(t *testing.T, j *Journal, path, content string) {
+	t.Helper()
+
+	if err := j.RecordDirs(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := Entry{Op: OpCreate, Path: path, Source: "/card/" + filepath.Base(path)}
+	if _, err := os.Stat(path); err == nil {
+		backup, err := j.Backup(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Op, e.Backup = OpOverwrite, backup
+	}
+
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ := os.Stat(path)
+	e.Size, e.ModTime = info.Size(), info.ModTime()
+	if err := j.Record(e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	dest := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "run.journal")
+	backupDir := BackupDir(dest, "run")
+
+	existing := filepath.Join(dest, "2025", "a.jpg")
+	os.MkdirAll(filepath.Dir(existing), 0755)
+	os.WriteFile(existing, []byte("original"), 0644)
+
+	j, err := Create(journalPath, dest, backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dest, "2025", "12", "31", "b.jpg")
+	modified := filepath.Join(dest, "2025", "12", "31", "c.jpg")
+	copyWithJournal(t, j, existing, "new content")
+	copyWithJournal(t, j, created, "b")
+	copyWithJournal(t, j, modified, "c")
+	j.Close()
+
+	// Edited by the user after the run
+	os.WriteFile(modified, []byte("edited"), 0644)
+
+	entries, err := Load(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Undo(entries, backupDir)
+
+	if data, _ := os.ReadFile(existing); string(data) != "original" {
+		t.Errorf("expected overwritten file to be restored, got %q", data)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("expected created file to be removed")
+	}
+	if _, err := os.Stat(modified); err != nil {
+		t.Error("expected modified file to be kept")
+	}
+	if len(res.Refused) != 1 || res.Refused[0].Path != modified {
+		t.Errorf("expected modified file to be refused, got %+v", res.Refused)
+	}
+	if len(res.Reverted) != 2 {
+		t.Errorf("expected 2 reverted sources, got %v", res.Reverted)
+	}
+
+	// "12/31" still holds the kept file; nothing left in the backup dir
+	if _, err := os.Stat(filepath.Join(dest, "2025", "12", "31")); err != nil {
+		t.Error("expected non-empty directory to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(dest, BackupDirName)); !os.IsNotExist(err) {
+		t.Error("expected backup directory to be cleaned up")
+	}
+}
+
+func TestUndo_RemovesCreatedDirs(t *testing.T) {
+	dest := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "run.journal")
+
+	j, err := Create(journalPath, dest, BackupDir(dest, "run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyWithJournal(t, j, filepath.Join(dest, "2025", "12", "31", "a.jpg"), "a")
+	j.Close()
+
+	entries, _ := Load(journalPath)
+	res := Undo(entries, "")
+
+	if len(res.Dirs) != 3 {
+		t.Errorf("expected 3 directories removed, got %v", res.Dirs)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "2025")); !os.IsNotExist(err) {
+		t.Error("expected created directories to be removed")
+	}
+}
+
+func TestBackup_CopiesWithoutHardLinks(t *testing.T) {
+	link = func(string, string) error { return &os.LinkError{Op: "link", Err: os.ErrPermission} }
+	defer func() { link = os.Link }()
+
+	dest := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "run.journal")
+	backupDir := BackupDir(dest, "run")
+
+	existing := filepath.Join(dest, "2025", "a.jpg")
+	os.MkdirAll(filepath.Dir(existing), 0755)
+	os.WriteFile(existing, []byte("original"), 0644)
+	mtime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(existing, mtime, mtime)
+
+	j, err := Create(journalPath, dest, backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyWithJournal(t, j, existing, "new content")
+	j.Close()
+
+	entries, _ := Load(journalPath)
+	res := Undo(entries, backupDir)
+
+	if len(res.Restored) != 1 {
+		t.Fatalf("expected the copied backup to be restored, got %+v", res)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "original" {
+		t.Errorf("restored %q", data)
+	}
+	if info, _ := os.Stat(existing); !info.ModTime().Equal(mtime) {
+		t.Errorf("restored mtime %s", info.ModTime())
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dest := t.TempDir()
+	old := BackupDir(dest, "old")
+	recent := BackupDir(dest, "recent")
+	for _, dir := range []string{old, recent} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("backup"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PruneBackups(dest, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != old {
+		t.Errorf("removed = %v, want [%s]", removed, old)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent backup was removed: %v", err)
+	}
+}
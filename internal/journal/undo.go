@@ -0,0 +1,183 @@
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// BackupDirName is the directory inside the destination holding backups of
+// overwritten files, one subdirectory per run.
+const BackupDirName = ".shutterpipe-undo"
+
+// BackupDir returns the backup directory of a run.
+func BackupDir(dest, runID string) string {
+	return filepath.Join(dest, BackupDirName, runID)
+}
+
+// PruneBackups removes the run backup directories under dest that were
+// created more than maxAge ago and returns their paths. Undo of those runs
+// can no longer restore overwritten files.
+func PruneBackups(dest string, maxAge time.Duration) ([]string, error) {
+	root := filepath.Join(dest, BackupDirName)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !e.IsDir() || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, dir)
+	}
+	// Fails, as intended, while other runs still have backups
+	os.Remove(root)
+	return removed, nil
+}
+
+// Refusal is a change that undo left in place.
+type Refusal struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// UndoResult describes what Undo changed.
+type UndoResult struct {
+	Removed  []string  `json:"removed"`
+	Restored []string  `json:"restored"`
+	Dirs     []string  `json:"dirs"`
+	Refused  []Refusal `json:"refused"`
+	// Reverted lists the sources whose copies were undone. Their state
+	// entries should be removed so they are copied again next run.
+	Reverted []string `json:"reverted"`
+	// Quarantined lists undone quarantine files.
+	Quarantined []string `json:"quarantined"`
+}
+
+// Undo reverses the journal entries, newest first. Files that changed since
+// the run are refused rather than deleted. Created directories are removed
+// if they are empty afterwards.
+func Undo(entries []Entry, backupDir string) *UndoResult {
+	res := &UndoResult{}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		switch e.Op {
+		case OpCreate, OpQuarantine:
+			if err := checkUnchanged(e); err != nil {
+				if os.IsNotExist(err) {
+					res.Reverted = appendSource(res.Reverted, e.Source)
+					continue
+				}
+				res.Refused = append(res.Refused, Refusal{Path: e.Path, Reason: err.Error()})
+				continue
+			}
+			if err := os.Remove(e.Path); err != nil {
+				res.Refused = append(res.Refused, Refusal{Path: e.Path, Reason: err.Error()})
+				continue
+			}
+			res.Removed = append(res.Removed, e.Path)
+			res.Reverted = appendSource(res.Reverted, e.Source)
+			if e.Op == OpQuarantine {
+				res.Quarantined = append(res.Quarantined, e.Path)
+			}
+
+		case OpOverwrite:
+			if e.Backup == "" {
+				res.Refused = append(res.Refused, Refusal{Path: e.Path, Reason: "overwritten file was not backed up"})
+				continue
+			}
+			if _, err := os.Stat(e.Backup); err != nil {
+				res.Refused = append(res.Refused, Refusal{Path: e.Path, Reason: "backup missing: " + e.Backup})
+				continue
+			}
+			if err := checkUnchanged(e); err != nil && !os.IsNotExist(err) {
+				res.Refused = append(res.Refused, Refusal{Path: e.Path, Reason: err.Error()})
+				continue
+			}
+			if err := os.Rename(e.Backup, e.Path); err != nil {
+				res.Refused = append(res.Refused, Refusal{Path: e.Path, Reason: err.Error()})
+				continue
+			}
+			res.Restored = append(res.Restored, e.Path)
+			res.Reverted = appendSource(res.Reverted, e.Source)
+		}
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Op != OpMkdir {
+			continue
+		}
+		// Fails, as intended, if anything else was put in the directory
+		if err := os.Remove(entries[i].Path); err == nil {
+			res.Dirs = append(res.Dirs, entries[i].Path)
+		}
+	}
+
+	if backupDir != "" {
+		removeEmptyDirs(backupDir)
+		os.Remove(filepath.Dir(backupDir))
+	}
+
+	return res
+}
+
+// checkUnchanged returns an error if the file differs from how the run left
+// it. A missing file is reported with an os.IsNotExist error.
+func checkUnchanged(e Entry) error {
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return err
+	}
+	if info.Size() != e.Size {
+		return fmt.Errorf("modified after the run (size %d, expected %d)", info.Size(), e.Size)
+	}
+	if !info.ModTime().Equal(e.ModTime) {
+		return fmt.Errorf("modified after the run (mtime %s)", info.ModTime().Format("2006-01-02 15:04:05"))
+	}
+	if e.Hash != "" {
+		hash, err := HashFile(e.Path)
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fmt.Errorf("modified after the run (hash mismatch)")
+		}
+	}
+	return nil
+}
+
+func appendSource(sources []string, source string) []string {
+	if source == "" {
+		return sources
+	}
+	return append(sources, source)
+}
+
+// removeEmptyDirs removes empty directories under root, deepest first.
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs {
+		os.Remove(d)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +19,9 @@ type Logger struct {
 	file    *os.File
 	logJSON bool
 	logText bool
+	// lineLen is the length of the progress line on the console, so a
+	// shorter one can blank it out.
+	lineLen int
 }
 
 func New(logFilePath string, logJSON, logText bool) (*Logger, error) {
@@ -52,7 +56,10 @@ type LogEntry struct {
 	Source    string           `json:"source,omitempty"`
 	Dest      string           `json:"dest,omitempty"`
 	Action    types.CopyAction `json:"action,omitempty"`
+	Method    types.CopyMethod `json:"method,omitempty"`
 	Error     string           `json:"error,omitempty"`
+	Class     types.ErrorClass `json:"error_class,omitempty"`
+	Attempts  int              `json:"attempts,omitempty"`
 	Duration  time.Duration    `json:"duration,omitempty"`
 }
 
@@ -67,12 +74,24 @@ func (l *Logger) LogTask(task types.CopyTask, duration time.Duration) {
 		Source:    task.Source.Path,
 		Dest:      task.DestPath,
 		Action:    task.Action,
+		Method:    task.Method,
 		Duration:  duration,
 	}
+	if task.Method != "" {
+		entry.Message += " (" + string(task.Method) + ")"
+	}
 
+	if task.Attempts > 1 {
+		entry.Attempts = task.Attempts
+		entry.Message += fmt.Sprintf(" [%d attempts]", task.Attempts)
+	}
 	if task.Error != "" {
 		entry.Level = "ERROR"
 		entry.Error = task.Error
+		entry.Class = task.ErrorClass
+		if task.ErrorClass != "" {
+			entry.Error = string(task.ErrorClass) + ": " + task.Error
+		}
 	}
 
 	l.writeEntry(entry)
@@ -130,14 +149,29 @@ func (l *Logger) writeEntry(entry LogEntry) {
 
 func (l *Logger) Summary(summary types.RunSummary) {
 	fmt.Fprintln(l.console, "\n=== ShutterPipe Summary ===")
+	if summary.RunID != "" {
+		fmt.Fprintf(l.console, "Run ID:         %s\n", summary.RunID)
+	}
 	fmt.Fprintf(l.console, "Total files:    %d\n", summary.TotalFiles)
 	fmt.Fprintf(l.console, "Copied:         %d\n", summary.Copied)
 	fmt.Fprintf(l.console, "Skipped:        %d\n", summary.Skipped)
 	fmt.Fprintf(l.console, "Renamed:        %d\n", summary.Renamed)
 	fmt.Fprintf(l.console, "Overwritten:    %d\n", summary.Overwritten)
 	fmt.Fprintf(l.console, "Quarantined:    %d\n", summary.Quarantined)
+	if summary.Review > 0 {
+		fmt.Fprintf(l.console, "Review:         %d\n", summary.Review)
+	}
 	fmt.Fprintf(l.console, "Failed:         %d\n", summary.Failed)
+	for class, n := range summary.FailuresByClass {
+		fmt.Fprintf(l.console, "  %-14s%d\n", string(class)+":", n)
+	}
 	fmt.Fprintf(l.console, "Unclassified:   %d\n", summary.Unclassified)
+	for rule, n := range summary.ScanSkipped {
+		fmt.Fprintf(l.console, "Scan skipped:   %d (%s)\n", n, rule)
+	}
+	for method, n := range summary.CopyMethods {
+		fmt.Fprintf(l.console, "Copy method:    %d (%s)\n", n, method)
+	}
 	fmt.Fprintf(l.console, "Duration:       %s\n", summary.Duration.Round(time.Second))
 	if summary.BytesCopied > 0 {
 		fmt.Fprintf(l.console, "Bytes copied:   %.2f MB\n", float64(summary.BytesCopied)/1024/1024)
@@ -147,5 +181,43 @@ func (l *Logger) Summary(summary types.RunSummary) {
 }
 
 func (l *Logger) Progress(current, total int, filename string) {
-	fmt.Fprintf(l.console, "\r[%d/%d] %s", current, total, filename)
+	l.progressLine(fmt.Sprintf("[%d/%d] %s", current, total, filename))
+}
+
+// Transfer redraws the progress line with the bytes copied, speed, time
+// left and the files in progress.
+func (l *Logger) Transfer(current, total int, throughput float64, t types.TransferProgress) {
+	line := fmt.Sprintf("[%d/%d] %.1f/%.1f MB", current, total, mb(t.BytesDone), mb(t.BytesTotal))
+	if t.BytesTotal > 0 {
+		line += fmt.Sprintf(" (%d%%)", t.BytesDone*100/t.BytesTotal)
+	}
+	line += fmt.Sprintf(" %.2f MB/s", throughput/1024/1024)
+	if t.ETA > 0 {
+		line += " ETA " + t.ETA.String()
+	}
+	for i, f := range t.Files {
+		sep := ", "
+		if i == 0 {
+			sep = " | "
+		}
+		pct := int64(100)
+		if f.Size > 0 {
+			pct = f.Done * 100 / f.Size
+		}
+		line += fmt.Sprintf("%s%s %d%%", sep, f.Filename, pct)
+	}
+	l.progressLine(line)
+}
+
+func (l *Logger) progressLine(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pad := max(l.lineLen-len(line), 0)
+	l.lineLen = len(line)
+	fmt.Fprintf(l.console, "\r%s%s", line, strings.Repeat(" ", pad))
+}
+
+func mb(n int64) float64 {
+	return float64(n) / 1024 / 1024
 }
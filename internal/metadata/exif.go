@@ -4,8 +4,8 @@ import (
 	"os"
 	"time"
 
-	"github.com/rwcarlsen/goexif/exif"
 	"github.com/On-Jun9/ShutterPipe/pkg/types"
+	"github.com/rwcarlsen/goexif/exif"
 )
 
 type EXIFExtractor struct{}
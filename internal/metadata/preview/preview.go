@@ -0,0 +1,111 @@
+// Package preview decodes photos for thumbnails and perceptual hashing. RAW
+// files are read through the JPEG preview the camera embedded in them.
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// maxPreviewScan limits how much of a RAW file is read when searching for an
+// embedded JPEG preview.
+const maxPreviewScan = 64 << 20
+
+// Format is how an extension can be decoded.
+type Format int
+
+const (
+	// Unsupported files can't be decoded.
+	Unsupported Format = iota
+	// Image files are decoded directly.
+	Image
+	// RAW files are decoded through their embedded JPEG preview.
+	RAW
+)
+
+// formats maps lower case extensions without the dot to their format.
+var formats = map[string]Format{
+	"jpg": Image, "jpeg": Image, "png": Image,
+	"raw": RAW, "arw": RAW, "cr2": RAW, "nef": RAW, "dng": RAW,
+	"raf": RAW, "orf": RAW, "rw2": RAW, "srw": RAW,
+}
+
+// FormatOf returns the format of ext (lower case, without the dot).
+func FormatOf(ext string) Format {
+	return formats[ext]
+}
+
+// Supported reports whether files with ext can be decoded.
+func Supported(ext string) bool {
+	return formats[ext] != Unsupported
+}
+
+// Decode returns the image in the file at path, or the embedded preview for
+// RAW files.
+func Decode(path, ext string) (image.Image, error) {
+	if formats[ext] == RAW {
+		return DecodeRAW(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// DecodeRAW returns the largest JPEG embedded in a RAW file, falling back to
+// the EXIF thumbnail.
+func DecodeRAW(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxPreviewScan))
+	if err != nil {
+		return nil, err
+	}
+
+	bestOffset, bestArea := -1, 0
+	for off := 0; off < len(data)-3; {
+		i := bytes.Index(data[off:], []byte{0xFF, 0xD8, 0xFF})
+		if i < 0 {
+			break
+		}
+		start := off + i
+		if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data[start:])); err == nil {
+			if area := cfg.Width * cfg.Height; area > bestArea {
+				bestOffset, bestArea = start, area
+			}
+		}
+		off = start + 3
+	}
+
+	if bestOffset >= 0 {
+		if img, err := jpeg.Decode(bytes.NewReader(data[bestOffset:])); err == nil {
+			return img, nil
+		}
+	}
+
+	x, err := exif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("no embedded preview: %w", err)
+	}
+	thumb, err := x.JpegThumbnail()
+	if err != nil {
+		return nil, fmt.Errorf("no embedded preview: %w", err)
+	}
+	return jpeg.Decode(bytes.NewReader(thumb))
+}
@@ -0,0 +1,59 @@
+package preview
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFormatOf(t *testing.T) {
+	for ext, want := range map[string]Format{"jpg": Image, "png": Image, "arw": RAW, "dng": RAW, "mp4": Unsupported, "xmp": Unsupported} {
+		if got := FormatOf(ext); got != want {
+			t.Errorf("FormatOf(%q) = %v, want %v", ext, got, want)
+		}
+		if Supported(ext) != (want != Unsupported) {
+			t.Errorf("Supported(%q) = %v", ext, Supported(ext))
+		}
+	}
+}
+
+func TestDecodeRAW_LargestPreview(t *testing.T) {
+	// RAW files carry a small and a large preview after their own headers
+	raw := []byte("II*\x00 not a real sensor dump ")
+	raw = append(raw, encodeJPEG(t, 160, 120)...)
+	raw = append(raw, encodeJPEG(t, 640, 480)...)
+	path := filepath.Join(t.TempDir(), "DSC00001.ARW")
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Decode(path, "arw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 640 || b.Dy() != 480 {
+		t.Errorf("preview is %dx%d, want 640x480", b.Dx(), b.Dy())
+	}
+}
+
+func TestDecodeRAW_NoPreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "DSC00001.ARW")
+	if err := os.WriteFile(path, []byte("II*\x00 no preview"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeRAW(path); err == nil {
+		t.Error("DecodeRAW succeeded without a preview")
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// histogram counts observations into cumulative buckets.
+type histogram struct {
+	bounds []float64
+	// counts has one entry per bound plus the +Inf bucket, not cumulative.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i, _ := slices.BinarySearch(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// encoder writes the Prometheus text exposition format.
+type encoder struct {
+	buf *bytes.Buffer
+}
+
+func (e encoder) header(name, help, typ string) {
+	e.buf.WriteString("# HELP " + name + " " + help + "\n")
+	e.buf.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sample writes one value. labels alternate names and values.
+func (e encoder) sample(name string, value float64, labels ...string) {
+	e.buf.WriteString(name)
+	if len(labels) > 0 {
+		e.buf.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.buf.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		e.buf.WriteByte('}')
+	}
+	e.buf.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func (e encoder) histogram(name string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		e.sample(name+"_bucket", float64(cumulative), "le", formatFloat(bound))
+	}
+	e.sample(name+"_bucket", float64(h.count), "le", "+Inf")
+	e.sample(name+"_sum", h.sum)
+	e.sample(name+"_count", float64(h.count))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func sortedRunKeys(m map[runKey]float64) []runKey {
+	keys := make([]runKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b runKey) int {
+		return cmp.Or(cmp.Compare(a.preset, b.preset), cmp.Compare(a.result, b.result))
+	})
+	return keys
+}
@@ -0,0 +1,227 @@
+// Package metrics exposes pipeline runs as Prometheus metrics in the text
+// exposition format.
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Run results counted in shutterpipe_runs_total.
+const (
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+)
+
+// unknownClass counts failures that were not classified.
+const unknownClass types.ErrorClass = "unknown"
+
+var (
+	// durationBuckets are the upper bounds of the per-file copy duration
+	// histogram in seconds.
+	durationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+	// throughputBuckets are the upper bounds of the per-file throughput
+	// histogram in bytes per second, from 1 MiB/s to 1 GiB/s.
+	throughputBuckets = []float64{1 << 20, 5 << 20, 10 << 20, 25 << 20, 50 << 20, 100 << 20, 250 << 20, 500 << 20, 1 << 30}
+)
+
+type runKey struct {
+	preset string
+	result string
+}
+
+// Collector accumulates the metrics of every run since the process started.
+// Runs are expected one at a time, as the web server and the watch daemon
+// run them.
+type Collector struct {
+	mu           sync.Mutex
+	files        map[types.CopyAction]float64
+	bytes        map[types.CopyAction]float64
+	failures     map[types.ErrorClass]float64
+	unclassified float64
+	runs         map[runKey]float64
+	duration     *histogram
+	throughput   *histogram
+	phase        string
+	lastSuccess  map[string]time.Time
+
+	queueDepth func() int
+}
+
+// NewCollector creates a collector with no runs recorded.
+func NewCollector() *Collector {
+	return &Collector{
+		files:       make(map[types.CopyAction]float64),
+		bytes:       make(map[types.CopyAction]float64),
+		failures:    make(map[types.ErrorClass]float64),
+		runs:        make(map[runKey]float64),
+		duration:    newHistogram(durationBuckets),
+		throughput:  newHistogram(throughputBuckets),
+		phase:       pipeline.PhaseIdle,
+		lastSuccess: make(map[string]time.Time),
+	}
+}
+
+// SetQueueDepth sets the function reporting how many runs are waiting.
+// Without it the queue depth is 0.
+func (c *Collector) SetQueueDepth(fn func() int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queueDepth = fn
+}
+
+// Run records one pipeline run. Subscribe Observe to the pipeline's events
+// and call Finish with the result of Run.
+type Run struct {
+	c      *Collector
+	preset string
+	dryRun bool
+}
+
+// StartRun begins recording a run of the named preset; preset is empty for
+// runs not started from one. Dry runs only report their phase.
+func (c *Collector) StartRun(preset string, dryRun bool) *Run {
+	return &Run{c: c, preset: preset, dryRun: dryRun}
+}
+
+// Observe records a pipeline event.
+func (r *Run) Observe(update pipeline.ProgressUpdate) {
+	c := r.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if phase := pipeline.Phase(update.Type); phase != "" {
+		c.phase = phase
+	}
+	if r.dryRun || update.Task == nil {
+		return
+	}
+	if update.Type != pipeline.EventProgress && update.Type != pipeline.EventFileSkipped {
+		return
+	}
+
+	task := update.Task
+	action := task.Action
+	if task.Error != "" {
+		action = types.CopyActionFailed
+	}
+	c.files[action]++
+
+	// Only files the copier actually wrote have a copy method
+	if task.Error != "" || task.Method == "" {
+		return
+	}
+	c.bytes[action] += float64(task.Source.Size)
+	if seconds := task.Duration.Seconds(); seconds > 0 {
+		c.duration.observe(seconds)
+		c.throughput.observe(float64(task.Source.Size) / seconds)
+	}
+}
+
+// Finish records how the run ended. A run fails if it returned an error or
+// any file failed. summary may be nil when the run never started.
+func (r *Run) Finish(summary *types.RunSummary, err error) {
+	c := r.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.phase = pipeline.PhaseFailed
+	}
+	if r.dryRun {
+		return
+	}
+
+	result := ResultSuccess
+	if err != nil || summary == nil || summary.Failed > 0 {
+		result = ResultFailed
+	}
+	c.runs[runKey{r.preset, result}]++
+	if result == ResultSuccess {
+		c.lastSuccess[r.preset] = time.Now()
+	}
+
+	if summary == nil {
+		return
+	}
+	c.unclassified += float64(summary.Unclassified)
+	classified := 0
+	for class, n := range summary.FailuresByClass {
+		c.failures[class] += float64(n)
+		classified += n
+	}
+	if rest := summary.Failed - classified; rest > 0 {
+		c.failures[unknownClass] += float64(rest)
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	c.write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+func (c *Collector) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := encoder{buf}
+
+	e.header("shutterpipe_files_total", "Files processed, by action. Failed files are counted as failed.", "counter")
+	for _, action := range sortedKeys(c.files) {
+		e.sample("shutterpipe_files_total", c.files[action], "action", string(action))
+	}
+
+	e.header("shutterpipe_bytes_total", "Bytes written to the destination, by action.", "counter")
+	for _, action := range sortedKeys(c.bytes) {
+		e.sample("shutterpipe_bytes_total", c.bytes[action], "action", string(action))
+	}
+
+	e.header("shutterpipe_failures_total", "Failed files of finished runs, by error class.", "counter")
+	for _, class := range sortedKeys(c.failures) {
+		e.sample("shutterpipe_failures_total", c.failures[class], "class", string(class))
+	}
+
+	e.header("shutterpipe_unclassified_files_total", "Files of finished runs without a capture date.", "counter")
+	e.sample("shutterpipe_unclassified_files_total", c.unclassified)
+
+	e.header("shutterpipe_runs_total", "Finished runs, by preset and result.", "counter")
+	for _, key := range sortedRunKeys(c.runs) {
+		e.sample("shutterpipe_runs_total", c.runs[key], "preset", key.preset, "result", key.result)
+	}
+
+	e.header("shutterpipe_file_copy_duration_seconds", "Time to copy one file, including retries.", "histogram")
+	e.histogram("shutterpipe_file_copy_duration_seconds", c.duration)
+
+	e.header("shutterpipe_file_copy_throughput_bytes_per_second", "Copy rate of one file.", "histogram")
+	e.histogram("shutterpipe_file_copy_throughput_bytes_per_second", c.throughput)
+
+	e.header("shutterpipe_run_phase", "Phase of the current or last run; 1 for the active phase.", "gauge")
+	for _, phase := range pipeline.Phases {
+		value := 0.0
+		if phase == c.phase {
+			value = 1
+		}
+		e.sample("shutterpipe_run_phase", value, "phase", phase)
+	}
+
+	depth := 0
+	if c.queueDepth != nil {
+		depth = c.queueDepth()
+	}
+	e.header("shutterpipe_queue_depth", "Runs waiting to start.", "gauge")
+	e.sample("shutterpipe_queue_depth", float64(depth))
+
+	e.header("shutterpipe_last_success_timestamp_seconds", "Unix time of the last successful run, by preset.", "gauge")
+	for _, preset := range sortedKeys(c.lastSuccess) {
+		e.sample("shutterpipe_last_success_timestamp_seconds", float64(c.lastSuccess[preset].Unix()), "preset", preset)
+	}
+}
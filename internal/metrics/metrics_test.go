@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func expectLines(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(body, "\n"+line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	c.SetQueueDepth(func() int { return 2 })
+
+	run := c.StartRun("sony", false)
+	run.Observe(pipeline.ProgressUpdate{Type: pipeline.EventRunStarted})
+	run.Observe(pipeline.ProgressUpdate{Type: pipeline.EventFileSkipped, Task: &types.CopyTask{Action: types.CopyActionSkipped}})
+	run.Observe(pipeline.ProgressUpdate{Type: pipeline.EventProgress, Task: &types.CopyTask{
+		Source:   types.FileEntry{Size: 4 << 20},
+		Action:   types.CopyActionCopied,
+		Method:   types.CopyMethodBuffered,
+		Duration: 2 * time.Second,
+	}})
+	run.Observe(pipeline.ProgressUpdate{Type: pipeline.EventProgress, Task: &types.CopyTask{
+		Source: types.FileEntry{Size: 1 << 20},
+		Action: types.CopyActionCopied,
+		Method: types.CopyMethodBuffered,
+		Error:  "disk full",
+	}})
+
+	body := scrape(t, c)
+	expectLines(t, body,
+		`shutterpipe_run_phase{phase="copying"} 1`,
+		`shutterpipe_run_phase{phase="idle"} 0`,
+		`shutterpipe_queue_depth 2`,
+	)
+
+	summary := &types.RunSummary{
+		Failed:          1,
+		FailuresByClass: map[types.ErrorClass]int{types.ErrorClassNoSpace: 1},
+		Unclassified:    3,
+	}
+	run.Observe(pipeline.ProgressUpdate{Type: pipeline.EventComplete, Summary: summary})
+	run.Finish(summary, nil)
+
+	body = scrape(t, c)
+	expectLines(t, body,
+		`shutterpipe_files_total{action="copied"} 1`,
+		`shutterpipe_files_total{action="failed"} 1`,
+		`shutterpipe_files_total{action="skipped"} 1`,
+		`shutterpipe_bytes_total{action="copied"} 4.194304e+06`,
+		`shutterpipe_failures_total{class="no_space"} 1`,
+		`shutterpipe_unclassified_files_total 3`,
+		`shutterpipe_runs_total{preset="sony",result="failed"} 1`,
+		`shutterpipe_file_copy_duration_seconds_bucket{le="1"} 0`,
+		`shutterpipe_file_copy_duration_seconds_bucket{le="2.5"} 1`,
+		`shutterpipe_file_copy_duration_seconds_bucket{le="+Inf"} 1`,
+		`shutterpipe_file_copy_duration_seconds_sum 2`,
+		`shutterpipe_file_copy_duration_seconds_count 1`,
+		`shutterpipe_file_copy_throughput_bytes_per_second_bucket{le="1.048576e+06"} 0`,
+		`shutterpipe_file_copy_throughput_bytes_per_second_bucket{le="5.24288e+06"} 1`,
+		`shutterpipe_run_phase{phase="complete"} 1`,
+	)
+	// A run with failed files is not a success
+	if strings.Contains(body, "shutterpipe_last_success_timestamp_seconds{") {
+		t.Errorf("unexpected last success in\n%s", body)
+	}
+
+	c.StartRun("sony", false).Finish(&types.RunSummary{}, nil)
+	expectLines(t, scrape(t, c), `shutterpipe_runs_total{preset="sony",result="success"} 1`)
+	if !strings.Contains(scrape(t, c), `shutterpipe_last_success_timestamp_seconds{preset="sony"} `) {
+		t.Error("missing last success timestamp")
+	}
+}
+
+func TestCollector_FailedAndDryRuns(t *testing.T) {
+	c := NewCollector()
+
+	c.StartRun("", false).Finish(nil, errors.New("source missing"))
+
+	dry := c.StartRun("nightly", true)
+	dry.Observe(pipeline.ProgressUpdate{Type: pipeline.EventProgress, Task: &types.CopyTask{Action: types.CopyActionCopied}})
+	dry.Finish(&types.RunSummary{}, nil)
+
+	body := scrape(t, c)
+	expectLines(t, body, `shutterpipe_runs_total{preset="",result="failed"} 1`)
+	if strings.Contains(body, `preset="nightly"`) || strings.Contains(body, "shutterpipe_files_total{") {
+		t.Errorf("dry run was counted:\n%s", body)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel = %s", got)
+	}
+}
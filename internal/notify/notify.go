@@ -0,0 +1,314 @@
+// Package notify sends run notifications by webhook, email and desktop
+// notification.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Notification statuses.
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Default templates.
+const (
+	DefaultTitle   = "ShutterPipe {{.Status}}"
+	DefaultMessage = "{{.Source}} → {{.Dest}}: {{.Summary.Copied}} copied, {{.Summary.Skipped}} skipped, {{.Summary.Failed}} failed{{if .Error}} ({{.Error}}){{end}}"
+)
+
+// SignatureHeader carries the webhook body's HMAC-SHA256 as "sha256=<hex>".
+const SignatureHeader = "X-ShutterPipe-Signature"
+
+// Timeout bounds each delivery.
+var Timeout = 10 * time.Second
+
+// Notification describes a finished run.
+type Notification struct {
+	Status  string            `json:"status"`
+	RunID   string            `json:"run_id"`
+	Source  string            `json:"source"`
+	Dest    string            `json:"dest"`
+	Summary *types.RunSummary `json:"summary"`
+	Error   string            `json:"error,omitempty"`
+	Title   string            `json:"title"`
+	Message string            `json:"message"`
+}
+
+// Failed reports whether the run failed or had failed files.
+func (n *Notification) Failed() bool {
+	return n.Status == StatusFailed || (n.Summary != nil && n.Summary.Failed > 0)
+}
+
+// Validate checks the notifier settings and templates.
+func Validate(cfg *types.NotifyConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.When {
+	case "", types.NotifyAlways, types.NotifyFailure, types.NotifySuccess:
+	default:
+		return fmt.Errorf("unknown notify condition %q", cfg.When)
+	}
+	if _, err := parseTemplate(cfg.Title, DefaultTitle); err != nil {
+		return fmt.Errorf("invalid title template: %w", err)
+	}
+	if _, err := parseTemplate(cfg.Message, DefaultMessage); err != nil {
+		return fmt.Errorf("invalid message template: %w", err)
+	}
+	for _, w := range cfg.Webhooks {
+		if !strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://") {
+			return fmt.Errorf("invalid webhook URL %q", w.URL)
+		}
+	}
+	if e := cfg.Email; e != nil && (e.Host == "" || e.From == "" || len(e.To) == 0) {
+		return errors.New("email notifications need host, from and to")
+	}
+	return nil
+}
+
+// Redact returns a copy of cfg without webhook secrets and SMTP passwords,
+// for run history and API responses.
+func Redact(cfg *types.NotifyConfig) *types.NotifyConfig {
+	if cfg == nil {
+		return nil
+	}
+	out := *cfg
+	out.Webhooks = make([]types.WebhookNotify, len(cfg.Webhooks))
+	for i, w := range cfg.Webhooks {
+		w.Secret = ""
+		out.Webhooks[i] = w
+	}
+	if cfg.Email != nil {
+		email := *cfg.Email
+		email.Password = ""
+		out.Email = &email
+	}
+	return &out
+}
+
+// HasCredentials reports whether cfg holds a webhook secret or SMTP password.
+func HasCredentials(cfg *types.NotifyConfig) bool {
+	if cfg == nil {
+		return false
+	}
+	for _, w := range cfg.Webhooks {
+		if w.Secret != "" {
+			return true
+		}
+	}
+	return cfg.Email != nil && cfg.Email.Password != ""
+}
+
+// RestoreCredentials returns a copy of cfg with the credentials Redact
+// removed taken from stored: webhook secrets by URL and the SMTP password if
+// the host and username are unchanged.
+func RestoreCredentials(cfg, stored *types.NotifyConfig) *types.NotifyConfig {
+	if cfg == nil || stored == nil {
+		return cfg
+	}
+	out := *cfg
+	out.Webhooks = make([]types.WebhookNotify, len(cfg.Webhooks))
+	for i, w := range cfg.Webhooks {
+		for _, s := range stored.Webhooks {
+			if w.Secret == "" && s.URL == w.URL {
+				w.Secret = s.Secret
+				break
+			}
+		}
+		out.Webhooks[i] = w
+	}
+	if e, s := cfg.Email, stored.Email; e != nil && s != nil && e.Password == "" &&
+		e.Host == s.Host && e.Username == s.Username {
+		email := *e
+		email.Password = s.Password
+		out.Email = &email
+	}
+	return &out
+}
+
+// Send renders the templates and delivers n to every configured notifier.
+// It returns one error per failed delivery.
+func Send(cfg *types.NotifyConfig, n Notification) []error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.When {
+	case types.NotifyFailure:
+		if !n.Failed() {
+			return nil
+		}
+	case types.NotifySuccess:
+		if n.Failed() {
+			return nil
+		}
+	}
+
+	if n.Summary == nil {
+		n.Summary = &types.RunSummary{}
+	}
+
+	var err error
+	if n.Title, err = render(cfg.Title, DefaultTitle, &n); err != nil {
+		return []error{err}
+	}
+	if n.Message, err = render(cfg.Message, DefaultMessage, &n); err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, w := range cfg.Webhooks {
+		if err := SendWebhook(w, n); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", w.URL, err))
+		}
+	}
+	if cfg.Email != nil {
+		if err := SendEmail(*cfg.Email, n); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if cfg.Desktop != nil {
+		if err := SendDesktop(*cfg.Desktop, n); err != nil {
+			errs = append(errs, fmt.Errorf("desktop: %w", err))
+		}
+	}
+	return errs
+}
+
+func parseTemplate(text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	return template.New("notify").Parse(text)
+}
+
+func render(text, fallback string, n *Notification) (string, error) {
+	tmpl, err := parseTemplate(text, fallback)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendWebhook POSTs n as JSON.
+func SendWebhook(w types.WebhookNotify, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ShutterPipe")
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+
+	resp, err := (&http.Client{Timeout: Timeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// SendEmail sends n as a plain-text mail. STARTTLS is used when the server
+// offers it; authentication requires TLS unless the server is local.
+func SendEmail(e types.EmailNotify, n Notification) error {
+	port := e.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerValue(n.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, e.From, e.To, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(Timeout):
+		return fmt.Errorf("timed out after %s", Timeout)
+	}
+}
+
+// headerValue keeps a header on one line and encodes non-ASCII text.
+func headerValue(s string) string {
+	s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	return mime.BEncoding.Encode("utf-8", s)
+}
+
+// SendDesktop runs the notify-send command.
+func SendDesktop(d types.DesktopNotify, n Notification) error {
+	command := d.Command
+	if command == "" {
+		command = "notify-send"
+	}
+	args := strings.Fields(command)
+	args = append(args, n.Title, n.Message)
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
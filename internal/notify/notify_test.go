@@ -0,0 +1,264 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func testNotification() Notification {
+	return Notification{
+		Status:  StatusCompleted,
+		RunID:   "20251231-100000-abcd",
+		Source:  "/card",
+		Dest:    "/nas",
+		Summary: &types.RunSummary{Copied: 12, Failed: 1},
+	}
+}
+
+func TestSend_Webhook(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+
+	cfg := &types.NotifyConfig{
+		Message:  "{{.Summary.Copied}} copied",
+		Webhooks: []types.WebhookNotify{{URL: srv.URL, Secret: "s3cret"}},
+	}
+	if errs := Send(cfg, testNotification()); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	var got Notification
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Message != "12 copied" || got.Summary.Copied != 12 {
+		t.Errorf("unexpected payload: %s", body)
+	}
+	if signature != Sign("s3cret", body) {
+		t.Errorf("signature mismatch: %s", signature)
+	}
+}
+
+func TestSend_WebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	errs := Send(&types.NotifyConfig{Webhooks: []types.WebhookNotify{{URL: srv.URL}}}, testNotification())
+	if len(errs) != 1 {
+		t.Errorf("expected one error, got %v", errs)
+	}
+}
+
+func TestSend_When(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	hooks := []types.WebhookNotify{{URL: srv.URL}}
+
+	ok := testNotification()
+	ok.Summary = &types.RunSummary{Copied: 1}
+
+	Send(&types.NotifyConfig{When: types.NotifyFailure, Webhooks: hooks}, ok)
+	if calls != 0 {
+		t.Error("failure-only notifier fired for a successful run")
+	}
+	Send(&types.NotifyConfig{When: types.NotifyFailure, Webhooks: hooks}, testNotification())
+	if calls != 1 {
+		t.Error("failure-only notifier did not fire for a run with failed files")
+	}
+	Send(&types.NotifyConfig{When: types.NotifySuccess, Webhooks: hooks}, ok)
+	if calls != 2 {
+		t.Error("success-only notifier did not fire for a successful run")
+	}
+}
+
+// fakeSMTP accepts one message and returns it on the channel.
+func fakeSMTP(t *testing.T) (host string, port int, received <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					ch <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, ch
+}
+
+func TestSend_Email(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+
+	cfg := &types.NotifyConfig{
+		Title: "백업 {{.Status}}",
+		Email: &types.EmailNotify{Host: host, Port: port, From: "pipe@example.com", To: []string{"me@example.com"}},
+	}
+	if errs := Send(cfg, testNotification()); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	msg := <-received
+	if !strings.Contains(msg, "To: me@example.com") {
+		t.Errorf("missing recipient header:\n%s", msg)
+	}
+	if !strings.Contains(msg, "Subject: =?utf-8?b?") {
+		t.Errorf("expected encoded subject:\n%s", msg)
+	}
+	if !strings.Contains(msg, "12 copied") {
+		t.Errorf("expected default message body:\n%s", msg)
+	}
+}
+
+func TestSend_Desktop(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "notify-send")
+	os.WriteFile(script, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" > "+strconv.Quote(out)+"\n"), 0755)
+
+	cfg := &types.NotifyConfig{
+		Title:   "done {{.RunID}}",
+		Desktop: &types.DesktopNotify{Command: script + " --urgency=low"},
+	}
+	if errs := Send(cfg, testNotification()); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(args) != 3 || args[0] != "--urgency=low" || args[1] != "done 20251231-100000-abcd" {
+		t.Errorf("unexpected args: %q", args)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []*types.NotifyConfig{
+		{When: "sometimes"},
+		{Message: "{{.Summary.Copied"},
+		{Webhooks: []types.WebhookNotify{{URL: "ftp://example.com"}}},
+		{Email: &types.EmailNotify{Host: "localhost"}},
+	}
+	for _, cfg := range bad {
+		if err := Validate(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+	if err := Validate(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cfg := &types.NotifyConfig{
+		Webhooks: []types.WebhookNotify{{URL: "https://example.com/hook", Secret: "s3cret"}},
+		Email:    &types.EmailNotify{Host: "smtp.example.com", Username: "me", Password: "hunter2"},
+	}
+
+	got := Redact(cfg)
+	if got.Webhooks[0].Secret != "" || got.Email.Password != "" {
+		t.Errorf("credentials kept: %+v %+v", got.Webhooks[0], *got.Email)
+	}
+	if got.Webhooks[0].URL != "https://example.com/hook" || got.Email.Username != "me" {
+		t.Errorf("other fields lost: %+v %+v", got.Webhooks[0], *got.Email)
+	}
+	if cfg.Webhooks[0].Secret != "s3cret" || cfg.Email.Password != "hunter2" {
+		t.Error("Redact modified its input")
+	}
+	if Redact(nil) != nil {
+		t.Error("Redact(nil) != nil")
+	}
+}
+
+func TestRestoreCredentials(t *testing.T) {
+	stored := &types.NotifyConfig{
+		Webhooks: []types.WebhookNotify{
+			{URL: "https://example.com/a", Secret: "a-secret"},
+			{URL: "https://example.com/b", Secret: "b-secret"},
+		},
+		Email: &types.EmailNotify{Host: "smtp.example.com", Username: "me", Password: "hunter2"},
+	}
+
+	got := RestoreCredentials(Redact(stored), stored)
+	if got.Webhooks[0].Secret != "a-secret" || got.Webhooks[1].Secret != "b-secret" || got.Email.Password != "hunter2" {
+		t.Errorf("credentials not restored: %+v %+v", got.Webhooks, *got.Email)
+	}
+
+	// Changed targets don't inherit the stored credentials
+	edited := Redact(stored)
+	edited.Webhooks[0].URL = "https://attacker.example/hook"
+	edited.Email.Host = "smtp.attacker.example"
+	got = RestoreCredentials(edited, stored)
+	if got.Webhooks[0].Secret != "" || got.Email.Password != "" {
+		t.Errorf("credentials moved to a new target: %+v %+v", got.Webhooks[0], *got.Email)
+	}
+	if !HasCredentials(stored) || HasCredentials(Redact(stored)) {
+		t.Error("HasCredentials does not match Redact")
+	}
+}
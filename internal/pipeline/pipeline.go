@@ -1,17 +1,26 @@
 package pipeline
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/On-Jun9/ShutterPipe/internal/config"
 	"github.com/On-Jun9/ShutterPipe/internal/copier"
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/hooks"
+	"github.com/On-Jun9/ShutterPipe/internal/journal"
 	"github.com/On-Jun9/ShutterPipe/internal/log"
 	"github.com/On-Jun9/ShutterPipe/internal/metadata"
+	"github.com/On-Jun9/ShutterPipe/internal/notify"
 	"github.com/On-Jun9/ShutterPipe/internal/planner"
 	"github.com/On-Jun9/ShutterPipe/internal/policy"
+	"github.com/On-Jun9/ShutterPipe/internal/preflight"
+	"github.com/On-Jun9/ShutterPipe/internal/quarantine"
+	"github.com/On-Jun9/ShutterPipe/internal/report"
 	"github.com/On-Jun9/ShutterPipe/internal/scanner"
 	"github.com/On-Jun9/ShutterPipe/internal/state"
 	"github.com/On-Jun9/ShutterPipe/internal/verify"
@@ -19,17 +28,49 @@ import (
 )
 
 type Pipeline struct {
-	cfg              *config.Config
-	scanner          *scanner.Scanner
-	meta             *metadata.Extractor
-	planner          *planner.Planner
-	dedup            *policy.DedupChecker
-	conflict         *policy.ConflictResolver
-	copier           *copier.Copier
-	verifier         *verify.Verifier
-	state            *state.State
-	logger           *log.Logger
-	progressCallback ProgressCallback
+	cfg        *config.Config
+	scanner    *scanner.Scanner
+	meta       *metadata.Extractor
+	planner    *planner.Planner
+	dedup      *policy.DedupChecker
+	perceptual *policy.PerceptualIndex
+	conflict   *policy.ConflictResolver
+	copier     *copier.Copier
+	verifier   *verify.Verifier
+	state      *state.State
+	logger     *log.Logger
+	history    *history.Store
+	results    []history.FileResult
+	hooks      *hooks.Runner
+	events     *Bus
+	runID      string
+	cancelled  atomic.Bool
+	abortErr   atomic.Pointer[error]
+}
+
+// Validate checks cfg, including the hook, notifier and copier settings, and
+// fills in defaults. Call it before New.
+func Validate(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if err := hooks.Validate(cfg.Hooks); err != nil {
+		return &config.ValidationError{Field: "hooks", Message: err.Error()}
+	}
+	if err := notify.Validate(cfg.Notify); err != nil {
+		return &config.ValidationError{Field: "notify", Message: err.Error()}
+	}
+	if err := copier.ValidateFsync(cfg.Fsync); err != nil {
+		return &config.ValidationError{Field: "fsync", Message: err.Error()}
+	}
+	if err := copier.ValidatePreserve(cfg.Preserve); err != nil {
+		return &config.ValidationError{Field: "preserve", Message: err.Error()}
+	}
+	if err := copier.ValidateBandwidth(cfg.Bandwidth); err != nil {
+		return &config.ValidationError{Field: "bandwidth", Message: err.Error()}
+	}
+	return nil
 }
 
 func New(cfg *config.Config) (*Pipeline, error) {
@@ -45,22 +86,135 @@ func New(cfg *config.Config) (*Pipeline, error) {
 
 	quarantinePath := filepath.Join(cfg.Dest, cfg.QuarantineDir)
 
-	return &Pipeline{
-		cfg:      cfg,
-		scanner:  scanner.New(cfg.IncludeExtensions),
-		meta:     metadata.New(),
-		planner:  planner.New(cfg.Dest, cfg.UnclassifiedDir, cfg.OrganizeStrategy, cfg.EventName),
-		dedup:    policy.NewDedupChecker(cfg.DedupMethod),
-		conflict: policy.NewConflictResolver(cfg.ConflictPolicy, quarantinePath),
-		copier:   copier.New(cfg.Jobs, cfg.DryRun, cfg.HashVerify),
-		verifier: verify.New(cfg.HashVerify),
-		state:    st,
-		logger:   logger,
-	}, nil
+	var perceptual *policy.PerceptualIndex
+	if cfg.DedupMethod == types.DedupMethodPerceptual {
+		cachePath := filepath.Join(filepath.Dir(cfg.StateFile), "perceptual-cache.json")
+		perceptual = policy.NewPerceptualIndex(cfg.Dest, []string{cfg.QuarantineDir, cfg.ReviewDir, cfg.ReportDir, journal.BackupDirName}, *cfg.PerceptualThreshold, cachePath)
+	}
+
+	scan := scanner.NewWithOptions(cfg.IncludeExtensions, scanner.Options{
+		IncludePatterns: cfg.IncludePatterns,
+		ExcludePatterns: cfg.ExcludePatterns,
+		MinSize:         cfg.MinFileSize,
+		MaxSize:         cfg.MaxFileSize,
+		CardMode:        cfg.CardMode,
+	})
+
+	p := &Pipeline{
+		cfg:        cfg,
+		scanner:    scan,
+		meta:       metadata.New(),
+		planner:    planner.New(cfg.Dest, cfg.UnclassifiedDir, cfg.OrganizeStrategy, cfg.EventName),
+		dedup:      policy.NewDedupChecker(cfg.DedupMethod),
+		perceptual: perceptual,
+		conflict:   policy.NewConflictResolver(cfg.ConflictPolicy, quarantinePath),
+		copier:     copier.New(cfg.Jobs, cfg.DryRun, cfg.HashVerify),
+		verifier:   verify.New(cfg.HashVerify),
+		state:      st,
+		logger:     logger,
+		history:    history.NewStore(cfg.HistoryDir),
+		events:     NewBus(),
+	}
+	p.hooks = hooks.NewRunner(cfg.Hooks, logger.Error)
+	p.copier.SetBandwidth(cfg.Bandwidth)
+	p.copier.SetDurability(cfg.Fsync, cfg.Preserve)
+	p.copier.SetBufferSize(cfg.CopyBufferSize)
+	p.copier.SetRetry(cfg.RetryAttempts, time.Duration(cfg.RetryDelaySec)*time.Second)
+
+	// The logger, run history and hooks see the same events as external
+	// subscribers such as the web UI
+	p.events.Subscribe(p.logEvent)
+	p.events.Subscribe(p.recordEvent)
+	p.events.Subscribe(p.hookEvent)
+	p.events.Subscribe(p.notifyEvent)
+
+	return p, nil
+}
+
+// Events returns the event bus of the pipeline.
+func (p *Pipeline) Events() *Bus {
+	return p.events
 }
 
+// SetProgressCallback subscribes cb to all pipeline events.
 func (p *Pipeline) SetProgressCallback(cb ProgressCallback) {
-	p.progressCallback = cb
+	p.events.Subscribe(cb)
+}
+
+func (p *Pipeline) emit(update ProgressUpdate) {
+	update.RunID = p.runID
+	p.events.Publish(update)
+}
+
+// Cancel stops planning and makes pending copies fail. Files copied so far
+// are still recorded in the state file.
+func (p *Pipeline) Cancel() {
+	p.cancelled.Store(true)
+	p.copier.Cancel()
+}
+
+// SetBandwidth changes the copy throughput limits of a running pipeline.
+// Zero means unlimited. The configured schedule no longer applies afterwards.
+func (p *Pipeline) SetBandwidth(limit, perWorker int64) {
+	p.copier.SetLimits(limit, perWorker)
+	p.logger.Info(fmt.Sprintf("Bandwidth limit changed: %d B/s global, %d B/s per worker", limit, perWorker))
+	p.emit(ProgressUpdate{
+		Type:       EventBandwidth,
+		Throughput: p.copier.Throughput(),
+		Limit:      limit,
+		PerWorker:  perWorker,
+	})
+}
+
+// Bandwidth returns the current limits and the smoothed copy throughput in
+// bytes per second.
+func (p *Pipeline) Bandwidth() (limit, perWorker int64, throughput float64) {
+	limit, perWorker = p.copier.Limits()
+	return limit, perWorker, p.copier.Throughput()
+}
+
+// emitTransfer reports the bytes copied so far. finished is the size of
+// the files already done; the copied part of the files in progress is added.
+func (p *Pipeline) emitTransfer(current, total int, finished, totalBytes int64) {
+	limit, perWorker, throughput := p.Bandwidth()
+	files := p.copier.Progress()
+
+	done := finished
+	for _, f := range files {
+		done += f.Done
+	}
+	var eta time.Duration
+	if throughput > 0 && totalBytes > done {
+		eta = time.Duration(float64(totalBytes-done) / throughput * float64(time.Second)).Round(time.Second)
+	}
+
+	p.emit(ProgressUpdate{
+		Type:       EventTransfer,
+		Current:    current,
+		Total:      total,
+		Throughput: throughput,
+		Limit:      limit,
+		PerWorker:  perWorker,
+		Transfer: &types.TransferProgress{
+			BytesDone:  done,
+			BytesTotal: totalBytes,
+			ETA:        eta,
+			Files:      files,
+		},
+	})
+}
+
+// Abort cancels the run and makes Run return err.
+func (p *Pipeline) Abort(err error) {
+	p.abortErr.CompareAndSwap(nil, &err)
+	p.Cancel()
+}
+
+func (p *Pipeline) stopErr() error {
+	if err := p.abortErr.Load(); err != nil {
+		return *err
+	}
+	return copier.ErrCancelled
 }
 
 // shouldIncludeByDate checks if a file should be included based on date filter.
@@ -100,17 +254,145 @@ func (p *Pipeline) shouldIncludeByDate(entry types.FileEntry, meta types.MediaMe
 	return true
 }
 
+// groupMetadata extracts metadata for every member of a group and dates the
+// whole group from its most authoritative member that has a capture time.
+// Members fall back to their own metadata if no member could be dated.
+func (p *Pipeline) groupMetadata(group types.FileGroup) map[string]types.MediaMetadata {
+	metas := make(map[string]types.MediaMetadata, len(group.Members))
+	var authority *types.MediaMetadata
+	var authorityName string
+
+	// Members are sorted most authoritative first
+	for _, entry := range group.Members {
+		meta := p.meta.Extract(entry)
+		metas[entry.Path] = meta
+		if authority == nil && meta.CaptureTime != nil {
+			authority = &meta
+			authorityName = entry.Name
+		}
+	}
+
+	if authority == nil || len(group.Members) == 1 {
+		return metas
+	}
+
+	for _, entry := range group.Members {
+		meta := *authority
+		if entry.Name != authorityName {
+			meta.Source = authority.Source + " (group:" + authorityName + ")"
+		}
+		metas[entry.Path] = meta
+	}
+	return metas
+}
+
+// countFailure adds a failed file to the summary.
+func countFailure(summary *types.RunSummary, class types.ErrorClass) {
+	summary.Failed++
+	if class == "" {
+		return
+	}
+	if summary.FailuresByClass == nil {
+		summary.FailuresByClass = make(map[types.ErrorClass]int)
+	}
+	summary.FailuresByClass[class]++
+}
+
+// uncountCopy takes back the action and copy method counted for a file the
+// copier reported as done but that failed to sync afterwards.
+func uncountCopy(summary *types.RunSummary, task types.CopyTask) {
+	switch task.Action {
+	case types.CopyActionCopied:
+		summary.Copied--
+	case types.CopyActionRenamed:
+		summary.Renamed--
+	case types.CopyActionOverwritten:
+		summary.Overwritten--
+	case types.CopyActionQuarantined:
+		summary.Quarantined--
+	case types.CopyActionReview:
+		summary.Review--
+	}
+	if summary.CopyMethods[task.Method] > 0 {
+		summary.CopyMethods[task.Method]--
+		if summary.CopyMethods[task.Method] == 0 {
+			delete(summary.CopyMethods, task.Method)
+		}
+	}
+}
+
+// preflight checks free space, permissions, mounts and filename limits of the
+// destination before copying. Dry runs only log the problems.
+func (p *Pipeline) preflight(tasks []types.CopyTask) error {
+	p.emit(ProgressUpdate{
+		Type:    EventStatus,
+		Message: "대상 경로 사전 점검 중...",
+	})
+
+	rep := preflight.Check(tasks, preflight.Options{Dest: p.cfg.Dest, SpaceMargin: p.cfg.SpaceMargin})
+	for _, fs := range rep.Filesystems {
+		p.logger.Info(fmt.Sprintf("Preflight: %s (%s) %d files, %d bytes planned, %d bytes of undo backups, %d bytes free",
+			fs.Path, fs.Type, fs.Files, fs.Planned, fs.Backup, fs.Free))
+	}
+	for _, problem := range rep.Problems {
+		if problem.Severity == preflight.SeverityWarning {
+			p.logger.Info("Preflight warning: " + problem.String())
+		}
+	}
+
+	err := rep.Err()
+	if err == nil {
+		return nil
+	}
+	if p.cfg.DryRun {
+		p.logger.Error("Preflight problems (dry run, continuing)", err)
+		return nil
+	}
+	return err
+}
+
+// redacted returns a copy of cfg without notifier credentials.
+func redacted(cfg *config.Config) config.Config {
+	out := *cfg
+	out.Notify = notify.Redact(cfg.Notify)
+	return out
+}
+
+// pruneBackups removes undo backups of runs older than maxAge.
+func (p *Pipeline) pruneBackups(maxAge time.Duration) {
+	pruned, err := journal.PruneBackups(p.cfg.Dest, maxAge)
+	if err != nil {
+		p.logger.Error("Failed to prune undo backups", err)
+	}
+	for _, dir := range pruned {
+		p.logger.Info("Pruned undo backups: " + dir)
+	}
+}
+
+// Run executes the pipeline. A failed run is published as an error event.
 func (p *Pipeline) Run() (*types.RunSummary, error) {
+	summary, err := p.run()
+	if err != nil {
+		p.emit(ProgressUpdate{Type: EventError, Error: err.Error()})
+	}
+	return summary, err
+}
+
+func (p *Pipeline) run() (*types.RunSummary, error) {
 	startTime := time.Now()
+	p.runID = history.NewID(startTime)
+
+	p.emit(ProgressUpdate{Type: EventRunStarted})
+	if p.cancelled.Load() {
+		return nil, p.stopErr()
+	}
 
 	p.logger.Info("Starting scan: '" + p.cfg.Source + "'")
 
-	if p.progressCallback != nil {
-		p.progressCallback(ProgressUpdate{
-			Type:    "status",
-			Message: "파일 스캔 중... (시간이 걸릴 수 있습니다)",
-		})
-	}
+	p.emit(ProgressUpdate{
+		Type:    EventStatus,
+		Message: "파일 스캔 중... (시간이 걸릴 수 있습니다)",
+	})
 
 	entries, err := p.scanner.Scan(p.cfg.Source)
 	if err != nil {
@@ -118,132 +400,218 @@ func (p *Pipeline) Run() (*types.RunSummary, error) {
 	}
 
 	p.logger.Info("Found " + strconv.Itoa(len(entries)) + " files")
-
-	fmt.Printf("DEBUG: Config IgnoreState=%v, DryRun=%v\n", p.cfg.IgnoreState, p.cfg.DryRun) // DEBUG
-
-	fmt.Println("DEBUG: Sending metadata analysis status...") // DEBUG
-	fmt.Println("DEBUG: Sending metadata analysis status...") // DEBUG
-	if p.progressCallback != nil {
-		p.progressCallback(ProgressUpdate{
-			Type:    "status",
-			Message: "메타데이터 분석 및 계획 수립 중...",
-			Total:   len(entries),
-		})
+	skipStats := p.scanner.SkipStats()
+	for rule, n := range skipStats {
+		p.logger.Info(fmt.Sprintf("Scanner skipped %d files (%s)", n, rule))
 	}
-	fmt.Println("DEBUG: Metadata status sent.") // DEBUG
+
+	p.emit(ProgressUpdate{
+		Type:    EventStatus,
+		Message: "메타데이터 분석 및 계획 수립 중...",
+		Total:   len(entries),
+	})
 
 	var tasks []types.CopyTask
 	var unclassifiedCount int
 	var filteredCount int
 
-	fmt.Printf("DEBUG: Starting loop for %d entries\n", len(entries)) // DEBUG
-	for i, entry := range entries {
-		// fmt.Printf("DEBUG: Processing entry %d: %s\n", i, entry.Path) // Too verbose
-		if i%100 == 0 {
-			fmt.Printf("DEBUG: Progress callback at %d\n", i) // DEBUG
-			if p.progressCallback != nil {
-				p.progressCallback(ProgressUpdate{
-					Type:    "analysis_progress",
-					Message: "메타데이터 분석 중...",
-					Current: i,
-					Total:   len(entries),
-				})
-			}
+	// Sidecars and RAW+JPG pairs are dated, planned and resolved as one shot
+	groups := scanner.GroupSidecars(entries)
+
+	p.logger.Info(fmt.Sprintf("Grouped %d files into %d shots", len(entries), len(groups)))
+
+	analyzed, nextReport := 0, 0
+	for _, group := range groups {
+		if p.cancelled.Load() {
+			return nil, p.stopErr()
 		}
+		if analyzed >= nextReport {
+			p.emit(ProgressUpdate{
+				Type:    EventAnalysisProgress,
+				Message: "메타데이터 분석 중...",
+				Current: analyzed,
+				Total:   len(entries),
+			})
+			nextReport = analyzed - analyzed%100 + 100
+		}
+		analyzed += len(group.Members)
 
-		if !p.cfg.IgnoreState && p.state.IsProcessed(entry.Path, entry.Size) {
+		var members []types.FileEntry
+		for _, entry := range group.Members {
+			if !p.cfg.IgnoreState && p.state.IsProcessed(entry.Path, entry.Size) {
+				continue
+			}
+			members = append(members, entry)
+		}
+		if len(members) == 0 {
 			continue
 		}
 
-		meta := p.meta.Extract(entry)
+		metas := p.groupMetadata(group)
 
-		// Date filter check (EXIF preferred, file mod time fallback)
-		if !p.shouldIncludeByDate(entry, meta) {
-			continue
-		}
+		var pending []*types.CopyTask
+		for _, entry := range members {
+			meta := metas[entry.Path]
+
+			// Date filter check (EXIF preferred, file mod time fallback)
+			if !p.shouldIncludeByDate(entry, meta) {
+				continue
+			}
 
-		filteredCount++
-		task := p.planner.Plan(entry, meta)
+			filteredCount++
+			task := p.planner.Plan(entry, meta)
 
-		if meta.CaptureTime == nil {
-			unclassifiedCount++
+			if meta.CaptureTime == nil {
+				unclassifiedCount++
+			}
+
+			// Skip duplicate check if IgnoreState is enabled
+			if !p.cfg.IgnoreState {
+				isDup, err := p.dedup.IsDuplicate(entry, task.DestPath)
+				if err == nil && isDup {
+					task.Status = types.TaskStatusSkipped
+					task.Action = types.CopyActionSkipped
+					p.emit(ProgressUpdate{Type: EventFileSkipped, Filename: entry.Name, Action: task.Action, Task: &task})
+					continue
+				}
+			}
+
+			// Near-duplicates go to the review folder rather than being skipped
+			if p.perceptual != nil {
+				match, ok, err := p.perceptual.Match(entry, group.Key)
+				if err != nil {
+					p.logger.Error("Perceptual hash failed: "+entry.Path, err)
+				} else if ok {
+					p.logger.Info(fmt.Sprintf("Near-duplicate: %s ~ %s (distance %d)", entry.Path, match.Path, match.Distance))
+					task.DestDir = filepath.Join(p.cfg.Dest, p.cfg.ReviewDir)
+					task.DestPath = p.conflict.UniqueName(filepath.Join(task.DestDir, entry.Name))
+					task.Action = types.CopyActionReview
+					tasks = append(tasks, task)
+					continue
+				}
+			}
+
+			pending = append(pending, &task)
 		}
 
-		// Skip duplicate check if IgnoreState is enabled
-		if !p.cfg.IgnoreState {
-			isDup, err := p.dedup.IsDuplicate(entry, task.DestPath)
-			if err == nil && isDup {
+		resolutions := p.conflict.ResolveGroup(group.Base, pending)
+		for i, task := range pending {
+			resolution := resolutions[i]
+			if resolution.Reason != "" {
+				p.logger.Info(fmt.Sprintf("Conflict %s (%s): %s", task.Source.Name, resolution.Action, resolution.Reason))
+			}
+			if resolution.Skip {
 				task.Status = types.TaskStatusSkipped
-				task.Action = types.CopyActionSkipped
+				task.Action = resolution.Action
+				task.ConflictPath = task.DestPath
+				task.ConflictReason = resolution.Reason
+				p.emit(ProgressUpdate{Type: EventFileSkipped, Filename: task.Source.Name, Action: task.Action, Task: task})
 				continue
 			}
-		}
 
-		resolution := p.conflict.Resolve(&task)
-		if resolution.Skip {
-			task.Status = types.TaskStatusSkipped
+			if resolution.Action != types.CopyActionCopied {
+				task.ConflictPath = task.DestPath
+				task.ConflictReason = resolution.Reason
+			}
+			task.DestPath = resolution.DestPath
 			task.Action = resolution.Action
-			continue
+			tasks = append(tasks, *task)
 		}
-
-		task.DestPath = resolution.DestPath
-		task.Action = resolution.Action
-		tasks = append(tasks, task)
 	}
 
 	// Ensure 100% analysis progress is sent
-	if p.progressCallback != nil {
-		p.progressCallback(ProgressUpdate{
-			Type:    "analysis_progress",
-			Message: "메타데이터 분석 완료",
-			Current: len(entries),
-			Total:   len(entries),
-		})
-	}
+	p.emit(ProgressUpdate{
+		Type:    EventAnalysisProgress,
+		Message: "메타데이터 분석 완료",
+		Current: len(entries),
+		Total:   len(entries),
+	})
 
 	summary := &types.RunSummary{
+		RunID:        p.runID,
 		ScannedFiles: len(entries),
 		TotalFiles:   filteredCount,
 		Unclassified: unclassifiedCount,
 		StartTime:    startTime,
+		ScanSkipped:  skipStats,
 	}
 
 	if len(tasks) == 0 {
 		summary.EndTime = time.Now()
 		summary.Duration = summary.EndTime.Sub(startTime)
-		p.logger.Summary(*summary)
 
 		// Wait a bit to ensure previous progress messages are sent
 		time.Sleep(100 * time.Millisecond)
 
-		if p.progressCallback != nil {
-			p.progressCallback(ProgressUpdate{
-				Type:    "complete",
-				Summary: summary,
-			})
-		}
+		p.emit(ProgressUpdate{
+			Type:    EventComplete,
+			Summary: summary,
+		})
 		return summary, nil
 	}
 
+	if !p.cfg.SkipPreflight {
+		if err := p.preflight(tasks); err != nil {
+			return nil, err
+		}
+	}
+
+	// Journal every destination change so the run can be undone
+	if !p.cfg.DryRun {
+		if p.cfg.UndoRetentionDays > 0 {
+			p.pruneBackups(time.Duration(p.cfg.UndoRetentionDays) * 24 * time.Hour)
+		}
+		j, err := journal.Create(journal.Path(p.history.Dir(), summary.RunID), p.cfg.Dest, journal.BackupDir(p.cfg.Dest, summary.RunID))
+		if err != nil {
+			p.logger.Error("Failed to create copy journal, undo will not be available", err)
+		} else {
+			defer j.Close()
+			p.copier.SetJournal(j)
+		}
+	}
+
 	resultChan := make(chan copier.CopyResult, len(tasks))
 	go p.copier.CopyAll(tasks, resultChan)
 
-	var bytesCopied int64
+	var bytesCopied, bytesFinished, bytesTotal int64
+	for _, task := range tasks {
+		bytesTotal += task.Source.Size
+	}
 	processed := 0
-
-	for result := range resultChan {
-		processed++
-		p.logger.Progress(processed, len(tasks), result.Task.Source.Name)
-
-		if p.progressCallback != nil {
-			p.progressCallback(ProgressUpdate{
-				Type:     "progress",
-				Current:  processed,
-				Total:    len(tasks),
-				Filename: result.Task.Source.Name,
-				Action:   result.Task.Action,
-			})
+	// Copied files by destination, in case they fail to sync later
+	copied := make(map[string]types.CopyTask)
+
+	// Report bytes between results so long copies still show progress
+	ticker := time.NewTicker(TransferInterval)
+	defer ticker.Stop()
+
+	for results := resultChan; results != nil; {
+		var result copier.CopyResult
+		select {
+		case <-ticker.C:
+			p.emitTransfer(processed, len(tasks), bytesFinished, bytesTotal)
+			continue
+		case r, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			result = r
 		}
+		processed++
+		bytesFinished += result.Task.Source.Size
+
+		limit, _ := p.copier.Limits()
+		p.emit(ProgressUpdate{
+			Type:       EventProgress,
+			Current:    processed,
+			Total:      len(tasks),
+			Filename:   result.Task.Source.Name,
+			Action:     result.Task.Action,
+			Throughput: p.copier.Throughput(),
+			Limit:      limit,
+			Task:       &result.Task,
+		})
 
 		switch result.Task.Action {
 		case types.CopyActionCopied:
@@ -260,19 +628,42 @@ func (p *Pipeline) Run() (*types.RunSummary, error) {
 		case types.CopyActionQuarantined:
 			summary.Quarantined++
 			bytesCopied += result.Task.Source.Size
+		case types.CopyActionReview:
+			summary.Review++
+			bytesCopied += result.Task.Source.Size
 		}
 
 		if result.Error != nil {
-			summary.Failed++
-			p.logger.LogTask(result.Task, 0)
+			countFailure(summary, result.Task.ErrorClass)
 		} else {
+			if result.Task.Action == types.CopyActionQuarantined && !p.cfg.DryRun {
+				p.recordQuarantine(result.Task)
+			}
+			if result.Task.Method != "" {
+				if summary.CopyMethods == nil {
+					summary.CopyMethods = make(map[types.CopyMethod]int)
+				}
+				summary.CopyMethods[result.Task.Method]++
+			}
 			if !p.cfg.DryRun {
 				p.state.MarkProcessed(result.Task.Source.Path, result.Task.Source.Size, result.Task.DestPath)
+				copied[result.Task.DestPath] = result.Task
 			}
-			p.logger.LogTask(result.Task, 0)
 		}
 	}
 
+	// Files that didn't reach the disk must not be recorded as processed
+	for _, syncErr := range p.copier.Flush() {
+		p.logger.Error("Copied file was not synced to disk", syncErr)
+		if task, ok := copied[syncErr.Path]; ok {
+			uncountCopy(summary, task)
+			bytesCopied -= task.Source.Size
+			p.state.Remove(task.Source.Path)
+			p.failResult(task.DestPath, syncErr)
+		}
+		countFailure(summary, types.ErrorClassDestIO)
+	}
+
 	summary.EndTime = time.Now()
 	summary.Duration = summary.EndTime.Sub(startTime)
 	summary.BytesCopied = bytesCopied
@@ -286,21 +677,195 @@ func (p *Pipeline) Run() (*types.RunSummary, error) {
 		}
 	}
 
-	p.logger.Summary(*summary)
+	if p.perceptual != nil {
+		if err := p.perceptual.Save(); err != nil {
+			p.logger.Error("Failed to save perceptual hash cache", err)
+		}
+	}
 
 	// Wait a bit to ensure previous progress messages are sent
 	time.Sleep(100 * time.Millisecond)
 
-	if p.progressCallback != nil {
-		p.progressCallback(ProgressUpdate{
-			Type:    "complete",
-			Summary: summary,
-		})
+	p.emit(ProgressUpdate{
+		Type:    EventComplete,
+		Summary: summary,
+	})
+
+	// A hook aborted the run; the files copied before are still recorded
+	if err := p.abortErr.Load(); err != nil {
+		return summary, *err
 	}
 
 	return summary, nil
 }
 
+// recordQuarantine adds a quarantined file to the manifest right away, so
+// files resolved in the web UI or CLI during the run are not overwritten.
+func (p *Pipeline) recordQuarantine(task types.CopyTask) {
+	err := quarantine.Update(filepath.Join(p.cfg.Dest, p.cfg.QuarantineDir), func(m *quarantine.Manifest) error {
+		m.Add(quarantine.Entry{
+			QuarantinePath: task.DestPath,
+			DestPath:       task.ConflictPath,
+			SourcePath:     task.Source.Path,
+			ConflictPath:   task.ConflictPath,
+			Reason:         task.ConflictReason,
+			Size:           task.Source.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		p.logger.Error("Failed to record quarantined file: "+task.DestPath, err)
+	}
+}
+
+// failResult marks the recorded result for dest as failed, so the run
+// history and reports show files that failed after their progress event.
+func (p *Pipeline) failResult(dest string, err error) {
+	for i := range p.results {
+		if p.results[i].Dest == dest {
+			p.results[i].Status = types.TaskStatusFailed
+			p.results[i].Error = err.Error()
+			p.results[i].ErrorClass = types.ErrorClassDestIO
+		}
+	}
+}
+
+// logEvent writes file results and the summary to the log.
+func (p *Pipeline) logEvent(e ProgressUpdate) {
+	switch e.Type {
+	case EventProgress:
+		p.logger.Progress(e.Current, e.Total, e.Filename)
+		if e.Task != nil {
+			p.logger.LogTask(*e.Task, e.Task.Duration)
+		}
+	case EventTransfer:
+		p.logger.Transfer(e.Current, e.Total, e.Throughput, *e.Transfer)
+	case EventComplete:
+		p.logger.Summary(*e.Summary)
+	case EventError:
+		p.logger.Error("Run failed", errors.New(e.Error))
+	}
+}
+
+// recordEvent collects file results and saves the run history when the run
+// completes.
+func (p *Pipeline) recordEvent(e ProgressUpdate) {
+	switch e.Type {
+	case EventProgress, EventFileSkipped:
+		if e.Task != nil {
+			p.results = append(p.results, history.ResultFromTask(*e.Task))
+		}
+	case EventComplete:
+		p.saveHistory(e.Summary)
+	}
+}
+
+// hookEvent runs the configured hooks. A hook with the abort policy that
+// fails stops the run.
+func (p *Pipeline) hookEvent(e ProgressUpdate) {
+	payload := hooks.Payload{
+		RunID:  e.RunID,
+		Source: p.cfg.Source,
+		Dest:   p.cfg.Dest,
+		DryRun: p.cfg.DryRun,
+	}
+
+	var events []string
+	switch e.Type {
+	case EventRunStarted:
+		events = []string{hooks.PreRun}
+	case EventProgress:
+		if e.Task == nil {
+			return
+		}
+		payload.Task = hooks.NewTask(e.Task)
+		payload.Error = e.Task.Error
+		events = []string{hooks.PostFile}
+		if e.Task.Error != "" {
+			events = append(events, hooks.OnError)
+		}
+	case EventComplete:
+		payload.Summary = e.Summary
+		events = []string{hooks.PostRun}
+	case EventError:
+		payload.Error = e.Error
+		events = []string{hooks.OnError}
+	}
+
+	for _, event := range events {
+		if !p.hooks.Has(event) {
+			continue
+		}
+		payload.Event = event
+		if err := p.hooks.Fire(payload); err != nil {
+			p.logger.Error("Aborting run", err)
+			p.Abort(err)
+			return
+		}
+	}
+}
+
+// notifyEvent sends notifications when the run completes or fails.
+func (p *Pipeline) notifyEvent(e ProgressUpdate) {
+	if p.cfg.Notify == nil {
+		return
+	}
+
+	n := notify.Notification{
+		RunID:   e.RunID,
+		Source:  p.cfg.Source,
+		Dest:    p.cfg.Dest,
+		Summary: e.Summary,
+	}
+	switch e.Type {
+	case EventComplete:
+		// An aborted run is reported by the error event that follows
+		if p.abortErr.Load() != nil {
+			return
+		}
+		n.Status = notify.StatusCompleted
+	case EventError:
+		n.Status = notify.StatusFailed
+		n.Error = e.Error
+	default:
+		return
+	}
+
+	for _, err := range notify.Send(p.cfg.Notify, n) {
+		p.logger.Error("Notification failed", err)
+	}
+}
+
+// saveHistory records the run with its config snapshot and file results and
+// writes the ingest reports next to the destination.
+func (p *Pipeline) saveHistory(summary *types.RunSummary) {
+	rec := &history.Record{
+		Run: history.Run{
+			ID:      summary.RunID,
+			Source:  p.cfg.Source,
+			Dest:    p.cfg.Dest,
+			DryRun:  p.cfg.DryRun,
+			Summary: *summary,
+		},
+		Config: redacted(p.cfg),
+		Files:  p.results,
+	}
+	if err := p.history.Save(rec); err != nil {
+		p.logger.Error("Failed to save run history", err)
+	}
+
+	if p.cfg.DryRun || len(p.cfg.ReportFormats) == 0 {
+		return
+	}
+	paths, err := report.Build(rec).WriteFiles(filepath.Join(p.cfg.Dest, p.cfg.ReportDir), p.cfg.ReportFormats)
+	if err != nil {
+		p.logger.Error("Failed to write report", err)
+	}
+	for _, path := range paths {
+		p.logger.Info("Report written: " + path)
+	}
+}
+
 func (p *Pipeline) Close() error {
 	return p.logger.Close()
 }
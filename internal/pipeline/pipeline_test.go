@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/journal"
+	"github.com/On-Jun9/ShutterPipe/internal/notify"
+	"github.com/On-Jun9/ShutterPipe/internal/state"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Source = filepath.Join(dir, "card")
+	cfg.Dest = filepath.Join(dir, "nas")
+	cfg.StateFile = filepath.Join(dir, "data", "state.json")
+	cfg.LogFile = filepath.Join(dir, "data", "shutterpipe.log")
+	cfg.HistoryDir = filepath.Join(dir, "history")
+	if err := os.MkdirAll(cfg.Dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func run(t *testing.T, cfg *config.Config) *types.RunSummary {
+	t.Helper()
+	if err := Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	summary, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(cfg *config.Config){
+		"hooks":     func(cfg *config.Config) { cfg.Hooks.PreRun = []types.Hook{{Command: " "}} },
+		"notify":    func(cfg *config.Config) { cfg.Notify = &types.NotifyConfig{When: "sometimes"} },
+		"fsync":     func(cfg *config.Config) { cfg.Fsync = "eventually" },
+		"preserve":  func(cfg *config.Config) { cfg.Preserve = []string{"owner"} },
+		"bandwidth": func(cfg *config.Config) { cfg.Bandwidth.Limit = -1 },
+	}
+	for field, breakCfg := range tests {
+		cfg := testConfig(t)
+		breakCfg(cfg)
+		err := Validate(cfg)
+		var verr *config.ValidationError
+		if !errors.As(err, &verr) || verr.Field != field {
+			t.Errorf("%s: error = %v, want a validation error for the field", field, err)
+		}
+	}
+}
+
+func TestRun_ScannerFilters(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DryRun = true
+	cfg.CardMode = true
+	cfg.ExcludePatterns = []string{"*_tmp.jpg"}
+	cfg.MinFileSize = 10
+
+	writeFile(t, filepath.Join(cfg.Source, "DCIM", "100MSDCF", "DSC00001.JPG"), 100)
+	writeFile(t, filepath.Join(cfg.Source, "DCIM", "100MSDCF", "DSC00002_tmp.jpg"), 100)
+	writeFile(t, filepath.Join(cfg.Source, "DCIM", "100MSDCF", "DSC00003.JPG"), 1)
+	writeFile(t, filepath.Join(cfg.Source, "Desktop", "wallpaper.jpg"), 100)
+
+	summary := run(t, cfg)
+	if summary.ScannedFiles != 1 {
+		t.Errorf("scanned %d files, want 1 (skipped %v)", summary.ScannedFiles, summary.ScanSkipped)
+	}
+}
+
+func TestRun_HistoryDir(t *testing.T) {
+	cfg := testConfig(t)
+	writeFile(t, filepath.Join(cfg.Source, "DCIM", "DSC00001.JPG"), 100)
+
+	summary := run(t, cfg)
+	if summary.Copied != 1 {
+		t.Fatalf("copied %d files, want 1", summary.Copied)
+	}
+
+	// History and journal go to the history dir, not next to the state file
+	runs, err := history.NewStore(cfg.HistoryDir).List()
+	if err != nil || len(runs) != 1 || runs[0].ID != summary.RunID {
+		t.Fatalf("runs in history dir: %v (%v)", runs, err)
+	}
+	if _, err := os.Stat(journal.Path(cfg.HistoryDir, summary.RunID)); err != nil {
+		t.Errorf("journal: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(cfg.StateFile), "runs")); !os.IsNotExist(err) {
+		t.Errorf("history written next to the state file: %v", err)
+	}
+}
+
+func TestRun_HistoryRedactsNotify(t *testing.T) {
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(notify.SignatureHeader)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t)
+	cfg.Notify = &types.NotifyConfig{Webhooks: []types.WebhookNotify{{URL: srv.URL, Secret: "s3cret"}}}
+	writeFile(t, filepath.Join(cfg.Source, "DCIM", "DSC00001.JPG"), 100)
+	summary := run(t, cfg)
+
+	// The secret is still used to sign but is not kept in the snapshot
+	if signature == "" {
+		t.Error("webhook was not signed")
+	}
+	rec, err := history.NewStore(cfg.HistoryDir).Get(summary.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Config.Notify == nil || rec.Config.Notify.Webhooks[0].Secret != "" {
+		t.Errorf("history snapshot notify = %+v", rec.Config.Notify)
+	}
+}
+
+func TestRun_SyncFailure(t *testing.T) {
+	cfg := testConfig(t)
+	lost := filepath.Join(cfg.Source, "DCIM", "DSC00001.JPG")
+	kept := filepath.Join(cfg.Source, "DCIM", "DSC00002.JPG")
+	writeFile(t, lost, 100)
+	writeFile(t, kept, 200)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// A copy that vanishes before the batch sync fails to sync
+	p.Events().Subscribe(func(e ProgressUpdate) {
+		if e.Type == EventProgress && e.Task != nil && e.Task.Source.Path == lost {
+			os.Remove(e.Task.DestPath)
+		}
+	})
+	summary, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := 0
+	for _, n := range summary.CopyMethods {
+		methods += n
+	}
+	if summary.Copied != 1 || summary.Failed != 1 || methods != 1 || summary.BytesCopied != 200 {
+		t.Errorf("copied %d, failed %d, methods %v, bytes %d; want 1, 1, 1 method, 200",
+			summary.Copied, summary.Failed, summary.CopyMethods, summary.BytesCopied)
+	}
+
+	rec, err := history.NewStore(cfg.HistoryDir).Get(summary.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range rec.Files {
+		failed := f.Status == types.TaskStatusFailed && f.Error != "" && f.ErrorClass == types.ErrorClassDestIO
+		if failed != (f.Source == lost) {
+			t.Errorf("%s: status %q, error %q, class %q", filepath.Base(f.Source), f.Status, f.Error, f.ErrorClass)
+		}
+	}
+
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.IsProcessed(lost, 100) || !st.IsProcessed(kept, 200) {
+		t.Error("state does not match the synced files")
+	}
+}
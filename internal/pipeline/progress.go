@@ -1,11 +1,71 @@
 package pipeline
 
-import "github.com/On-Jun9/ShutterPipe/pkg/types"
+import (
+	"sync"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Event types published on the pipeline's event bus.
+const (
+	EventRunStarted       = "run_started"
+	EventStatus           = "status"
+	EventAnalysisProgress = "analysis_progress"
+	// EventFileSkipped is published for files skipped while planning
+	// (duplicates, conflict skips) that never reach the copier.
+	EventFileSkipped = "file_skipped"
+	// EventProgress is published for each finished copy task.
+	EventProgress = "progress"
+	// EventTransfer reports byte-level progress, throughput and limits at
+	// most every TransferInterval while copying.
+	EventTransfer = "transfer"
+	// EventBandwidth reports the limits when they change.
+	EventBandwidth = "bandwidth"
+	EventComplete  = "complete"
+	EventError     = "error"
+)
+
+// Run phases, as derived from events by Phase.
+const (
+	PhaseIdle      = "idle"
+	PhaseStarting  = "starting"
+	PhaseAnalyzing = "analyzing"
+	PhaseCopying   = "copying"
+	PhaseComplete  = "complete"
+	PhaseFailed    = "failed"
+)
+
+// Phases lists every run phase in order.
+var Phases = []string{PhaseIdle, PhaseStarting, PhaseAnalyzing, PhaseCopying, PhaseComplete, PhaseFailed}
+
+// Phase returns the phase a run enters with an event of the given type, or
+// "" if the event doesn't change it.
+func Phase(eventType string) string {
+	switch eventType {
+	case EventRunStarted:
+		return PhaseStarting
+	case EventAnalysisProgress:
+		return PhaseAnalyzing
+	case EventProgress, EventTransfer:
+		return PhaseCopying
+	case EventComplete:
+		return PhaseComplete
+	case EventError:
+		return PhaseFailed
+	}
+	return ""
+}
+
+// TransferInterval is how often EventTransfer is published, so long copies
+// show progress without flooding subscribers.
+const TransferInterval = 500 * time.Millisecond
 
 type ProgressCallback func(update ProgressUpdate)
 
 type ProgressUpdate struct {
 	Type     string            `json:"type"`
+	RunID    string            `json:"run_id,omitempty"`
 	Message  string            `json:"message,omitempty"`
 	Current  int               `json:"current,omitempty"`
 	Total    int               `json:"total,omitempty"`
@@ -13,4 +73,64 @@ type ProgressUpdate struct {
 	Action   types.CopyAction  `json:"action,omitempty"`
 	Summary  *types.RunSummary `json:"summary,omitempty"`
 	Error    string            `json:"error,omitempty"`
+	// Throughput is the smoothed copy rate in bytes per second; Limit and
+	// PerWorker are the current limits (0 = unlimited).
+	Throughput float64 `json:"throughput,omitempty"`
+	Limit      int64   `json:"limit,omitempty"`
+	PerWorker  int64   `json:"per_worker,omitempty"`
+	// Transfer is the byte-level progress for transfer events.
+	Transfer *types.TransferProgress `json:"transfer,omitempty"`
+	// Task is the finished task for file events.
+	Task *types.CopyTask `json:"-"`
+}
+
+// Bus delivers pipeline events to subscribers synchronously, in the order
+// they subscribed.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []subscriber
+}
+
+type subscriber struct {
+	id int
+	fn ProgressCallback
+}
+
+// NewBus creates an empty event bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers fn for all events and returns a function that removes
+// it again.
+func (b *Bus) Subscribe(fn ProgressCallback) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subs = append(b.subs, subscriber{id: id, fn: fn})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subs {
+			if s.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish sends an event to every subscriber.
+func (b *Bus) Publish(update ProgressUpdate) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		s.fn(update)
+	}
 }
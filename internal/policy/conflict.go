@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/On-Jun9/ShutterPipe/pkg/types"
 )
@@ -12,12 +13,17 @@ import (
 type ConflictResolver struct {
 	policy        types.ConflictPolicy
 	quarantineDir string
+	// reserved holds names handed out by UniqueName and ResolveGroup. Files
+	// are only copied after the whole batch is planned, so they don't exist on
+	// disk yet.
+	reserved map[string]bool
 }
 
 func NewConflictResolver(policy types.ConflictPolicy, quarantineDir string) *ConflictResolver {
 	return &ConflictResolver{
 		policy:        policy,
 		quarantineDir: quarantineDir,
+		reserved:      make(map[string]bool),
 	}
 }
 
@@ -25,10 +31,13 @@ type Resolution struct {
 	Action   types.CopyAction
 	DestPath string
 	Skip     bool
+	// Reason describes the attributes compared by content-aware policies.
+	Reason string
 }
 
 func (c *ConflictResolver) Resolve(task *types.CopyTask) Resolution {
-	if _, err := os.Stat(task.DestPath); os.IsNotExist(err) {
+	existing, err := os.Stat(task.DestPath)
+	if os.IsNotExist(err) {
 		return Resolution{Action: types.CopyActionCopied, DestPath: task.DestPath}
 	}
 
@@ -46,13 +55,90 @@ func (c *ConflictResolver) Resolve(task *types.CopyTask) Resolution {
 	case types.ConflictPolicyQuarantine:
 		quarantinePath := filepath.Join(c.quarantineDir, task.Source.Name)
 		quarantinePath = c.generateUniqueName(quarantinePath)
-		return Resolution{Action: types.CopyActionQuarantined, DestPath: quarantinePath}
+		reason := fmt.Sprintf("quarantine: source size=%d, existing size=%d", task.Source.Size, existing.Size())
+		return Resolution{Action: types.CopyActionQuarantined, DestPath: quarantinePath, Reason: reason}
+
+	case types.ConflictPolicyKeepNewer:
+		reason := fmt.Sprintf("keep-newer: source mtime=%s, existing mtime=%s",
+			task.Source.ModTime.Format(time.RFC3339), existing.ModTime().Format(time.RFC3339))
+		if task.Source.ModTime.After(existing.ModTime()) {
+			return Resolution{Action: types.CopyActionOverwritten, DestPath: task.DestPath, Reason: reason}
+		}
+		return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: reason}
+
+	case types.ConflictPolicyKeepLarger:
+		reason := fmt.Sprintf("keep-larger: source size=%d, existing size=%d", task.Source.Size, existing.Size())
+		if task.Source.Size > existing.Size() {
+			return Resolution{Action: types.CopyActionOverwritten, DestPath: task.DestPath, Reason: reason}
+		}
+		return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: reason}
+
+	case types.ConflictPolicyRenameHash:
+		srcHash, err := hashFile(task.Source.Path)
+		if err != nil {
+			return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: "rename-hash: " + err.Error()}
+		}
+		newPath := hashSuffixedName(task.DestPath, srcHash)
+		reason := fmt.Sprintf("rename-hash: source sha256=%s", srcHash[:shortHashLen])
+		if _, err := os.Stat(newPath); err == nil {
+			// Same name and hash means this content was already stored
+			return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: reason + ", already present as " + filepath.Base(newPath)}
+		}
+		return Resolution{Action: types.CopyActionRenamed, DestPath: newPath, Reason: reason}
+
+	case types.ConflictPolicyIdenticalSkipElseRename:
+		srcHash, err := hashFile(task.Source.Path)
+		if err != nil {
+			return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: "identical-skip-else-rename: " + err.Error()}
+		}
+		destHash, err := hashFile(task.DestPath)
+		if err != nil {
+			return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: "identical-skip-else-rename: " + err.Error()}
+		}
+		reason := fmt.Sprintf("identical-skip-else-rename: source sha256=%s, existing sha256=%s",
+			srcHash[:shortHashLen], destHash[:shortHashLen])
+		if srcHash == destHash {
+			return Resolution{Action: types.CopyActionSkipped, Skip: true, Reason: reason}
+		}
+		newPath := c.generateUniqueName(task.DestPath)
+		return Resolution{Action: types.CopyActionRenamed, DestPath: newPath, Reason: reason}
 
 	default:
 		return Resolution{Action: types.CopyActionSkipped, Skip: true}
 	}
 }
 
+// shortHashLen is the number of hex digits used for hash-suffixed names.
+const shortHashLen = 8
+
+// hashSuffixedName turns "dir/IMG_0001.jpg" into "dir/IMG_0001_<hash>.jpg".
+func hashSuffixedName(path, hash string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf("%s_%s%s", base, hash[:shortHashLen], ext))
+}
+
+// UniqueName returns path, or the first free "_N" variant of it if a file
+// already exists there or the name was returned before. The name is reserved
+// so later tasks of the same batch don't get it again.
+func (c *ConflictResolver) UniqueName(path string) string {
+	name := path
+	if c.taken(path) {
+		name = c.generateUniqueName(path)
+	}
+	c.reserved[name] = true
+	return name
+}
+
+// taken reports whether a file exists at path or the name is reserved.
+func (c *ConflictResolver) taken(path string) bool {
+	if c.reserved[path] {
+		return true
+	}
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
+
 func (c *ConflictResolver) generateUniqueName(path string) string {
 	dir := filepath.Dir(path)
 	ext := filepath.Ext(path)
@@ -61,10 +147,119 @@ func (c *ConflictResolver) generateUniqueName(path string) string {
 	for i := 1; i < 10000; i++ {
 		newName := fmt.Sprintf("%s_%d%s", base, i, ext)
 		newPath := filepath.Join(dir, newName)
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
+		if !c.taken(newPath) {
 			return newPath
 		}
 	}
 
 	return path
 }
+
+// ResolveGroup resolves conflicts for tasks that belong to one shot. With the
+// rename policies every member gets the same suffix, so a RAW+JPG pair stays
+// paired as "IMG_0001_1.ARW" and "IMG_0001_1.JPG". With rename-hash a member
+// whose shared-suffix name is already used by other content falls back to a
+// numbered variant of it. base is the group's shared base name; each member
+// name must start with it.
+func (c *ConflictResolver) ResolveGroup(base string, tasks []*types.CopyTask) []Resolution {
+	resolutions := make([]Resolution, len(tasks))
+	renamed := false
+	for i, task := range tasks {
+		resolutions[i] = c.Resolve(task)
+		if resolutions[i].Action == types.CopyActionRenamed {
+			renamed = true
+		}
+	}
+
+	if len(tasks) < 2 || !renamed {
+		return resolutions
+	}
+
+	var suffixFor func(i int) string
+	switch c.policy {
+	case types.ConflictPolicyRename:
+		n := c.groupSuffixIndex(base, tasks)
+		if n == 0 {
+			return resolutions
+		}
+		suffixFor = func(int) string { return fmt.Sprintf("_%d", n) }
+	case types.ConflictPolicyRenameHash:
+		// Use the primary member's hash for all members
+		hash, err := hashFile(tasks[0].Source.Path)
+		if err != nil {
+			return resolutions
+		}
+		suffixFor = func(int) string { return "_" + hash[:shortHashLen] }
+	default:
+		return resolutions
+	}
+
+	for i, task := range tasks {
+		if resolutions[i].Skip {
+			continue
+		}
+		newPath, ok := groupMemberPath(task.DestPath, base, suffixFor(i))
+		if !ok {
+			continue
+		}
+		if c.policy == types.ConflictPolicyRenameHash && c.taken(newPath) {
+			// The shared name only proves the primary's content is stored,
+			// so compare this member before reusing it
+			if sameContent(task.Source.Path, newPath) {
+				resolutions[i] = Resolution{Action: types.CopyActionSkipped, Skip: true,
+					Reason: "rename-hash: already present as " + filepath.Base(newPath)}
+				continue
+			}
+			newPath = c.generateUniqueName(newPath)
+		}
+		c.reserved[newPath] = true
+		resolutions[i].Action = types.CopyActionRenamed
+		resolutions[i].DestPath = newPath
+		if resolutions[i].Reason == "" {
+			resolutions[i].Reason = "renamed with its group " + base
+		}
+	}
+	return resolutions
+}
+
+// groupSuffixIndex returns the smallest N for which no member's "_N" name is
+// taken, or 0 if none is found.
+func (c *ConflictResolver) groupSuffixIndex(base string, tasks []*types.CopyTask) int {
+	for n := 1; n < 10000; n++ {
+		free := true
+		for _, task := range tasks {
+			path, ok := groupMemberPath(task.DestPath, base, fmt.Sprintf("_%d", n))
+			if !ok {
+				continue
+			}
+			if c.taken(path) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return n
+		}
+	}
+	return 0
+}
+
+// sameContent reports whether the files at a and b have the same SHA-256.
+func sameContent(a, b string) bool {
+	hashA, err := hashFile(a)
+	if err != nil {
+		return false
+	}
+	hashB, err := hashFile(b)
+	return err == nil && hashA == hashB
+}
+
+// groupMemberPath inserts suffix after the group base in the file name:
+// "C0001M01.XML" with base "C0001" becomes "C0001_1M01.XML".
+func groupMemberPath(path, base, suffix string) (string, bool) {
+	name := filepath.Base(path)
+	if len(name) < len(base) || !strings.EqualFold(name[:len(base)], base) {
+		return path, false
+	}
+	return filepath.Join(filepath.Dir(path), name[:len(base)]+suffix+name[len(base):]), true
+}
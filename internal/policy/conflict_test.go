@@ -75,3 +75,191 @@ func TestConflictResolver_Rename(t *testing.T) {
 		t.Errorf("expected %s, got %s", expected, res.DestPath)
 	}
 }
+
+func TestConflictResolver_KeepLarger(t *testing.T) {
+	tmpDir := t.TempDir()
+	existingFile := filepath.Join(tmpDir, "photo.jpg")
+	os.WriteFile(existingFile, []byte("existing"), 0644)
+
+	resolver := NewConflictResolver(types.ConflictPolicyKeepLarger, filepath.Join(tmpDir, "quarantine"))
+
+	smaller := &types.CopyTask{
+		Source:   types.FileEntry{Name: "photo.jpg", Size: 3},
+		DestPath: existingFile,
+	}
+	if res := resolver.Resolve(smaller); !res.Skip || res.Reason == "" {
+		t.Errorf("expected skip with reason for smaller source, got %+v", res)
+	}
+
+	larger := &types.CopyTask{
+		Source:   types.FileEntry{Name: "photo.jpg", Size: 100},
+		DestPath: existingFile,
+	}
+	res := resolver.Resolve(larger)
+	if res.Skip || res.Action != types.CopyActionOverwritten {
+		t.Errorf("expected overwrite for larger source, got %+v", res)
+	}
+}
+
+func TestConflictResolver_IdenticalSkipElseRename(t *testing.T) {
+	tmpDir := t.TempDir()
+	existingFile := filepath.Join(tmpDir, "photo.jpg")
+	os.WriteFile(existingFile, []byte("existing"), 0644)
+
+	sameFile := filepath.Join(tmpDir, "same.jpg")
+	os.WriteFile(sameFile, []byte("existing"), 0644)
+	otherFile := filepath.Join(tmpDir, "other.jpg")
+	os.WriteFile(otherFile, []byte("different"), 0644)
+
+	resolver := NewConflictResolver(types.ConflictPolicyIdenticalSkipElseRename, filepath.Join(tmpDir, "quarantine"))
+
+	res := resolver.Resolve(&types.CopyTask{
+		Source:   types.FileEntry{Name: "photo.jpg", Path: sameFile},
+		DestPath: existingFile,
+	})
+	if !res.Skip {
+		t.Errorf("expected skip for identical content, got %+v", res)
+	}
+
+	res = resolver.Resolve(&types.CopyTask{
+		Source:   types.FileEntry{Name: "photo.jpg", Path: otherFile},
+		DestPath: existingFile,
+	})
+	if res.Skip || res.DestPath != filepath.Join(tmpDir, "photo_1.jpg") {
+		t.Errorf("expected rename to photo_1.jpg, got %+v", res)
+	}
+}
+
+func TestConflictResolver_RenameHash(t *testing.T) {
+	tmpDir := t.TempDir()
+	existingFile := filepath.Join(tmpDir, "photo.jpg")
+	os.WriteFile(existingFile, []byte("existing"), 0644)
+
+	srcFile := filepath.Join(tmpDir, "src.jpg")
+	os.WriteFile(srcFile, []byte("different"), 0644)
+
+	resolver := NewConflictResolver(types.ConflictPolicyRenameHash, filepath.Join(tmpDir, "quarantine"))
+	task := &types.CopyTask{
+		Source:   types.FileEntry{Name: "photo.jpg", Path: srcFile},
+		DestPath: existingFile,
+	}
+
+	first := resolver.Resolve(task)
+	if first.Skip || first.Action != types.CopyActionRenamed {
+		t.Fatalf("expected rename, got %+v", first)
+	}
+
+	// The name is derived from content, so a second run yields the same name
+	second := resolver.Resolve(task)
+	if second.DestPath != first.DestPath {
+		t.Errorf("expected stable name %s, got %s", first.DestPath, second.DestPath)
+	}
+
+	os.WriteFile(first.DestPath, []byte("different"), 0644)
+	if res := resolver.Resolve(task); !res.Skip {
+		t.Errorf("expected skip once hashed name exists, got %+v", res)
+	}
+}
+
+func TestConflictResolver_ResolveGroupSharedSuffix(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Only the JPG conflicts, and "_1" is taken for the RAW
+	os.WriteFile(filepath.Join(tmpDir, "IMG_0001.JPG"), []byte("existing"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "IMG_0001_1.ARW"), []byte("existing"), 0644)
+
+	resolver := NewConflictResolver(types.ConflictPolicyRename, filepath.Join(tmpDir, "quarantine"))
+	tasks := []*types.CopyTask{
+		{Source: types.FileEntry{Name: "IMG_0001.ARW"}, DestPath: filepath.Join(tmpDir, "IMG_0001.ARW")},
+		{Source: types.FileEntry{Name: "IMG_0001.JPG"}, DestPath: filepath.Join(tmpDir, "IMG_0001.JPG")},
+	}
+
+	res := resolver.ResolveGroup("IMG_0001", tasks)
+
+	if res[0].DestPath != filepath.Join(tmpDir, "IMG_0001_2.ARW") {
+		t.Errorf("unexpected RAW path %s", res[0].DestPath)
+	}
+	if res[1].DestPath != filepath.Join(tmpDir, "IMG_0001_2.JPG") {
+		t.Errorf("unexpected JPG path %s", res[1].DestPath)
+	}
+}
+
+func TestConflictResolver_UniqueNameReserves(t *testing.T) {
+	tmpDir := t.TempDir()
+	resolver := NewConflictResolver(types.ConflictPolicySkip, filepath.Join(tmpDir, "quarantine"))
+
+	path := filepath.Join(tmpDir, "review", "photo.jpg")
+	first := resolver.UniqueName(path)
+	second := resolver.UniqueName(path)
+
+	if first != path {
+		t.Errorf("first name = %s, want %s", first, path)
+	}
+	if want := filepath.Join(tmpDir, "review", "photo_1.jpg"); second != want {
+		t.Errorf("second name = %s, want %s", second, want)
+	}
+}
+
+func TestConflictResolver_ResolveGroupRenameHashTaken(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dest")
+	os.MkdirAll(srcDir, 0755)
+	os.MkdirAll(destDir, 0755)
+
+	rawSrc := filepath.Join(srcDir, "IMG_0001.ARW")
+	jpgSrc := filepath.Join(srcDir, "IMG_0001.JPG")
+	os.WriteFile(rawSrc, []byte("raw"), 0644)
+	os.WriteFile(jpgSrc, []byte("jpg"), 0644)
+	os.WriteFile(filepath.Join(destDir, "IMG_0001.ARW"), []byte("other raw"), 0644)
+	os.WriteFile(filepath.Join(destDir, "IMG_0001.JPG"), []byte("other jpg"), 0644)
+
+	hash, err := hashFile(rawSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The JPG's shared-suffix name already holds different content
+	sharedJPG := filepath.Join(destDir, "IMG_0001_"+hash[:shortHashLen]+".JPG")
+	os.WriteFile(sharedJPG, []byte("unrelated"), 0644)
+
+	resolver := NewConflictResolver(types.ConflictPolicyRenameHash, filepath.Join(tmpDir, "quarantine"))
+	tasks := []*types.CopyTask{
+		{Source: types.FileEntry{Name: "IMG_0001.ARW", Path: rawSrc}, DestPath: filepath.Join(destDir, "IMG_0001.ARW")},
+		{Source: types.FileEntry{Name: "IMG_0001.JPG", Path: jpgSrc}, DestPath: filepath.Join(destDir, "IMG_0001.JPG")},
+	}
+
+	res := resolver.ResolveGroup("IMG_0001", tasks)
+	if want := filepath.Join(destDir, "IMG_0001_"+hash[:shortHashLen]+".ARW"); res[0].DestPath != want {
+		t.Errorf("RAW path = %s, want %s", res[0].DestPath, want)
+	}
+	if want := filepath.Join(destDir, "IMG_0001_"+hash[:shortHashLen]+"_1.JPG"); res[1].Skip || res[1].DestPath != want {
+		t.Errorf("JPG resolution = %+v, want rename to %s", res[1], want)
+	}
+
+	// Once the group is stored, re-ingesting it skips every member
+	os.WriteFile(res[0].DestPath, []byte("raw"), 0644)
+	os.WriteFile(sharedJPG, []byte("jpg"), 0644)
+	res = NewConflictResolver(types.ConflictPolicyRenameHash, filepath.Join(tmpDir, "quarantine")).ResolveGroup("IMG_0001", tasks)
+	for i, r := range res {
+		if !r.Skip {
+			t.Errorf("member %d: expected skip on re-ingest, got %+v", i, r)
+		}
+	}
+}
+
+func TestConflictResolver_ResolveGroupSkipsReservedSuffix(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "IMG_0001.JPG"), []byte("existing"), 0644)
+
+	resolver := NewConflictResolver(types.ConflictPolicyRename, filepath.Join(tmpDir, "quarantine"))
+	// An earlier task of the batch was given "_1" for the RAW
+	resolver.UniqueName(filepath.Join(tmpDir, "IMG_0001_1.ARW"))
+	tasks := []*types.CopyTask{
+		{Source: types.FileEntry{Name: "IMG_0001.ARW"}, DestPath: filepath.Join(tmpDir, "IMG_0001.ARW")},
+		{Source: types.FileEntry{Name: "IMG_0001.JPG"}, DestPath: filepath.Join(tmpDir, "IMG_0001.JPG")},
+	}
+
+	res := resolver.ResolveGroup("IMG_0001", tasks)
+	if want := filepath.Join(tmpDir, "IMG_0001_2.JPG"); res[1].DestPath != want {
+		t.Errorf("JPG path = %s, want %s", res[1].DestPath, want)
+	}
+}
@@ -26,7 +26,9 @@ func (d *DedupChecker) IsDuplicate(src types.FileEntry, destPath string) (bool,
 		return false, err
 	}
 
-	if d.method == types.DedupMethodNameSize {
+	// Perceptual mode leaves near-duplicates to PerceptualIndex and only
+	// needs the cheap check for files already at their destination
+	if d.method == types.DedupMethodNameSize || d.method == types.DedupMethodPerceptual {
 		return src.Size == destInfo.Size(), nil
 	}
 
@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestDedupChecker_Methods(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.jpg")
+	dest := filepath.Join(dir, "dest.jpg")
+	os.WriteFile(src, []byte("aaaa"), 0644)
+	os.WriteFile(dest, []byte("bbbb"), 0644)
+	entry := types.FileEntry{Path: src, Size: 4}
+
+	// Same name and size but different content: only hashing tells them apart
+	for method, want := range map[types.DedupMethod]bool{
+		types.DedupMethodNameSize:   true,
+		types.DedupMethodPerceptual: true,
+		types.DedupMethodHash:       false,
+	} {
+		got, err := NewDedupChecker(method).IsDuplicate(entry, dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: duplicate = %v, want %v", method, got, want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package policy
+
+import (
+	"encoding/json"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/On-Jun9/ShutterPipe/internal/metadata/preview"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// PerceptualMatch describes a library image that looks like a candidate.
+type PerceptualMatch struct {
+	Path     string
+	Distance int
+}
+
+type perceptualEntry struct {
+	path string
+	hash uint64
+	// group is the FileGroup key of a file from the current batch. Library
+	// files have none.
+	group string
+}
+
+// PerceptualIndex detects near-duplicate images using a 64-bit difference
+// hash (dHash). The library under root is hashed lazily on first use.
+type PerceptualIndex struct {
+	root      string
+	skipDirs  []string
+	threshold int
+	cache     *perceptualCache
+
+	mu      sync.Mutex
+	built   bool
+	entries []perceptualEntry
+}
+
+// NewPerceptualIndex creates an index over the library at root. Directories in
+// skipDirs (relative to root) are not indexed. cachePath may be empty to
+// disable the on-disk hash cache.
+func NewPerceptualIndex(root string, skipDirs []string, threshold int, cachePath string) *PerceptualIndex {
+	return &PerceptualIndex{
+		root:      root,
+		skipDirs:  skipDirs,
+		threshold: threshold,
+		cache:     loadPerceptualCache(cachePath),
+	}
+}
+
+// Supports reports whether a perceptual hash can be computed for the entry.
+func (p *PerceptualIndex) Supports(entry types.FileEntry) bool {
+	return preview.Supported(entry.Extension)
+}
+
+// Match returns the closest library image within the threshold. Candidates
+// that do not match are added to the index so that near-duplicates within the
+// same batch are flagged as well. group is the key of the entry's FileGroup;
+// members of the same group, such as a RAW and its JPG, never match each
+// other.
+func (p *PerceptualIndex) Match(entry types.FileEntry, group string) (PerceptualMatch, bool, error) {
+	if !p.Supports(entry) {
+		return PerceptualMatch{}, false, nil
+	}
+
+	hash, err := PerceptualHash(entry.Path, entry.Extension)
+	if err != nil {
+		return PerceptualMatch{}, false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.built {
+		p.buildLocked()
+	}
+
+	best := PerceptualMatch{Distance: 65}
+	for _, e := range p.entries {
+		if e.path == entry.Path || (group != "" && e.group == group) {
+			continue
+		}
+		if d := HammingDistance(hash, e.hash); d < best.Distance {
+			best = PerceptualMatch{Path: e.path, Distance: d}
+		}
+	}
+
+	if best.Path != "" && best.Distance <= p.threshold {
+		return best, true, nil
+	}
+
+	p.entries = append(p.entries, perceptualEntry{path: entry.Path, hash: hash, group: group})
+	return PerceptualMatch{}, false, nil
+}
+
+// Save persists the hash cache.
+func (p *PerceptualIndex) Save() error {
+	return p.cache.save()
+}
+
+func (p *PerceptualIndex) buildLocked() {
+	p.built = true
+
+	skip := make(map[string]bool)
+	for _, dir := range p.skipDirs {
+		skip[filepath.Join(p.root, dir)] = true
+	}
+
+	filepath.WalkDir(p.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if skip[path] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if !preview.Supported(ext) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		hash, err := p.cache.hash(path, info.Size(), info.ModTime().UnixNano(), ext)
+		if err != nil {
+			return nil
+		}
+		p.entries = append(p.entries, perceptualEntry{path: path, hash: hash})
+		return nil
+	})
+}
+
+// HammingDistance returns the number of differing bits between two hashes.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// PerceptualHash computes the dHash of an image file. RAW files are hashed
+// using their embedded JPEG preview.
+func PerceptualHash(path, ext string) (uint64, error) {
+	img, err := preview.Decode(path, ext)
+	if err != nil {
+		return 0, err
+	}
+	return dHash(img), nil
+}
+
+// dHash shrinks the image to 9x8 grayscale cells and sets one bit per cell
+// depending on whether it is brighter than its right neighbour.
+func dHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	var cells [h][w]float64
+
+	b := img.Bounds()
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			cells[y][x] = averageLuma(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuma samples at most 16x16 pixels of the given rectangle.
+func averageLuma(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	stepX := (x1-x0)/16 + 1
+	stepY := (y1-y0)/16 + 1
+
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+type cachedHash struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Hash    uint64 `json:"hash"`
+}
+
+// perceptualCache stores hashes keyed by path, invalidated by size/mtime.
+type perceptualCache struct {
+	mu      sync.Mutex
+	path    string
+	dirty   bool
+	Entries map[string]cachedHash `json:"entries"`
+}
+
+func loadPerceptualCache(path string) *perceptualCache {
+	c := &perceptualCache{path: path, Entries: make(map[string]cachedHash)}
+	if path == "" {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Entries == nil {
+		c.Entries = make(map[string]cachedHash)
+	}
+	return c
+}
+
+func (c *perceptualCache) hash(path string, size, modTime int64, ext string) (uint64, error) {
+	c.mu.Lock()
+	if e, ok := c.Entries[path]; ok && e.Size == size && e.ModTime == modTime {
+		c.mu.Unlock()
+		return e.Hash, nil
+	}
+	c.mu.Unlock()
+
+	hash, err := PerceptualHash(path, ext)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.Entries[path] = cachedHash{Size: size, ModTime: modTime, Hash: hash}
+	c.dirty = true
+	c.mu.Unlock()
+	return hash, nil
+}
+
+func (c *perceptualCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	c.dirty = false
+	return nil
+}
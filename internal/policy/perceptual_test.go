@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func gradientImage(w, h int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*64/h) % 256)
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeJPEG(t *testing.T, path string, img image.Image, quality int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPerceptualHash_ResizedReencoded(t *testing.T) {
+	tmpDir := t.TempDir()
+	original := filepath.Join(tmpDir, "original.png")
+	resized := filepath.Join(tmpDir, "resized.jpg")
+	different := filepath.Join(tmpDir, "different.png")
+
+	writePNG(t, original, gradientImage(640, 480, false))
+	writeJPEG(t, resized, gradientImage(320, 240, false), 60)
+	writePNG(t, different, gradientImage(640, 480, true))
+
+	h1, err := PerceptualHash(original, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := PerceptualHash(resized, "jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3, err := PerceptualHash(different, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := HammingDistance(h1, h2); d > 6 {
+		t.Errorf("expected resized copy to be near, got distance %d", d)
+	}
+	if d := HammingDistance(h1, h3); d <= 10 {
+		t.Errorf("expected different image to be far, got distance %d", d)
+	}
+}
+
+func TestPerceptualIndex_Match(t *testing.T) {
+	libDir := t.TempDir()
+	srcDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(libDir, "2025", "01", "01"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	libPath := filepath.Join(libDir, "2025", "01", "01", "IMG_0001.png")
+	writePNG(t, libPath, gradientImage(640, 480, false))
+
+	srcPath := filepath.Join(srcDir, "export.jpg")
+	writeJPEG(t, srcPath, gradientImage(400, 300, false), 70)
+
+	index := NewPerceptualIndex(libDir, []string{"review"}, 10, "")
+	match, ok, err := index.Match(types.FileEntry{
+		Path:      srcPath,
+		Name:      "export.jpg",
+		Extension: "jpg",
+		ModTime:   time.Now(),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected near-duplicate match")
+	}
+	if match.Path != libPath {
+		t.Errorf("expected match %s, got %s", libPath, match.Path)
+	}
+}
+
+func TestPerceptualIndex_MatchSkipsOwnGroup(t *testing.T) {
+	srcDir := t.TempDir()
+	img := gradientImage(400, 300, false)
+	first := filepath.Join(srcDir, "DSC00001.png")
+	second := filepath.Join(srcDir, "DSC00001.jpg")
+	other := filepath.Join(srcDir, "DSC00002.jpg")
+	writePNG(t, first, img)
+	writeJPEG(t, second, img, 90)
+	writeJPEG(t, other, img, 90)
+
+	index := NewPerceptualIndex(t.TempDir(), nil, 10, "")
+	entry := func(path, ext string) types.FileEntry {
+		return types.FileEntry{Path: path, Name: filepath.Base(path), Extension: ext}
+	}
+
+	if _, ok, err := index.Match(entry(first, "png"), "dsc00001"); err != nil || ok {
+		t.Fatalf("first member matched (ok=%v, err=%v)", ok, err)
+	}
+	// The sibling of the same shot is not a near-duplicate of it
+	if _, ok, err := index.Match(entry(second, "jpg"), "dsc00001"); err != nil || ok {
+		t.Fatalf("sibling matched its own group (ok=%v, err=%v)", ok, err)
+	}
+	match, ok, err := index.Match(entry(other, "jpg"), "dsc00002")
+	if err != nil || !ok {
+		t.Fatalf("other shot did not match (ok=%v, err=%v)", ok, err)
+	}
+	if match.Path != first && match.Path != second {
+		t.Errorf("matched %s", match.Path)
+	}
+}
@@ -0,0 +1,375 @@
+// Package preflight checks the destination before any file is copied: free
+// space on each target filesystem, write permission, that removable and
+// network destinations are actually mounted, and filename limits of
+// FAT/exFAT/SMB targets.
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// fat32MaxFileSize is the largest file FAT32 can hold.
+const fat32MaxFileSize int64 = 1<<32 - 1
+
+// Severity tells whether a problem stops the run.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is one finding of the preflight.
+type Problem struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", p.Severity, p.Check, p.Message, p.Path)
+}
+
+// Filesystem summarizes one destination filesystem.
+type Filesystem struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+	Free    uint64 `json:"free"`
+	Total   uint64 `json:"total"`
+	Planned int64  `json:"planned"`
+	Files   int    `json:"files"`
+	// Backup is the size of files the run overwrites. Undo keeps them in the
+	// destination, as copies where hard links aren't supported.
+	Backup int64 `json:"backup,omitempty"`
+}
+
+// Report is the result of Check.
+type Report struct {
+	Filesystems []Filesystem `json:"filesystems"`
+	Problems    []Problem    `json:"problems"`
+}
+
+// Errors returns the problems that stop the run.
+func (r *Report) Errors() []Problem {
+	var errs []Problem
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			errs = append(errs, p)
+		}
+	}
+	return errs
+}
+
+// Err returns an error listing every blocking problem, or nil.
+func (r *Report) Err() error {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	lines := make([]string, len(errs))
+	for i, p := range errs {
+		lines[i] = "  " + p.String()
+	}
+	return fmt.Errorf("preflight failed with %d problem(s):\n%s", len(errs), strings.Join(lines, "\n"))
+}
+
+func (r *Report) add(sev Severity, check, path, format string, args ...any) {
+	r.Problems = append(r.Problems, Problem{Severity: sev, Check: check, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Options configures Check.
+type Options struct {
+	// Dest is the destination root.
+	Dest string
+	// SpaceMargin is kept free on top of the planned bytes.
+	SpaceMargin int64
+}
+
+// fsInfo is what statFS reports about the filesystem holding a path.
+type fsInfo struct {
+	Device  uint64
+	Type    string
+	Free    uint64
+	Total   uint64
+	NameMax int
+}
+
+// fsLimits describes naming restrictions of non-POSIX filesystems.
+type fsLimits struct {
+	caseInsensitive bool
+	windowsNames    bool
+	maxFileSize     int64
+}
+
+var limitsByType = map[string]fsLimits{
+	"vfat":  {caseInsensitive: true, windowsNames: true, maxFileSize: fat32MaxFileSize},
+	"msdos": {caseInsensitive: true, windowsNames: true, maxFileSize: fat32MaxFileSize},
+	"exfat": {caseInsensitive: true, windowsNames: true},
+	"smb":   {caseInsensitive: true, windowsNames: true},
+	"smb2":  {caseInsensitive: true, windowsNames: true},
+	"smbfs": {caseInsensitive: true, windowsNames: true},
+	"cifs":  {caseInsensitive: true, windowsNames: true},
+	"ntfs":  {windowsNames: true},
+	// ntfs-3g and exfat-fuse both report fuseblk
+	"fuseblk": {windowsNames: true},
+}
+
+// mountRoots are directories whose children are expected to be mountpoints.
+// rootDir is compared against to detect a destination that isn't mounted.
+var (
+	mountRoots = []string{"/mnt", "/media", "/run/media", "/Volumes"}
+	rootDir    = "/"
+)
+
+// Check inspects the filesystems the tasks will be copied to.
+func Check(tasks []types.CopyTask, opts Options) *Report {
+	r := &Report{}
+	if opts.SpaceMargin < 0 {
+		opts.SpaceMargin = 0
+	}
+
+	type target struct {
+		fs    *Filesystem
+		info  fsInfo
+		paths []string
+		sizes map[string]int64
+	}
+	byDevice := make(map[uint64]*target)
+	var order []uint64
+	checked := make(map[string]bool)
+
+	dirs := []string{opts.Dest}
+	for _, t := range tasks {
+		dirs = append(dirs, filepath.Dir(t.DestPath))
+	}
+
+	deviceOf := make(map[string]uint64)
+	for _, dir := range dirs {
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+
+		existing, err := existingAncestor(dir)
+		if err != nil {
+			r.add(SeverityError, "access", dir, "cannot resolve destination: %v", err)
+			continue
+		}
+		info, err := statFS(existing)
+		if err != nil {
+			r.add(SeverityWarning, "filesystem", existing, "cannot inspect filesystem: %v", err)
+			continue
+		}
+		deviceOf[dir] = info.Device
+		if _, ok := byDevice[info.Device]; ok {
+			continue
+		}
+
+		byDevice[info.Device] = &target{
+			fs:    &Filesystem{Path: existing, Type: info.Type, Free: info.Free, Total: info.Total},
+			info:  info,
+			sizes: make(map[string]int64),
+		}
+		order = append(order, info.Device)
+
+		checkMount(r, existing, info)
+		checkWritable(r, existing)
+	}
+
+	for _, t := range tasks {
+		dev, ok := deviceOf[filepath.Dir(t.DestPath)]
+		if !ok {
+			continue
+		}
+		tg := byDevice[dev]
+		tg.fs.Planned += t.Source.Size
+		tg.fs.Files++
+		tg.paths = append(tg.paths, t.DestPath)
+		tg.sizes[t.DestPath] = t.Source.Size
+
+		// Overwritten files are backed up next to the destination root
+		if t.Action == types.CopyActionOverwritten {
+			if info, err := os.Lstat(t.DestPath); err == nil {
+				if root, ok := deviceOf[opts.Dest]; ok {
+					byDevice[root].fs.Backup += info.Size()
+				}
+			}
+		}
+	}
+
+	for _, dev := range order {
+		tg := byDevice[dev]
+		need := uint64(tg.fs.Planned + tg.fs.Backup + opts.SpaceMargin)
+		if (tg.fs.Files > 0 || tg.fs.Backup > 0) && tg.fs.Free < need {
+			r.add(SeverityError, "space", tg.fs.Path,
+				"not enough free space: %s planned + %s undo backups + %s margin, %s free",
+				formatBytes(tg.fs.Planned), formatBytes(tg.fs.Backup), formatBytes(opts.SpaceMargin), formatBytes(int64(tg.fs.Free)))
+		}
+		checkNames(r, tg.fs.Path, tg.info, tg.paths, tg.sizes, opts.Dest)
+		r.Filesystems = append(r.Filesystems, *tg.fs)
+	}
+
+	return r
+}
+
+// existingAncestor returns the closest directory of path that exists.
+func existingAncestor(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		fi, err := os.Stat(path)
+		if err == nil {
+			if !fi.IsDir() {
+				return "", fmt.Errorf("%s is not a directory", path)
+			}
+			return path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
+
+// checkMount reports destinations under a mount root (e.g. /mnt/nas) that
+// live on the root filesystem, which happens when the NAS or card isn't
+// mounted and the bare mountpoint directory is left behind.
+func checkMount(r *Report, path string, info fsInfo) {
+	mountpoint := mountpointOf(path)
+	if mountpoint == "" {
+		return
+	}
+	root, err := statFS(rootDir)
+	if err != nil || root.Device != info.Device {
+		return
+	}
+
+	entries, err := os.ReadDir(mountpoint)
+	if err == nil && len(entries) == 0 {
+		r.add(SeverityError, "mount", mountpoint, "empty mountpoint on the root filesystem, the destination does not seem to be mounted")
+		return
+	}
+	r.add(SeverityWarning, "mount", mountpoint, "destination is on the root filesystem, not a mounted volume")
+}
+
+// mountpointOf returns the directory that would be mounted for path, like
+// /mnt/nas, /media/user/card or /Volumes/Backup.
+func mountpointOf(path string) string {
+	for _, root := range mountRoots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		parts := strings.Split(rel, string(filepath.Separator))
+		depth := 1
+		// /media/<user>/<volume> and /run/media/<user>/<volume>
+		if strings.HasSuffix(root, "media") {
+			depth = 2
+		}
+		if len(parts) < depth {
+			return ""
+		}
+		return filepath.Join(root, filepath.Join(parts[:depth]...))
+	}
+	return ""
+}
+
+// checkWritable creates and removes a temporary file in dir.
+func checkWritable(r *Report, dir string) {
+	f, err := os.CreateTemp(dir, ".shutterpipe-preflight-*")
+	if err != nil {
+		r.add(SeverityError, "permission", dir, "destination is not writable: %v", err)
+		return
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+}
+
+// checkNames applies the filesystem's name and size limits to the planned
+// paths relative to dest.
+func checkNames(r *Report, fsPath string, info fsInfo, paths []string, sizes map[string]int64, dest string) {
+	limits := limitsByType[info.Type]
+	seen := make(map[string]string)
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		rel, err := filepath.Rel(dest, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(path)
+		}
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			if msg := checkName(name, info, limits); msg != "" {
+				r.add(SeverityError, "filename", path, "%s", msg)
+				break
+			}
+		}
+
+		if limits.maxFileSize > 0 && sizes[path] > limits.maxFileSize {
+			r.add(SeverityError, "filesize", path, "%s is larger than the %s file size limit of %s",
+				formatBytes(sizes[path]), info.Type, formatBytes(limits.maxFileSize))
+		}
+
+		if limits.caseInsensitive {
+			key := strings.ToLower(path)
+			if other, ok := seen[key]; ok && other != path {
+				r.add(SeverityError, "case", path, "collides with %s on case-insensitive %s", other, info.Type)
+				continue
+			}
+			seen[key] = path
+		}
+	}
+}
+
+// checkName returns why name can't be created on the filesystem, or "".
+func checkName(name string, info fsInfo, limits fsLimits) string {
+	if info.NameMax > 0 && len(name) > info.NameMax {
+		return fmt.Sprintf("name %q is longer than %d bytes", name, info.NameMax)
+	}
+	if !limits.windowsNames {
+		return ""
+	}
+	if len(utf16.Encode([]rune(name))) > 255 {
+		return fmt.Sprintf("name %q is longer than 255 characters", name)
+	}
+	if i := strings.IndexAny(name, `"*:<>?\|`); i >= 0 {
+		return fmt.Sprintf("name %q contains %q, which %s does not allow", name, name[i], info.Type)
+	}
+	for _, c := range name {
+		if c < 0x20 {
+			return fmt.Sprintf("name %q contains a control character", name)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Sprintf("name %q ends with a dot or space, which %s drops", name, info.Type)
+	}
+	return ""
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
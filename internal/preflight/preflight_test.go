@@ -0,0 +1,170 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func task(dest string, size int64) types.CopyTask {
+	return types.CopyTask{
+		Source:   types.FileEntry{Path: "/card/" + filepath.Base(dest), Size: size},
+		DestDir:  filepath.Dir(dest),
+		DestPath: dest,
+	}
+}
+
+func checks(r *Report, sev Severity) map[string]int {
+	found := make(map[string]int)
+	for _, p := range r.Problems {
+		if p.Severity == sev {
+			found[p.Check]++
+		}
+	}
+	return found
+}
+
+func skipUnsupported(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("statfs not supported")
+	}
+}
+
+func TestCheck_OK(t *testing.T) {
+	skipUnsupported(t)
+	dest := t.TempDir()
+
+	r := Check([]types.CopyTask{
+		task(filepath.Join(dest, "2025/12/31/a.jpg"), 1024),
+		task(filepath.Join(dest, "2025/12/31/b.jpg"), 2048),
+	}, Options{Dest: dest})
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Filesystems) != 1 {
+		t.Fatalf("expected one filesystem, got %+v", r.Filesystems)
+	}
+	if fs := r.Filesystems[0]; fs.Planned != 3072 || fs.Files != 2 {
+		t.Errorf("unexpected totals: %+v", fs)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "2025")); !os.IsNotExist(err) {
+		t.Error("preflight should not create destination directories")
+	}
+}
+
+func TestCheck_Space(t *testing.T) {
+	skipUnsupported(t)
+	dest := t.TempDir()
+
+	r := Check([]types.CopyTask{task(filepath.Join(dest, "a.jpg"), 1<<40)}, Options{Dest: dest, SpaceMargin: 1 << 60})
+	if checks(r, SeverityError)["space"] != 1 {
+		t.Errorf("expected space error, got %v", r.Problems)
+	}
+	if r.Err() == nil {
+		t.Error("expected Err to fail")
+	}
+}
+
+func TestCheck_CountsOverwriteBackups(t *testing.T) {
+	skipUnsupported(t)
+	dest := t.TempDir()
+	existing := filepath.Join(dest, "a.jpg")
+	if err := os.WriteFile(existing, make([]byte, 512), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	overwrite := task(existing, 1024)
+	overwrite.Action = types.CopyActionOverwritten
+	r := Check([]types.CopyTask{overwrite, task(filepath.Join(dest, "b.jpg"), 2048)}, Options{Dest: dest})
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fs := r.Filesystems[0]; fs.Planned != 3072 || fs.Backup != 512 {
+		t.Errorf("unexpected totals: %+v", fs)
+	}
+}
+
+func TestCheck_NotWritable(t *testing.T) {
+	skipUnsupported(t)
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	dest := t.TempDir()
+	os.Chmod(dest, 0555)
+	defer os.Chmod(dest, 0755)
+
+	r := Check([]types.CopyTask{task(filepath.Join(dest, "a.jpg"), 1)}, Options{Dest: dest})
+	if checks(r, SeverityError)["permission"] != 1 {
+		t.Errorf("expected permission error, got %v", r.Problems)
+	}
+}
+
+func TestCheck_Mount(t *testing.T) {
+	skipUnsupported(t)
+	base := t.TempDir()
+	mnt := filepath.Join(base, "mnt")
+	os.MkdirAll(filepath.Join(mnt, "nas"), 0755)
+
+	oldRoots, oldRoot := mountRoots, rootDir
+	mountRoots, rootDir = []string{mnt}, base
+	defer func() { mountRoots, rootDir = oldRoots, oldRoot }()
+
+	dest := filepath.Join(mnt, "nas", "photos")
+	r := Check([]types.CopyTask{task(filepath.Join(dest, "a.jpg"), 1)}, Options{Dest: dest})
+	if checks(r, SeverityError)["mount"] != 1 {
+		t.Errorf("expected mount error for empty mountpoint, got %v", r.Problems)
+	}
+
+	os.MkdirAll(dest, 0755)
+	r = Check([]types.CopyTask{task(filepath.Join(dest, "a.jpg"), 1)}, Options{Dest: dest})
+	if checks(r, SeverityError)["mount"] != 0 || checks(r, SeverityWarning)["mount"] != 1 {
+		t.Errorf("expected mount warning only, got %v", r.Problems)
+	}
+}
+
+func TestMountpointOf(t *testing.T) {
+	tests := map[string]string{
+		"/mnt/nas/photos":          "/mnt/nas",
+		"/media/me/CARD/DCIM":      "/media/me/CARD",
+		"/run/media/me/Backup":     "/run/media/me/Backup",
+		"/Volumes/Backup/2025":     "/Volumes/Backup",
+		"/media/me":                "",
+		"/home/me/Pictures/Import": "",
+	}
+	for path, want := range tests {
+		if got := mountpointOf(path); got != want {
+			t.Errorf("mountpointOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestCheckNames(t *testing.T) {
+	dest := "/nas"
+	paths := []string{
+		"/nas/2025/IMG_0001.JPG",
+		"/nas/2025/img_0001.jpg",
+		"/nas/2025/clip:1.mp4",
+		"/nas/event. /a.jpg",
+		"/nas/2025/big.mov",
+	}
+	sizes := map[string]int64{"/nas/2025/big.mov": 5 << 30}
+
+	r := &Report{}
+	checkNames(r, dest, fsInfo{Type: "vfat", NameMax: 255}, paths, sizes, dest)
+	got := checks(r, SeverityError)
+	if got["case"] != 1 || got["filename"] != 2 || got["filesize"] != 1 {
+		t.Errorf("unexpected problems: %v", r.Problems)
+	}
+
+	r = &Report{}
+	checkNames(r, dest, fsInfo{Type: "ext4", NameMax: 255}, paths, sizes, dest)
+	if len(r.Problems) != 0 {
+		t.Errorf("ext4 should accept all names, got %v", r.Problems)
+	}
+}
@@ -0,0 +1,34 @@
+package preflight
+
+import (
+	"strings"
+	"syscall"
+)
+
+func statFS(path string) (fsInfo, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsInfo{}, err
+	}
+
+	var sys syscall.Stat_t
+	if err := syscall.Stat(path, &sys); err != nil {
+		return fsInfo{}, err
+	}
+
+	var name strings.Builder
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name.WriteByte(byte(c))
+	}
+
+	return fsInfo{
+		Device:  uint64(sys.Dev),
+		Type:    name.String(),
+		Free:    uint64(st.Bavail) * uint64(st.Bsize),
+		Total:   uint64(st.Blocks) * uint64(st.Bsize),
+		NameMax: 255,
+	}, nil
+}
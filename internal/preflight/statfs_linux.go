@@ -0,0 +1,47 @@
+package preflight
+
+import "syscall"
+
+// Filesystem magic numbers from statfs(2).
+var fsTypes = map[uint32]string{
+	0x4d44:     "vfat",
+	0x2011bab0: "exfat",
+	0x5346544e: "ntfs",
+	0x65735546: "fuseblk",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x6969:     "nfs",
+	0x482b:     "hfsplus",
+	0xef53:     "ext4",
+	0x9123683e: "btrfs",
+	0x58465342: "xfs",
+	0x2fc12fc1: "zfs",
+	0x01021994: "tmpfs",
+	0x794c7630: "overlay",
+}
+
+func statFS(path string) (fsInfo, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsInfo{}, err
+	}
+
+	var sys syscall.Stat_t
+	if err := syscall.Stat(path, &sys); err != nil {
+		return fsInfo{}, err
+	}
+
+	fsType, ok := fsTypes[uint32(st.Type)]
+	if !ok {
+		fsType = "unknown"
+	}
+
+	return fsInfo{
+		Device:  uint64(sys.Dev),
+		Type:    fsType,
+		Free:    uint64(st.Bavail) * uint64(st.Bsize),
+		Total:   uint64(st.Blocks) * uint64(st.Bsize),
+		NameMax: int(st.Namelen),
+	}, nil
+}
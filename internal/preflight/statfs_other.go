@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package preflight
+
+import "errors"
+
+func statFS(path string) (fsInfo, error) {
+	return fsInfo{}, errors.New("filesystem information is not available on this platform")
+}
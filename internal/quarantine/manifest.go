@@ -0,0 +1,287 @@
+// Package quarantine records quarantined files and resolves them later.
+package quarantine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ManifestFile is the manifest filename inside the quarantine directory.
+const ManifestFile = "manifest.json"
+
+const (
+	lockTimeout = 10 * time.Second
+	lockRetry   = 20 * time.Millisecond
+	staleLock   = time.Minute
+)
+
+// Status is the review state of a quarantined file.
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusAccepted Status = "accepted"
+	StatusRejected Status = "rejected"
+	StatusReplaced Status = "replaced"
+)
+
+// Entry describes one quarantined file.
+type Entry struct {
+	ID int `json:"id"`
+	// QuarantinePath is where the file currently lives.
+	QuarantinePath string `json:"quarantine_path"`
+	// DestPath is where the file would have gone without the conflict.
+	DestPath string `json:"dest_path"`
+	// SourcePath is the original source file.
+	SourcePath string `json:"source_path"`
+	// ConflictPath is the existing file the source collided with.
+	ConflictPath string    `json:"conflict_path"`
+	Reason       string    `json:"reason"`
+	Size         int64     `json:"size"`
+	Status       Status    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	ResolvedAt   time.Time `json:"resolved_at,omitempty"`
+	// ResolvedPath is where the file ended up after accept/replace.
+	ResolvedPath string `json:"resolved_path,omitempty"`
+}
+
+// Manifest is the persisted list of quarantined files.
+type Manifest struct {
+	mu       sync.Mutex
+	filePath string
+	NextID   int     `json:"next_id"`
+	Entries  []Entry `json:"entries"`
+}
+
+// Load reads the manifest in dir, returning an empty one if it doesn't exist.
+func Load(dir string) (*Manifest, error) {
+	m := &Manifest{filePath: filepath.Join(dir, ManifestFile), NextID: 1}
+
+	data, err := os.ReadFile(m.filePath)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse quarantine manifest: %w", err)
+	}
+	return m, nil
+}
+
+// Update loads the manifest in dir, applies fn and saves the result unless fn
+// fails. A lock file keeps the pipeline, the web UI and the CLI from
+// overwriting each other's changes.
+func Update(dir string, fn func(m *Manifest) error) error {
+	unlock, err := lock(filepath.Join(dir, ManifestFile+".lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	if err := fn(m); err != nil {
+		return err
+	}
+	return m.Save()
+}
+
+// lock creates path exclusively, waiting for another holder to remove it.
+// A lock older than staleLock was left by a crashed process and is taken
+// over.
+func lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock quarantine manifest: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("quarantine manifest is locked: %s", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// Save writes the manifest atomically.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(m.filePath), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile := m.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, m.filePath); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return nil
+}
+
+// Add records a newly quarantined file and returns its ID.
+func (m *Manifest) Add(e Entry) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.ID = m.NextID
+	m.NextID++
+	e.Status = StatusPending
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	m.Entries = append(m.Entries, e)
+	return e.ID
+}
+
+// Remove drops pending entries for the given quarantine paths, e.g. after
+// the run that quarantined them was undone. It returns how many were removed.
+func (m *Manifest) Remove(paths ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+
+	kept := m.Entries[:0]
+	for _, e := range m.Entries {
+		if e.Status == StatusPending && drop[e.QuarantinePath] {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	removed := len(m.Entries) - len(kept)
+	m.Entries = kept
+	return removed
+}
+
+// List returns entries, optionally only the pending ones.
+func (m *Manifest) List(pendingOnly bool) []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Entry
+	for _, e := range m.Entries {
+		if pendingOnly && e.Status != StatusPending {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Accept moves the file to its intended location, keeping the existing file
+// and picking a "_N" name if the intended path is taken.
+func (m *Manifest) Accept(id int) (Entry, error) {
+	return m.resolve(id, func(e *Entry) error {
+		target := uniquePath(e.DestPath)
+		if err := moveFile(e.QuarantinePath, target); err != nil {
+			return err
+		}
+		e.ResolvedPath = target
+		e.Status = StatusAccepted
+		return nil
+	})
+}
+
+// Replace moves the file to its intended location, overwriting the file it
+// collided with.
+func (m *Manifest) Replace(id int) (Entry, error) {
+	return m.resolve(id, func(e *Entry) error {
+		if err := moveFile(e.QuarantinePath, e.DestPath); err != nil {
+			return err
+		}
+		e.ResolvedPath = e.DestPath
+		e.Status = StatusReplaced
+		return nil
+	})
+}
+
+// Reject deletes the quarantined file.
+func (m *Manifest) Reject(id int) (Entry, error) {
+	return m.resolve(id, func(e *Entry) error {
+		if err := os.Remove(e.QuarantinePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		e.Status = StatusRejected
+		return nil
+	})
+}
+
+func (m *Manifest) resolve(id int, fn func(e *Entry) error) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		if e.ID != id {
+			continue
+		}
+		if e.Status != StatusPending {
+			return *e, fmt.Errorf("quarantine entry %d already %s", id, e.Status)
+		}
+		if err := fn(e); err != nil {
+			return *e, fmt.Errorf("quarantine entry %d: %w", id, err)
+		}
+		e.ResolvedAt = time.Now()
+		return *e, nil
+	}
+	return Entry{}, fmt.Errorf("quarantine entry %d not found", id)
+}
+
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+
+	dir := filepath.Dir(path)
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	for i := 1; i < 10000; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, ext))
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+	return path
+}
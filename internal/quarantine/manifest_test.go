@@ -0,0 +1,145 @@
+package quarantine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func setupEntry(t *testing.T, root string, m *Manifest) (int, string) {
+	t.Helper()
+
+	destPath := filepath.Join(root, "2025", "01", "01", "photo.jpg")
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(destPath, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qPath := filepath.Join(root, "quarantine", "photo.jpg")
+	if err := os.MkdirAll(filepath.Dir(qPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(qPath, []byte("incoming"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	id := m.Add(Entry{
+		QuarantinePath: qPath,
+		DestPath:       destPath,
+		SourcePath:     "/card/DCIM/photo.jpg",
+		ConflictPath:   destPath,
+		Reason:         "test",
+	})
+	return id, destPath
+}
+
+func TestManifest_SaveLoad(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "quarantine")
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupEntry(t, root, m)
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := loaded.List(true)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 pending entry, got %d", len(entries))
+	}
+	if entries[0].SourcePath != "/card/DCIM/photo.jpg" {
+		t.Errorf("unexpected source path %s", entries[0].SourcePath)
+	}
+}
+
+func TestManifest_Accept(t *testing.T) {
+	root := t.TempDir()
+	m, _ := Load(filepath.Join(root, "quarantine"))
+	id, destPath := setupEntry(t, root, m)
+
+	e, err := m.Accept(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join(filepath.Dir(destPath), "photo_1.jpg")
+	if e.ResolvedPath != expected {
+		t.Errorf("expected %s, got %s", expected, e.ResolvedPath)
+	}
+	if data, _ := os.ReadFile(destPath); string(data) != "existing" {
+		t.Error("existing file should be kept on accept")
+	}
+	if _, err := m.Accept(id); err == nil {
+		t.Error("expected error resolving an entry twice")
+	}
+}
+
+func TestManifest_Replace(t *testing.T) {
+	root := t.TempDir()
+	m, _ := Load(filepath.Join(root, "quarantine"))
+	id, destPath := setupEntry(t, root, m)
+
+	if _, err := m.Replace(id); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(destPath); string(data) != "incoming" {
+		t.Error("existing file should be replaced")
+	}
+}
+
+func TestManifest_Reject(t *testing.T) {
+	root := t.TempDir()
+	m, _ := Load(filepath.Join(root, "quarantine"))
+	id, _ := setupEntry(t, root, m)
+
+	e, err := m.Reject(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(e.QuarantinePath); !os.IsNotExist(err) {
+		t.Error("quarantined file should be deleted on reject")
+	}
+}
+
+func TestUpdate_Concurrent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "quarantine")
+
+	// A run adds entries while the web UI resolves them
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(dir, func(m *Manifest) error {
+				m.Add(Entry{QuarantinePath: fmt.Sprintf("/q/%d.jpg", i)})
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.List(false)); n != 20 {
+		t.Errorf("expected 20 entries, got %d", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile+".lock")); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
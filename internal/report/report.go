@@ -0,0 +1,305 @@
+// Package report renders ingest reports for a run in HTML, CSV and JSON.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Supported report formats.
+const (
+	FormatHTML = "html"
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Group is a breakdown row (a capture day or destination folder).
+type Group struct {
+	Key    string `json:"key"`
+	Files  int    `json:"files"`
+	Bytes  int64  `json:"bytes"`
+	Failed int    `json:"failed"`
+}
+
+// Report is the content of an ingest report.
+type Report struct {
+	RunID        string               `json:"run_id"`
+	Source       string               `json:"source"`
+	Dest         string               `json:"dest"`
+	DryRun       bool                 `json:"dry_run"`
+	GeneratedAt  time.Time            `json:"generated_at"`
+	Summary      types.RunSummary     `json:"summary"`
+	ByDay        []Group              `json:"by_day"`
+	ByFolder     []Group              `json:"by_folder"`
+	Failures     []history.FileResult `json:"failures"`
+	Unclassified []history.FileResult `json:"unclassified"`
+	Files        []history.FileResult `json:"files"`
+}
+
+// Build creates a report from a run record.
+func Build(rec *history.Record) *Report {
+	r := &Report{
+		RunID:        rec.ID,
+		Source:       rec.Source,
+		Dest:         rec.Dest,
+		DryRun:       rec.DryRun,
+		GeneratedAt:  time.Now(),
+		Summary:      rec.Summary,
+		Failures:     []history.FileResult{},
+		Unclassified: []history.FileResult{},
+		Files:        rec.Files,
+	}
+
+	days := make(map[string]*Group)
+	folders := make(map[string]*Group)
+	for _, f := range rec.Files {
+		if f.Error != "" {
+			r.Failures = append(r.Failures, f)
+		}
+		if f.CaptureTime == nil {
+			r.Unclassified = append(r.Unclassified, f)
+		}
+		if f.Action == types.CopyActionSkipped {
+			continue
+		}
+
+		day := "unclassified"
+		if f.CaptureTime != nil {
+			day = f.CaptureTime.Format("2006-01-02")
+		}
+		addToGroup(days, day, f)
+		addToGroup(folders, folderKey(rec.Dest, f.Dest), f)
+	}
+
+	r.ByDay = sortedGroups(days)
+	r.ByFolder = sortedGroups(folders)
+	return r
+}
+
+func addToGroup(groups map[string]*Group, key string, f history.FileResult) {
+	g, ok := groups[key]
+	if !ok {
+		g = &Group{Key: key}
+		groups[key] = g
+	}
+	g.Files++
+	if f.Error != "" {
+		g.Failed++
+	} else {
+		g.Bytes += f.Size
+	}
+}
+
+func sortedGroups(groups map[string]*Group) []Group {
+	out := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// folderKey returns the destination folder relative to the destination root.
+func folderKey(root, dest string) string {
+	if dest == "" {
+		return "-"
+	}
+	dir := filepath.Dir(dest)
+	if rel, err := filepath.Rel(root, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return dir
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Write renders the report in the given format.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatHTML:
+		return htmlTemplate.Execute(w, r)
+	case FormatCSV:
+		return r.writeCSV(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// WriteFiles writes the report in each format to dir as
+// "<run-id>.<format>" and returns the written paths.
+func (r *Report) WriteFiles(dir string, formats []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, format := range formats {
+		path := filepath.Join(dir, r.RunID+"."+format)
+		f, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+		err = r.Write(f, format)
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeCSV writes one row per file.
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "dest", "action", "size", "capture_time", "metadata_source", "metadata_error", "sha256", "error", "error_class"})
+	for _, f := range r.Files {
+		captured := ""
+		if f.CaptureTime != nil {
+			captured = f.CaptureTime.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			f.Source,
+			f.Dest,
+			string(f.Action),
+			strconv.FormatInt(f.Size, 10),
+			captured,
+			f.MetadataSource,
+			f.MetadataError,
+			f.Hash,
+			f.Error,
+			string(f.ErrorClass),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"time": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="ko">
+<head>
+<meta charset="utf-8">
+<title>ShutterPipe 백업 보고서 {{.RunID}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.15em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: .3em; }
+table { border-collapse: collapse; width: 100%; font-size: .9em; }
+th, td { text-align: left; padding: .35em .6em; border-bottom: 1px solid #eee; }
+th { background: #f6f6f6; }
+td.num { text-align: right; }
+.fail { color: #c0392b; }
+.muted { color: #888; }
+code { font-size: .85em; }
+</style>
+</head>
+<body>
+<h1>ShutterPipe 백업 보고서</h1>
+<p class="muted">실행 {{.RunID}} · 생성 {{time .GeneratedAt}}{{if .DryRun}} · 시뮬레이션(dry-run){{end}}</p>
+
+<table>
+<tr><th>원본</th><td>{{.Source}}</td></tr>
+<tr><th>대상</th><td>{{.Dest}}</td></tr>
+<tr><th>시작</th><td>{{time .Summary.StartTime}}</td></tr>
+<tr><th>소요 시간</th><td>{{duration .Summary.Duration}}</td></tr>
+</table>
+
+<h2>요약</h2>
+<table>
+<tr><th>전체</th><th>복사</th><th>건너뜀</th><th>이름 변경</th><th>덮어씀</th><th>격리</th><th>검토</th><th>실패</th><th>미분류</th><th>용량</th></tr>
+<tr>
+<td class="num">{{.Summary.TotalFiles}}</td>
+<td class="num">{{.Summary.Copied}}</td>
+<td class="num">{{.Summary.Skipped}}</td>
+<td class="num">{{.Summary.Renamed}}</td>
+<td class="num">{{.Summary.Overwritten}}</td>
+<td class="num">{{.Summary.Quarantined}}</td>
+<td class="num">{{.Summary.Review}}</td>
+<td class="num{{if .Summary.Failed}} fail{{end}}">{{.Summary.Failed}}</td>
+<td class="num">{{.Summary.Unclassified}}</td>
+<td class="num">{{bytes .Summary.BytesCopied}}</td>
+</tr>
+</table>
+
+<h2>날짜별</h2>
+<table>
+<tr><th>촬영일</th><th>파일</th><th>용량</th><th>실패</th></tr>
+{{range .ByDay}}<tr><td>{{.Key}}</td><td class="num">{{.Files}}</td><td class="num">{{bytes .Bytes}}</td><td class="num">{{.Failed}}</td></tr>
+{{end}}</table>
+
+<h2>폴더별</h2>
+<table>
+<tr><th>폴더</th><th>파일</th><th>용량</th><th>실패</th></tr>
+{{range .ByFolder}}<tr><td>{{.Key}}</td><td class="num">{{.Files}}</td><td class="num">{{bytes .Bytes}}</td><td class="num">{{.Failed}}</td></tr>
+{{end}}</table>
+
+<h2>실패 ({{len .Failures}})</h2>
+{{if .Failures}}<table>
+<tr><th>파일</th><th>분류</th><th>사유</th></tr>
+{{range .Failures}}<tr><td>{{.Source}}</td><td>{{.ErrorClass}}</td><td class="fail">{{.Error}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">없음</p>{{end}}
+
+<h2>미분류 ({{len .Unclassified}})</h2>
+{{if .Unclassified}}<table>
+<tr><th>파일</th><th>메타데이터 오류</th></tr>
+{{range .Unclassified}}<tr><td>{{.Source}}</td><td>{{.MetadataError}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">없음</p>{{end}}
+
+<h2>전체 파일 ({{len .Files}})</h2>
+<table>
+<tr><th>원본</th><th>대상</th><th>처리</th><th>용량</th><th>SHA-256</th></tr>
+{{range .Files}}<tr><td>{{.Source}}</td><td>{{.Dest}}</td><td{{if .Error}} class="fail"{{end}}>{{.Action}}</td><td class="num">{{bytes .Size}}</td><td>{{if .Hash}}<code>{{.Hash}}</code>{{else}}<span class="muted">-</span>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
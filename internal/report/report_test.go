@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func testRecord() *history.Record {
+	day := time.Date(2025, 12, 31, 10, 0, 0, 0, time.UTC)
+	return &history.Record{
+		Run: history.Run{ID: "20251231-100000-abcd", Source: "/card", Dest: "/nas"},
+		Files: []history.FileResult{
+			{Source: "/card/a.jpg", Dest: "/nas/2025/12/31/a.jpg", Size: 100, Action: types.CopyActionCopied, CaptureTime: &day, Hash: "abc123"},
+			{Source: "/card/b.jpg", Dest: "/nas/2025/12/31/b.jpg", Size: 50, Action: types.CopyActionFailed, CaptureTime: &day, Error: "no space left on device"},
+			{Source: "/card/c.mp4", Dest: "/nas/unclassified/c.mp4", Size: 10, Action: types.CopyActionCopied, MetadataError: "no XML sidecar"},
+			{Source: "/card/d.jpg", Dest: "/nas/2025/12/31/d.jpg", Size: 10, Action: types.CopyActionSkipped, CaptureTime: &day},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	r := Build(testRecord())
+
+	if len(r.Failures) != 1 || r.Failures[0].Source != "/card/b.jpg" {
+		t.Errorf("unexpected failures: %+v", r.Failures)
+	}
+	if len(r.Unclassified) != 1 || r.Unclassified[0].MetadataError != "no XML sidecar" {
+		t.Errorf("unexpected unclassified: %+v", r.Unclassified)
+	}
+
+	want := []Group{
+		{Key: "2025-12-31", Files: 2, Bytes: 100, Failed: 1},
+		{Key: "unclassified", Files: 1, Bytes: 10},
+	}
+	if len(r.ByDay) != len(want) {
+		t.Fatalf("expected %d days, got %+v", len(want), r.ByDay)
+	}
+	for i := range want {
+		if r.ByDay[i] != want[i] {
+			t.Errorf("day %d: expected %+v, got %+v", i, want[i], r.ByDay[i])
+		}
+	}
+
+	if len(r.ByFolder) != 2 || r.ByFolder[0].Key != "2025/12/31" || r.ByFolder[1].Key != "unclassified" {
+		t.Errorf("unexpected folders: %+v", r.ByFolder)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := Build(testRecord())
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected header and 4 rows, got %d", len(rows))
+	}
+	if rows[1][7] != "abc123" {
+		t.Errorf("expected hash in csv, got %q", rows[1][7])
+	}
+
+	buf.Reset()
+	if err := r.Write(&buf, FormatHTML); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "no space left on device") {
+		t.Error("expected failure reason in html report")
+	}
+
+	if err := r.Write(&buf, "pdf"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+var rawExtensions = map[string]bool{
+	"raw": true, "arw": true, "cr2": true, "cr3": true, "nef": true, "dng": true,
+	"raf": true, "orf": true, "rw2": true, "srw": true,
+}
+
+// sonyXMLSidecar matches Sony clip metadata names such as "C0001M01".
+var sonyXMLSidecar = regexp.MustCompile(`(?i)^(.+)M\d{2}$`)
+
+// GroupSidecars links entries that share a directory and base name, taking
+// known sidecar patterns into account ("C0001M01.XML" belongs to
+// "C0001.MP4", "IMG_0001.CR2.xmp" to "IMG_0001.CR2"). Groups keep the scan
+// order of their first member.
+func GroupSidecars(entries []types.FileEntry) []types.FileGroup {
+	var groups []types.FileGroup
+	index := make(map[string]int)
+
+	for _, entry := range entries {
+		base := GroupBase(entry.Name)
+		key := filepath.Join(filepath.Dir(entry.Path), strings.ToLower(base))
+
+		if i, ok := index[key]; ok {
+			groups[i].Members = append(groups[i].Members, entry)
+			continue
+		}
+
+		index[key] = len(groups)
+		groups = append(groups, types.FileGroup{
+			Key:     key,
+			Base:    base,
+			Members: []types.FileEntry{entry},
+		})
+	}
+
+	for i := range groups {
+		sort.SliceStable(groups[i].Members, func(a, b int) bool {
+			return MemberRank(groups[i].Members[a]) < MemberRank(groups[i].Members[b])
+		})
+	}
+
+	return groups
+}
+
+// GroupBase returns the base name used to group a file with its siblings.
+func GroupBase(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	switch strings.ToLower(ext) {
+	case ".xml":
+		if m := sonyXMLSidecar.FindStringSubmatch(base); m != nil {
+			return m[1]
+		}
+	case ".xmp":
+		// IMG_0001.CR2.xmp -> IMG_0001
+		if inner := filepath.Ext(base); inner != "" {
+			return strings.TrimSuffix(base, inner)
+		}
+	}
+	return base
+}
+
+// MemberRank orders group members by how authoritative their capture time
+// is: RAW, then other stills, then video (via XML), then sidecars.
+func MemberRank(entry types.FileEntry) int {
+	switch {
+	case rawExtensions[entry.Extension]:
+		return 0
+	case entry.IsVideo:
+		return 2
+	case entry.Extension == "xml":
+		return 3
+	case entry.Extension == "xmp" || entry.Extension == "thm":
+		return 4
+	default:
+		return 1
+	}
+}
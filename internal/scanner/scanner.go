@@ -2,7 +2,9 @@ package scanner
 
 import (
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/On-Jun9/ShutterPipe/pkg/types"
@@ -13,40 +15,145 @@ var videoExtensions = map[string]bool{
 	"m4v": true, "webm": true, "wmv": true, "flv": true,
 }
 
+// systemDirs are never descended into: OS metadata, trash and camera
+// thumbnail folders.
+var systemDirs = map[string]bool{
+	".trashes":                  true,
+	".spotlight-v100":           true,
+	".fseventsd":                true,
+	".temporaryitems":           true,
+	".documentrevisions-v100":   true,
+	"$recycle.bin":              true,
+	"system volume information": true,
+}
+
+// systemPaths are card-relative directories skipped regardless of mode.
+var systemPaths = []string{
+	"private/m4root/thmbnl",
+	"private/m4root/sub",
+}
+
+// cardPaths are the DCF and vendor video directories walked in card mode.
+var cardPaths = []string{
+	"dcim",
+	"private/m4root/clip",
+	"private/avchd",
+	"xdroot/clip",
+	"contents/video",
+	"mp_root",
+}
+
+// Skip rule names reported by SkipStats.
+const (
+	SkipExtension = "extension"
+	SkipExclude   = "exclude"
+	SkipInclude   = "include"
+	SkipMinSize   = "min_size"
+	SkipMaxSize   = "max_size"
+	SkipSystem    = "system"
+	SkipCardMode  = "card_mode"
+	SkipPartial   = "partial"
+
+	// SkipDirSuffix marks directories skipped as a whole, e.g. "system_dirs".
+	// Their contents are not walked, so the files inside are not counted.
+	SkipDirSuffix = "_dirs"
+)
+
+// Options configures path and size filters.
+type Options struct {
+	// IncludePatterns, if set, limit the scan to files matching at least one
+	// glob. Patterns without "/" match the file name; others match the path
+	// relative to the scan root and may use "**".
+	IncludePatterns []string
+	// ExcludePatterns skip matching files and directories.
+	ExcludePatterns []string
+	// MinSize and MaxSize bound the file size in bytes (0 = no limit).
+	MinSize int64
+	MaxSize int64
+	// CardMode only walks DCIM and known vendor video folders.
+	CardMode bool
+}
+
 type Scanner struct {
 	includeExt map[string]bool
+	opts       Options
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	skipped    map[string]int
 }
 
 func New(extensions []string) *Scanner {
+	return NewWithOptions(extensions, Options{})
+}
+
+// NewWithOptions creates a scanner with glob, size and card-mode filters.
+func NewWithOptions(extensions []string, opts Options) *Scanner {
 	extMap := make(map[string]bool)
 	for _, ext := range extensions {
 		extMap[strings.ToLower(ext)] = true
 	}
-	return &Scanner{includeExt: extMap}
+	return &Scanner{
+		includeExt: extMap,
+		opts:       opts,
+		include:    compileGlobs(opts.IncludePatterns),
+		exclude:    compileGlobs(opts.ExcludePatterns),
+		skipped:    make(map[string]int),
+	}
+}
+
+// SkipStats returns how many files, and under SkipDirSuffix keys how many
+// directories, each rule skipped during the last Scan.
+func (s *Scanner) SkipStats() map[string]int {
+	stats := make(map[string]int, len(s.skipped))
+	for k, v := range s.skipped {
+		stats[k] = v
+	}
+	return stats
 }
 
 func (s *Scanner) Scan(root string) ([]types.FileEntry, error) {
 	var entries []types.FileEntry
+	s.skipped = make(map[string]int)
 
 	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+
 		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if rule := s.skipDir(rel, d.Name()); rule != "" {
+				s.skipped[rule+SkipDirSuffix]++
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
-		if !s.includeExt[ext] {
+		if rule := s.skipFile(rel, d); rule != "" {
+			s.skipped[rule]++
 			return nil
 		}
 
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
 		info, err := d.Info()
 		if err != nil {
 			return nil
 		}
 
+		if s.opts.MinSize > 0 && info.Size() < s.opts.MinSize {
+			s.skipped[SkipMinSize]++
+			return nil
+		}
+		if s.opts.MaxSize > 0 && info.Size() > s.opts.MaxSize {
+			s.skipped[SkipMaxSize]++
+			return nil
+		}
+
 		entries = append(entries, types.FileEntry{
 			Path:      path,
 			Name:      d.Name(),
@@ -61,3 +168,170 @@ func (s *Scanner) Scan(root string) ([]types.FileEntry, error) {
 
 	return entries, err
 }
+
+func (s *Scanner) skipDir(rel, name string) string {
+	lower := strings.ToLower(rel)
+	if systemDirs[strings.ToLower(name)] || hasPathPrefix(lower, systemPaths) {
+		return SkipSystem
+	}
+	if matchAny(s.exclude, rel) {
+		return SkipExclude
+	}
+	if s.opts.CardMode && !onCardPath(lower) {
+		return SkipCardMode
+	}
+	return ""
+}
+
+func (s *Scanner) skipFile(rel string, d os.DirEntry) string {
+	name := d.Name()
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+
+	// Leftovers from interrupted copies and macOS AppleDouble files
+	if ext == "part" {
+		return SkipPartial
+	}
+	if strings.HasPrefix(name, "._") {
+		return SkipSystem
+	}
+	if s.opts.CardMode && !hasPathPrefix(strings.ToLower(path.Dir(rel)), cardPaths) {
+		return SkipCardMode
+	}
+	if !s.includeExt[ext] {
+		return SkipExtension
+	}
+	if matchAny(s.exclude, rel) {
+		return SkipExclude
+	}
+	if len(s.include) > 0 && !matchAny(s.include, rel) {
+		return SkipInclude
+	}
+	return ""
+}
+
+// onCardPath reports whether a directory is a card path, lies inside one, or
+// is an ancestor of one (e.g. "private" on the way to "private/avchd").
+func onCardPath(rel string) bool {
+	for _, p := range cardPaths {
+		if rel == p || strings.HasPrefix(rel, p+"/") || strings.HasPrefix(p, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(rel string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if rel == p || strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func compileGlobs(patterns []string) []*regexp.Regexp {
+	var out []*regexp.Regexp
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+		if re, err := regexp.Compile(globToRegexp(p)); err == nil {
+			out = append(out, re)
+		}
+	}
+	return out
+}
+
+// globToRegexp converts a glob to an anchored, case-insensitive regexp.
+// "**" matches across directories, "*", "?" and character classes such as
+// "[0-9]" or "[!a-z]" stay within one segment, as with path.Match. Patterns
+// without "/" are matched against the base name only.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	if !strings.Contains(glob, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			class, n, ok := globClass(glob[i:])
+			if !ok {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			b.WriteString(class)
+			i += n - 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// globClass converts the character class at the start of glob to a regexp
+// class that never matches "/". It returns the class, the number of glob
+// bytes consumed and false if the class is not terminated.
+func globClass(glob string) (string, int, bool) {
+	var b strings.Builder
+	i := 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		b.WriteString("[^/")
+		i++
+	} else {
+		b.WriteString("[")
+	}
+	start := i
+	for ; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == ']' && i > start:
+			b.WriteString("]")
+			return b.String(), i + 1, true
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(classEscape(glob[i]))
+		case c == '-' && i > start && i+1 < len(glob) && glob[i+1] != ']':
+			b.WriteByte('-')
+		case c == '/':
+			return "", 0, false
+		default:
+			b.WriteString(classEscape(c))
+		}
+	}
+	return "", 0, false
+}
+
+// classEscape escapes characters that are special inside a regexp class.
+func classEscape(c byte) string {
+	switch c {
+	case '\\', ']', '[', '^', '-':
+		return "\\" + string(c)
+	}
+	return string(c)
+}
+
+func matchAny(patterns []*regexp.Regexp, rel string) bool {
+	for _, re := range patterns {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
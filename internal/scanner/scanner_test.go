@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
 )
 
 func TestScanner_Scan(t *testing.T) {
@@ -50,3 +52,120 @@ func TestScanner_Scan(t *testing.T) {
 		t.Errorf("expected 1 video, got %d", videoCount)
 	}
 }
+
+func TestGroupSidecars(t *testing.T) {
+	entries := []types.FileEntry{
+		{Path: "/card/DCIM/IMG_0001.JPG", Name: "IMG_0001.JPG", Extension: "jpg"},
+		{Path: "/card/DCIM/IMG_0001.ARW", Name: "IMG_0001.ARW", Extension: "arw"},
+		{Path: "/card/DCIM/IMG_0001.ARW.xmp", Name: "IMG_0001.ARW.xmp", Extension: "xmp"},
+		{Path: "/card/DCIM/IMG_0002.JPG", Name: "IMG_0002.JPG", Extension: "jpg"},
+		{Path: "/card/CLIP/C0001M01.XML", Name: "C0001M01.XML", Extension: "xml"},
+		{Path: "/card/CLIP/C0001.MP4", Name: "C0001.MP4", Extension: "mp4", IsVideo: true},
+	}
+
+	groups := GroupSidecars(entries)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(groups))
+	}
+
+	shot := groups[0]
+	if shot.Base != "IMG_0001" || len(shot.Members) != 3 {
+		t.Fatalf("unexpected first group: %+v", shot)
+	}
+	if shot.Members[0].Extension != "arw" {
+		t.Errorf("expected RAW to be the primary member, got %s", shot.Members[0].Name)
+	}
+
+	clip := groups[2]
+	if clip.Base != "C0001" || len(clip.Members) != 2 {
+		t.Fatalf("unexpected clip group: %+v", clip)
+	}
+	if !clip.Members[0].IsVideo {
+		t.Errorf("expected video before its XML sidecar, got %s", clip.Members[0].Name)
+	}
+}
+
+func TestScanner_CardModeAndFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFiles := []struct {
+		name string
+		size int
+	}{
+		{"DCIM/100MSDCF/DSC00001.JPG", 100},
+		{"DCIM/100MSDCF/DSC00002.JPG", 5},
+		{"DCIM/100MSDCF/DSC00003.JPG.part", 100},
+		{"DCIM/100MSDCF/._DSC00001.JPG", 100},
+		{"PRIVATE/M4ROOT/CLIP/C0001.MP4", 100},
+		{"PRIVATE/M4ROOT/THMBNL/C0001T01.JPG", 100},
+		{"Backup/old.jpg", 100},
+		{".Trashes/501/deleted.jpg", 100},
+		{"DCIM/EDITS/edit.jpg", 100},
+	}
+
+	for _, tf := range testFiles {
+		path := filepath.Join(tmpDir, tf.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, tf.size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewWithOptions([]string{"jpg", "mp4"}, Options{
+		ExcludePatterns: []string{"DCIM/EDITS/**"},
+		MinSize:         10,
+		CardMode:        true,
+	})
+	entries, err := s.Scan(tmpDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 files, got %d: %+v", len(entries), entries)
+	}
+
+	stats := s.SkipStats()
+	expected := map[string]int{
+		SkipMinSize:                  1,
+		SkipPartial:                  1,
+		SkipSystem:                   1,
+		SkipSystem + SkipDirSuffix:   2,
+		SkipCardMode + SkipDirSuffix: 1,
+		SkipExclude:                  1,
+	}
+	for rule, n := range expected {
+		if stats[rule] != n {
+			t.Errorf("expected %d skipped by %s, got %d", n, rule, stats[rule])
+		}
+	}
+}
+
+func TestGlobToRegexp_Classes(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"IMG_[0-9]*", "DCIM/100/IMG_0001.JPG", true},
+		{"IMG_[0-9]*", "DCIM/100/IMG_A001.JPG", false},
+		{"IMG_[!0-9]*", "IMG_A001.JPG", true},
+		{"IMG_[^0-9]*", "IMG_0001.JPG", false},
+		{"DSC[AB]*.JPG", "dscb0001.jpg", true},
+		{"a[/]b", "a/b", false},
+		{"[x", "[x", true},
+		{"DCIM/1[0-9][0-9]*/**", "DCIM/100MSDCF/DSC00001.JPG", true},
+	}
+	for _, tt := range tests {
+		re := compileGlobs([]string{tt.glob})
+		if len(re) != 1 {
+			t.Errorf("%q did not compile", tt.glob)
+			continue
+		}
+		if got := re[0].MatchString(tt.path); got != tt.match {
+			t.Errorf("%q against %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	base := time.Date(2025, 12, 31, 15, 30, 0, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 12, 31, 15, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)},
+		{"@nightly", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 20 * * 1-5", time.Date(2025, 12, 31, 20, 30, 0, 0, time.UTC)},
+		{"0 9 * * 6,7", time.Date(2026, 1, 3, 9, 0, 0, 0, time.UTC)},
+		{"0 0 15 * *", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got := s.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a b c d e"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
@@ -61,7 +61,37 @@ func (s *State) Save() error {
 		return err
 	}
 
-	return os.WriteFile(s.filePath, data, 0644)
+	// Write to a temp file, sync and rename so an interrupted save or a
+	// power loss never leaves a truncated state file behind
+	tmpFile := s.filePath + ".tmp"
+	if err := writeSynced(tmpFile, data); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	if err := os.Rename(tmpFile, s.filePath); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(s.filePath)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 func (s *State) IsProcessed(path string, size int64) bool {
@@ -86,3 +116,13 @@ func (s *State) MarkProcessed(path string, size int64, destPath string) {
 	}
 	s.LastRun = time.Now()
 }
+
+// Remove forgets processed files so they are copied again.
+func (s *State) Remove(paths ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, path := range paths {
+		delete(s.Processed, path)
+	}
+}
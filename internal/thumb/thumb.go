@@ -0,0 +1,229 @@
+// Package thumb makes small JPEG thumbnails of photos and clips and caches
+// them on disk by content, so a card and its archived copy share one entry.
+package thumb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/On-Jun9/ShutterPipe/internal/metadata/preview"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+const (
+	// Size is the longest edge of a thumbnail in pixels.
+	Size = 320
+	// quality is the JPEG quality of generated thumbnails.
+	quality = 80
+	// fingerprintChunk is how much of the start and end of a file the
+	// fingerprint reads, so large clips don't have to be read in full.
+	fingerprintChunk = 64 << 10
+	// cacheVersion changes the cache keys when thumbnails are made
+	// differently.
+	cacheVersion = "v1"
+)
+
+// ErrNoThumbnail is returned for files no thumbnail can be made for.
+var ErrNoThumbnail = errors.New("no thumbnail available")
+
+// DefaultCacheDir returns where thumbnails are cached.
+func DefaultCacheDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".shutterpipe", "cache", "thumbs")
+}
+
+// Generator makes thumbnails and caches them in a directory.
+type Generator struct {
+	dir string
+	// sem limits concurrent decodes, which can each hold a full-size image.
+	sem chan struct{}
+}
+
+// New creates a generator caching in dir.
+func New(dir string) *Generator {
+	return &Generator{dir: dir, sem: make(chan struct{}, runtime.NumCPU())}
+}
+
+// Thumbnail returns a JPEG thumbnail of the file at path and its content
+// fingerprint. Photos use their EXIF thumbnail or embedded RAW preview when
+// they have one and are decoded and scaled down otherwise. Sony clips use
+// the THMBNL image the camera wrote next to them.
+func (g *Generator) Thumbnail(path string) ([]byte, string, error) {
+	key, err := fingerprint(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cachePath := filepath.Join(g.dir, key[:2], key+".jpg")
+	if data, err := os.ReadFile(cachePath); err == nil {
+		return data, key, nil
+	}
+
+	g.sem <- struct{}{}
+	img, err := decode(path)
+	if err != nil {
+		<-g.sem
+		return nil, "", err
+	}
+	thumb := orient(resize(img, Size), orientation(path))
+	<-g.sem
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", err
+	}
+	if err := writeCache(cachePath, buf.Bytes()); err != nil {
+		return nil, "", fmt.Errorf("failed to cache thumbnail: %w", err)
+	}
+	return buf.Bytes(), key, nil
+}
+
+// decode returns the image to make a thumbnail from.
+func decode(path string) (image.Image, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch preview.FormatOf(ext) {
+	case preview.RAW:
+		img, err := preview.DecodeRAW(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNoThumbnail, err)
+		}
+		return img, nil
+	case preview.Image:
+		if img, err := exifThumbnail(path); err == nil {
+			return img, nil
+		}
+		return decodeFile(path)
+	}
+
+	if thumbPath, ok := sonyThumbnail(path); ok {
+		return decodeFile(thumbPath)
+	}
+	return nil, ErrNoThumbnail
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoThumbnail, err)
+	}
+	return img, nil
+}
+
+// exifThumbnail decodes the thumbnail cameras embed in the EXIF data of a
+// JPEG, which saves decoding the full image.
+func exifThumbnail(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	data, err := x.JpegThumbnail()
+	if err != nil {
+		return nil, err
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+// sonyThumbnail returns the image a Sony camera writes for a clip:
+// PRIVATE/M4ROOT/CLIP/C0001.MP4 has PRIVATE/M4ROOT/THMBNL/C0001T01.JPG.
+// Names are matched ignoring case, as cards may be mounted either way.
+func sonyThumbnail(path string) (string, bool) {
+	clipDir := filepath.Dir(path)
+	thumbDir, ok := findEntry(filepath.Dir(clipDir), "THMBNL")
+	if !ok {
+		return "", false
+	}
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return findEntry(thumbDir, stem+"T01.JPG")
+}
+
+// findEntry returns the path of the entry in dir named name, ignoring case.
+func findEntry(dir, name string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return filepath.Join(dir, entry.Name()), true
+		}
+	}
+	return "", false
+}
+
+// fingerprint identifies the content of a file by its size and the hash of
+// its first and last fingerprintChunk bytes.
+func fingerprint(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%w: %s is a directory", ErrNoThumbnail, path)
+	}
+
+	h := sha256.New()
+	io.WriteString(h, cacheVersion)
+	binary.Write(h, binary.LittleEndian, info.Size())
+	if _, err := io.CopyN(h, f, fingerprintChunk); err != nil && err != io.EOF {
+		return "", err
+	}
+	if info.Size() > fingerprintChunk {
+		tail := max(info.Size()-fingerprintChunk, fingerprintChunk)
+		if _, err := io.Copy(h, io.NewSectionReader(f, tail, fingerprintChunk)); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeCache stores data at path through a temporary file, so concurrent
+// readers never see a partial thumbnail.
+func writeCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
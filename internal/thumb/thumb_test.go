@@ -0,0 +1,188 @@
+package thumb
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJPEG(t *testing.T, path string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeThumb(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestThumbnail_DownscalesAndCaches(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "DSC00001.JPG")
+	writeJPEG(t, src, 1000, 500)
+
+	g := New(filepath.Join(dir, "cache"))
+	data, key, err := g.Thumbnail(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := decodeThumb(t, data).Bounds(); b.Dx() != Size || b.Dy() != Size/2 {
+		t.Errorf("thumbnail is %dx%d", b.Dx(), b.Dy())
+	}
+
+	cached := filepath.Join(dir, "cache", key[:2], key+".jpg")
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("thumbnail not cached: %v", err)
+	}
+
+	// The archived copy of the same file uses the cached thumbnail
+	copyPath := filepath.Join(dir, "archive", "DSC00001.JPG")
+	content, _ := os.ReadFile(src)
+	os.MkdirAll(filepath.Dir(copyPath), 0755)
+	os.WriteFile(copyPath, content, 0644)
+	os.WriteFile(cached, []byte("cached"), 0644)
+
+	data, copyKey, err := g.Thumbnail(copyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copyKey != key || string(data) != "cached" {
+		t.Errorf("copy was not served from the cache (key %s, %d bytes)", copyKey, len(data))
+	}
+}
+
+func TestThumbnail_PNGOnWhite(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "shot.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, image.NewNRGBA(image.Rect(0, 0, 40, 20)))
+	f.Close()
+
+	data, _, err := New(filepath.Join(dir, "cache")).Thumbnail(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decodeThumb(t, data)
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 20 {
+		t.Errorf("small image was resized to %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := img.At(10, 10).RGBA(); r < 0xf000 {
+		t.Errorf("transparent pixel is not white: %x", r)
+	}
+}
+
+func TestThumbnail_SonyClip(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "PRIVATE", "M4ROOT")
+	clip := filepath.Join(root, "CLIP", "C0001.MP4")
+	os.MkdirAll(filepath.Dir(clip), 0755)
+	os.WriteFile(clip, []byte("not really a video"), 0644)
+	writeJPEG(t, filepath.Join(root, "THMBNL", "C0001T01.JPG"), 160, 90)
+
+	g := New(filepath.Join(dir, "cache"))
+	data, _, err := g.Thumbnail(clip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := decodeThumb(t, data).Bounds(); b.Dx() != 160 || b.Dy() != 90 {
+		t.Errorf("thumbnail is %dx%d", b.Dx(), b.Dy())
+	}
+
+	other := filepath.Join(root, "CLIP", "C0002.MP4")
+	os.WriteFile(other, []byte("another clip"), 0644)
+	if _, _, err := g.Thumbnail(other); !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("clip without THMBNL image: %v", err)
+	}
+}
+
+func TestThumbnail_RAWPreview(t *testing.T) {
+	dir := t.TempDir()
+	preview := writeJPEG(t, filepath.Join(dir, "preview.jpg"), 640, 480)
+
+	// RAW files carry the preview somewhere after their own headers
+	raw := append([]byte("II*\x00 not a real sensor dump "), preview...)
+	src := filepath.Join(dir, "DSC00002.ARW")
+	os.WriteFile(src, raw, 0644)
+
+	data, _, err := New(filepath.Join(dir, "cache")).Thumbnail(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := decodeThumb(t, data).Bounds(); b.Dx() != Size || b.Dy() != 240 {
+		t.Errorf("thumbnail is %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestOrient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red := color.RGBA{255, 0, 0, 255}
+	img.SetRGBA(0, 0, red)
+
+	// Orientation 6 is stored rotated 90° counterclockwise
+	got := orient(img, 6)
+	if b := got.Bounds(); b.Dx() != 1 || b.Dy() != 2 {
+		t.Fatalf("rotated to %dx%d", b.Dx(), b.Dy())
+	}
+	if got.RGBAAt(0, 0) != red {
+		t.Errorf("top pixel = %v", got.RGBAAt(0, 0))
+	}
+
+	got = orient(img, 8)
+	if got.RGBAAt(0, 1) != red {
+		t.Errorf("bottom pixel = %v", got.RGBAAt(0, 1))
+	}
+	if orient(img, 1) != img {
+		t.Error("upright image was copied")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, data, 0644)
+		return path
+	}
+
+	// Files between one and two chunks must be hashed in full
+	data := make([]byte, fingerprintChunk+1000)
+	a, _ := fingerprint(write("a", data))
+	data[fingerprintChunk+500] = 1
+	b, _ := fingerprint(write("b", data))
+	if a == b {
+		t.Error("change past the first chunk not detected")
+	}
+
+	if _, err := fingerprint(dir); !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("directory: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package watch
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount describes a mounted volume.
+type Mount struct {
+	// Path is the mount point.
+	Path string
+	// Device is the mount source (e.g. "/dev/sdb1").
+	Device string
+	// FSType is the filesystem type (e.g. "exfat", "vfat").
+	FSType string
+	// Label is the volume label, derived from the mount point name.
+	Label string
+	// UUID is the filesystem UUID (card ID), if it could be resolved.
+	UUID string
+}
+
+// ReadMounts parses /proc/self/mountinfo.
+func ReadMounts() ([]Mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts, err := parseMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+
+	uuids := deviceUUIDs()
+	for i := range mounts {
+		mounts[i].UUID = uuids[mounts[i].Device]
+	}
+	return mounts, nil
+}
+
+// parseMountInfo parses the mountinfo format described in proc(5):
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+
+		path := unescapeMountPath(fields[4])
+		mounts = append(mounts, Mount{
+			Path:   path,
+			Device: unescapeMountPath(fields[sep+2]),
+			FSType: fields[sep+1],
+			Label:  filepath.Base(path),
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes octal escapes such as "\040" for spaces.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// deviceUUIDs maps device paths to filesystem UUIDs via /dev/disk/by-uuid.
+func deviceUUIDs() map[string]string {
+	uuids := make(map[string]string)
+
+	entries, err := os.ReadDir("/dev/disk/by-uuid")
+	if err != nil {
+		return uuids
+	}
+	for _, e := range entries {
+		link := filepath.Join("/dev/disk/by-uuid", e.Name())
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		uuids[target] = e.Name()
+	}
+	return uuids
+}
+
+// HasDCIM reports whether the volume contains a DCF "DCIM" directory.
+func HasDCIM(mountPath string) bool {
+	entries, err := os.ReadDir(mountPath)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if e.IsDir() && strings.EqualFold(e.Name(), "DCIM") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,321 @@
+// Package watch ingests camera cards automatically when they are mounted.
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/metrics"
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule selects volumes and the preset used to ingest them. All non-empty
+// conditions must match.
+type Rule struct {
+	Name string `yaml:"name"`
+	// Label is a glob matched against the volume label (e.g. "SONY*").
+	Label string `yaml:"label"`
+	// CardID is the filesystem UUID of a specific card.
+	CardID string `yaml:"card_id"`
+	// RequireDCIM only matches volumes with a DCIM directory.
+	RequireDCIM bool `yaml:"require_dcim"`
+	// Preset is the name of the saved preset to run.
+	Preset string `yaml:"preset"`
+	// CardMode restricts the scan to DCIM and vendor video folders.
+	CardMode bool `yaml:"card_mode"`
+}
+
+// Config configures the watch daemon.
+type Config struct {
+	Interval   time.Duration `yaml:"interval"`
+	MountRoots []string      `yaml:"mount_roots"`
+	// LogDir receives one log file per ingest.
+	LogDir string `yaml:"log_dir"`
+	Rules  []Rule `yaml:"rules"`
+	// MetricsAddr, if set, serves Prometheus metrics at /metrics there.
+	MetricsAddr string `yaml:"metrics_addr"`
+}
+
+// DefaultConfig returns a config polling the usual removable-media roots.
+func DefaultConfig() *Config {
+	homeDir, _ := os.UserHomeDir()
+	return &Config{
+		Interval:   5 * time.Second,
+		MountRoots: []string{"/media", "/run/media", "/mnt"},
+		LogDir:     filepath.Join(homeDir, ".shutterpipe", "watch-logs"),
+	}
+}
+
+// LoadConfig reads a YAML watch config on top of the defaults.
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Matches reports whether the rule applies to the mount.
+func (r Rule) Matches(m Mount, hasDCIM bool) bool {
+	if r.RequireDCIM && !hasDCIM {
+		return false
+	}
+	if r.CardID != "" && !strings.EqualFold(r.CardID, m.UUID) {
+		return false
+	}
+	if r.Label != "" {
+		ok, err := filepath.Match(strings.ToLower(r.Label), strings.ToLower(m.Label))
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Watcher polls mount points and runs a pipeline for matching volumes.
+type Watcher struct {
+	cfg     *Config
+	presets *config.PresetManager
+
+	mu       sync.Mutex
+	seen     map[string]bool
+	active   map[string]*pipeline.Pipeline
+	stopping bool
+
+	// ingestMu serializes ingests so runs never race on the state file.
+	ingestMu sync.Mutex
+	// waiting counts ingests queued behind the running one.
+	waiting atomic.Int32
+
+	metrics *metrics.Collector
+}
+
+// New creates a watcher.
+func New(cfg *Config) (*Watcher, error) {
+	pm, err := config.NewPresetManager()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		cfg:     cfg,
+		presets: pm,
+		seen:    make(map[string]bool),
+		active:  make(map[string]*pipeline.Pipeline),
+		metrics: metrics.NewCollector(),
+	}
+	w.metrics.SetQueueDepth(w.QueueDepth)
+	return w, nil
+}
+
+// QueueDepth returns the number of ingests waiting for the running one.
+func (w *Watcher) QueueDepth() int {
+	return int(w.waiting.Load())
+}
+
+// serveMetrics serves the metrics on MetricsAddr until the returned
+// function is called.
+func (w *Watcher) serveMetrics() func() {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", w.metrics)
+	srv := &http.Server{Addr: w.cfg.MetricsAddr, Handler: mux}
+
+	go func() {
+		fmt.Printf("[watch] serving metrics at http://%s/metrics\n", w.cfg.MetricsAddr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("[watch] metrics server stopped: %v\n", err)
+		}
+	}()
+	return func() { srv.Close() }
+}
+
+// Run polls until stop is closed. Volumes already mounted at startup are
+// ingested as well.
+func (w *Watcher) Run(stop <-chan struct{}) error {
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if w.cfg.MetricsAddr != "" {
+		defer w.serveMetrics()()
+	}
+
+	for {
+		if err := w.poll(); err != nil {
+			fmt.Printf("[watch] failed to read mounts: %v\n", err)
+		}
+
+		select {
+		case <-stop:
+			w.cancelAll()
+			// Wait for the running ingest to save its state
+			w.ingestMu.Lock()
+			w.ingestMu.Unlock()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) poll() error {
+	mounts, err := ReadMounts()
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, m := range mounts {
+		if !w.underRoots(m.Path) {
+			continue
+		}
+		present[m.Path] = true
+
+		w.mu.Lock()
+		seen := w.seen[m.Path]
+		w.seen[m.Path] = true
+		w.mu.Unlock()
+		if seen {
+			continue
+		}
+
+		rule, ok := w.matchRule(m)
+		if !ok {
+			continue
+		}
+		fmt.Printf("[watch] %s (%s) matched rule %q\n", m.Path, m.Label, rule.Name)
+		go w.ingest(m, rule)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path := range w.seen {
+		if present[path] {
+			continue
+		}
+		delete(w.seen, path)
+		if p, ok := w.active[path]; ok {
+			fmt.Printf("[watch] %s removed during ingest, cancelling\n", path)
+			p.Cancel()
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) underRoots(path string) bool {
+	for _, root := range w.cfg.MountRoots {
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) matchRule(m Mount) (Rule, bool) {
+	hasDCIM := HasDCIM(m.Path)
+	for _, r := range w.cfg.Rules {
+		if r.Matches(m, hasDCIM) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (w *Watcher) ingest(m Mount, rule Rule) {
+	w.waiting.Add(1)
+	w.ingestMu.Lock()
+	w.waiting.Add(-1)
+	defer w.ingestMu.Unlock()
+
+	w.mu.Lock()
+	stopping := w.stopping
+	w.mu.Unlock()
+	if stopping {
+		return
+	}
+
+	// The card may have been pulled while waiting for another ingest
+	if _, err := os.Stat(m.Path); err != nil {
+		fmt.Printf("[watch] %s no longer available, skipping\n", m.Path)
+		return
+	}
+
+	preset, err := w.presets.LoadPreset(rule.Preset)
+	if err != nil {
+		fmt.Printf("[watch] rule %q: %v\n", rule.Name, err)
+		w.metrics.StartRun(rule.Preset, false).Finish(nil, err)
+		return
+	}
+
+	cfg := config.PresetToConfig(preset)
+	cfg.Source = m.Path
+	if rule.CardMode {
+		cfg.CardMode = true
+	}
+	cfg.LogFile = filepath.Join(w.cfg.LogDir, ingestLogName(m.Label))
+	run := w.metrics.StartRun(rule.Preset, cfg.DryRun)
+	if err := pipeline.Validate(cfg); err != nil {
+		fmt.Printf("[watch] rule %q: invalid preset %q: %v\n", rule.Name, rule.Preset, err)
+		run.Finish(nil, err)
+		return
+	}
+
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		fmt.Printf("[watch] %s: %v\n", m.Path, err)
+		run.Finish(nil, err)
+		return
+	}
+	defer p.Close()
+	p.Events().Subscribe(run.Observe)
+
+	w.mu.Lock()
+	w.active[m.Path] = p
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.active, m.Path)
+		w.mu.Unlock()
+	}()
+
+	fmt.Printf("[watch] ingesting %s with preset %q (log: %s)\n", m.Path, rule.Preset, cfg.LogFile)
+	summary, err := p.Run()
+	run.Finish(summary, err)
+	if err != nil {
+		fmt.Printf("[watch] ingest of %s failed: %v\n", m.Path, err)
+		return
+	}
+	fmt.Printf("[watch] ingest of %s finished: %d copied, %d failed\n", m.Path, summary.Copied, summary.Failed)
+}
+
+func (w *Watcher) cancelAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopping = true
+	for _, p := range w.active {
+		p.Cancel()
+	}
+}
+
+var unsafeLogChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func ingestLogName(label string) string {
+	return time.Now().Format("20060102-150405") + "-" + unsafeLogChars.ReplaceAllString(label, "_") + ".log"
+}
@@ -0,0 +1,52 @@
+package watch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	input := `23 28 0:22 / /proc rw,relatime - proc proc rw
+98 28 8:17 / /media/alice/SONY\040A7 rw,nosuid,nodev shared:50 - exfat /dev/sdb1 rw,fmask=0022
+99 28 8:33 / /run/media/bob/EOS_DIGITAL rw,nosuid - vfat /dev/sdc1 rw
+`
+	mounts, err := parseMountInfo(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 3 {
+		t.Fatalf("expected 3 mounts, got %d", len(mounts))
+	}
+
+	m := mounts[1]
+	if m.Path != "/media/alice/SONY A7" {
+		t.Errorf("unexpected path %q", m.Path)
+	}
+	if m.Label != "SONY A7" || m.Device != "/dev/sdb1" || m.FSType != "exfat" {
+		t.Errorf("unexpected mount %+v", m)
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	m := Mount{Path: "/media/alice/SONY A7", Label: "SONY A7", UUID: "1234-ABCD"}
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		hasDCIM bool
+		want    bool
+	}{
+		{"label glob", Rule{Label: "sony*"}, false, true},
+		{"label mismatch", Rule{Label: "EOS*"}, true, false},
+		{"dcim required", Rule{RequireDCIM: true}, false, false},
+		{"dcim present", Rule{RequireDCIM: true}, true, true},
+		{"card id", Rule{CardID: "1234-abcd"}, false, true},
+		{"card id mismatch", Rule{CardID: "FFFF-0000", Label: "SONY*"}, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Matches(m, tt.hasDCIM); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
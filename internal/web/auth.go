@@ -0,0 +1,382 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role is what an authenticated client may do.
+type Role string
+
+const (
+	// RoleAdmin may start runs and change settings.
+	RoleAdmin Role = "admin"
+	// RoleViewer may only watch progress and read history.
+	RoleViewer Role = "viewer"
+)
+
+// AuthConfig enables login for the web UI and API. Without a password or
+// token every request is allowed.
+type AuthConfig struct {
+	// Password logs in as admin, ViewerPassword as viewer.
+	Password       string
+	ViewerPassword string
+	// Token grants admin access to API clients that send it as
+	// "Authorization: Bearer <token>".
+	Token      string
+	SessionTTL time.Duration
+}
+
+const (
+	DefaultSessionTTL = 12 * time.Hour
+
+	sessionCookie = "shutterpipe_session"
+	csrfHeader    = "X-CSRF-Token"
+
+	// maxLoginFailures from one address lock it out for loginLockout.
+	maxLoginFailures = 5
+	loginLockout     = 15 * time.Minute
+)
+
+// publicPaths can be requested without logging in.
+var publicPaths = map[string]bool{
+	"/api/auth/status": true,
+	"/api/auth/login":  true,
+	"/api/auth/logout": true,
+	"/api/version":     true,
+}
+
+// viewerDenied lists read endpoints that are still off limits for viewers
+// because they expose the filesystem or saved configs. Each entry also
+// covers the paths below it.
+var viewerDenied = []string{
+	"/api/browse",
+	"/api/thumb",
+	"/api/quarantine",
+	"/api/presets",
+	"/api/jobs",
+}
+
+// deniedToViewer reports whether path is one of viewerDenied or below it.
+func deniedToViewer(path string) bool {
+	for _, p := range viewerDenied {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type session struct {
+	role    Role
+	csrf    string
+	expires time.Time
+}
+
+type loginFailures struct {
+	count int
+	reset time.Time
+}
+
+type authenticator struct {
+	cfg      AuthConfig
+	mu       sync.Mutex
+	sessions map[string]*session
+	failures map[string]*loginFailures
+}
+
+func newAuthenticator(cfg AuthConfig) *authenticator {
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = DefaultSessionTTL
+	}
+	return &authenticator{
+		cfg:      cfg,
+		sessions: make(map[string]*session),
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+// SetAuth enables authentication. Call it before Start.
+func (s *Server) SetAuth(cfg AuthConfig) {
+	if cfg.Password == "" && cfg.ViewerPassword == "" && cfg.Token == "" {
+		s.auth = nil
+		return
+	}
+	s.auth = newAuthenticator(cfg)
+}
+
+// secretEqual compares in constant time. Hashing first hides the length.
+func secretEqual(secret, given string) bool {
+	if secret == "" {
+		return false
+	}
+	a := sha256.Sum256([]byte(secret))
+	b := sha256.Sum256([]byte(given))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// checkPassword returns the role the password logs in as.
+func (a *authenticator) checkPassword(password string) (Role, bool) {
+	switch {
+	case secretEqual(a.cfg.Password, password):
+		return RoleAdmin, true
+	case secretEqual(a.cfg.ViewerPassword, password):
+		return RoleViewer, true
+	}
+	return "", false
+}
+
+// lockedOut reports whether addr failed to log in too often.
+func (a *authenticator) lockedOut(addr string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, ok := a.failures[addr]
+	if !ok {
+		return false
+	}
+	if time.Now().After(f.reset) {
+		delete(a.failures, addr)
+		return false
+	}
+	return f.count >= maxLoginFailures
+}
+
+func (a *authenticator) recordFailure(addr string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, ok := a.failures[addr]
+	if !ok || time.Now().After(f.reset) {
+		f = &loginFailures{reset: time.Now().Add(loginLockout)}
+		a.failures[addr] = f
+	}
+	f.count++
+}
+
+func (a *authenticator) newSession(addr string, role Role) (string, *session) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.failures, addr)
+
+	now := time.Now()
+	for id, sess := range a.sessions {
+		if now.After(sess.expires) {
+			delete(a.sessions, id)
+		}
+	}
+
+	id := randomToken()
+	sess := &session{role: role, csrf: randomToken(), expires: now.Add(a.cfg.SessionTTL)}
+	a.sessions[id] = sess
+	return id, sess
+}
+
+// session returns the live session of the request's cookie and extends it.
+func (a *authenticator) session(r *http.Request) (string, *session) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sess, ok := a.sessions[cookie.Value]
+	if !ok {
+		return "", nil
+	}
+	if time.Now().After(sess.expires) {
+		delete(a.sessions, cookie.Value)
+		return "", nil
+	}
+	sess.expires = time.Now().Add(a.cfg.SessionTTL)
+	return cookie.Value, sess
+}
+
+func (a *authenticator) endSession(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, id)
+}
+
+// bearer reports whether the request carries the API token.
+func (a *authenticator) bearer(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && secretEqual(a.cfg.Token, token)
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sameOrigin rejects browser requests sent from other sites. Requests
+// without an Origin header come from non-browser clients.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authMiddleware guards the API: cross-site writes are always rejected and,
+// with authentication enabled, every request needs a session or the token.
+// Cookie sessions must send their CSRF token on writes and viewers may
+// only read.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The UI itself is public so it can show the login form
+		if !strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != metricsPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !safeMethod(r.Method) && !sameOrigin(r) {
+			http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		a := s.auth
+		if a == nil || publicPaths[r.URL.Path] || a.bearer(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, sess := a.session(r)
+		if sess == nil {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if !safeMethod(r.Method) && !secretEqual(sess.csrf, r.Header.Get(csrfHeader)) {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		if sess.role == RoleViewer && (!safeMethod(r.Method) || deniedToViewer(r.URL.Path)) {
+			http.Error(w, "read-only access", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Auth handlers
+
+type LoginRequest struct {
+	Password string `json:"password"`
+}
+
+// AuthStatus tells the UI whether it has to show the login form and what
+// the session may do.
+type AuthStatus struct {
+	Enabled       bool   `json:"enabled"`
+	Authenticated bool   `json:"authenticated"`
+	Role          Role   `json:"role,omitempty"`
+	CSRFToken     string `json:"csrf_token,omitempty"`
+}
+
+func (s *Server) authStatus(r *http.Request) AuthStatus {
+	if s.auth == nil {
+		return AuthStatus{Authenticated: true, Role: RoleAdmin}
+	}
+	if _, sess := s.auth.session(r); sess != nil {
+		return AuthStatus{Enabled: true, Authenticated: true, Role: sess.role, CSRFToken: sess.csrf}
+	}
+	return AuthStatus{Enabled: true}
+}
+
+func (s *Server) handleAuthStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.authStatus(r))
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	a := s.auth
+	if a == nil {
+		http.Error(w, "authentication is disabled", http.StatusNotFound)
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	addr := clientAddr(r)
+	if a.lockedOut(addr) {
+		http.Error(w, "too many failed logins, try again later", http.StatusTooManyRequests)
+		return
+	}
+	role, ok := a.checkPassword(req.Password)
+	if !ok {
+		a.recordFailure(addr)
+		http.Error(w, "invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	id, sess := a.newSession(addr, role)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthStatus{Enabled: true, Authenticated: true, Role: role, CSRFToken: sess.csrf})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if s.auth != nil {
+		if id, _ := s.auth.session(r); id != "" {
+			s.auth.endSession(id)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// isLoopback reports whether addr only listens on the local machine.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// newAuthServer serves the auth routes and stub API routes.
+func newAuthServer(cfg AuthConfig) *Server {
+	s := &Server{router: mux.NewRouter()}
+	s.SetAuth(cfg)
+	s.router.Use(s.authMiddleware)
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	api := s.router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/auth/status", s.handleAuthStatus).Methods("GET")
+	api.HandleFunc("/auth/login", s.handleLogin).Methods("POST")
+	api.HandleFunc("/auth/logout", s.handleLogout).Methods("POST")
+	api.HandleFunc("/run", ok).Methods("POST")
+	api.HandleFunc("/runs", ok).Methods("GET")
+	api.HandleFunc("/runs/{id}", ok).Methods("GET")
+	api.HandleFunc("/browse", ok).Methods("GET")
+	api.HandleFunc("/presets", ok).Methods("GET")
+	api.HandleFunc("/presets/load", ok).Methods("GET")
+	api.HandleFunc("/jobs", ok).Methods("GET")
+	api.HandleFunc("/jobs/{id}", ok).Methods("GET")
+	s.router.HandleFunc(metricsPath, ok).Methods("GET")
+	s.router.PathPrefix("/").HandlerFunc(ok)
+	return s
+}
+
+func serve(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// login returns the session cookie and CSRF token.
+func login(t *testing.T, s *Server, password string) (*http.Cookie, string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"password":"`+password+`"}`))
+	rec := serve(s, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d", rec.Code)
+	}
+	var status AuthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+	return cookies[0], status.CSRFToken
+}
+
+func TestAuthDisabled(t *testing.T) {
+	s := newAuthServer(AuthConfig{})
+
+	if rec := serve(s, httptest.NewRequest("POST", "/api/run", nil)); rec.Code != http.StatusOK {
+		t.Errorf("run without auth: status %d", rec.Code)
+	}
+
+	// Other sites must not be able to start runs even without auth
+	req := httptest.NewRequest("POST", "/api/run", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	if rec := serve(s, req); rec.Code != http.StatusForbidden {
+		t.Errorf("cross-origin run: status %d, want 403", rec.Code)
+	}
+}
+
+func TestAuthRequiresSession(t *testing.T) {
+	s := newAuthServer(AuthConfig{Password: "secret"})
+
+	if rec := serve(s, httptest.NewRequest("GET", "/api/runs", nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no session: status %d, want 401", rec.Code)
+	}
+	if rec := serve(s, httptest.NewRequest("GET", "/index.html", nil)); rec.Code != http.StatusOK {
+		t.Errorf("static files: status %d, want 200", rec.Code)
+	}
+	if rec := serve(s, httptest.NewRequest("GET", "/api/auth/status", nil)); rec.Code != http.StatusOK {
+		t.Errorf("auth status: status %d, want 200", rec.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"password":"wrong"}`))
+	if rec := serve(s, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d, want 401", rec.Code)
+	}
+
+	cookie, csrf := login(t, s, "secret")
+
+	req = httptest.NewRequest("GET", "/api/runs", nil)
+	req.AddCookie(cookie)
+	if rec := serve(s, req); rec.Code != http.StatusOK {
+		t.Errorf("with session: status %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/run", nil)
+	req.AddCookie(cookie)
+	if rec := serve(s, req); rec.Code != http.StatusForbidden {
+		t.Errorf("write without CSRF token: status %d, want 403", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/run", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(csrfHeader, csrf)
+	if rec := serve(s, req); rec.Code != http.StatusOK {
+		t.Errorf("write with CSRF token: status %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/auth/logout", nil)
+	req.AddCookie(cookie)
+	serve(s, req)
+
+	req = httptest.NewRequest("GET", "/api/runs", nil)
+	req.AddCookie(cookie)
+	if rec := serve(s, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status %d, want 401", rec.Code)
+	}
+}
+
+func TestAuthViewerIsReadOnly(t *testing.T) {
+	s := newAuthServer(AuthConfig{Password: "admin", ViewerPassword: "viewer"})
+	cookie, csrf := login(t, s, "viewer")
+
+	for _, path := range []string{"/api/runs", "/api/runs/abc"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		if rec := serve(s, req); rec.Code != http.StatusOK {
+			t.Errorf("viewer read %s: status %d", path, rec.Code)
+		}
+	}
+
+	for _, path := range []string{"/api/browse", "/api/presets", "/api/presets/load?name=x", "/api/jobs", "/api/jobs/abc"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		if rec := serve(s, req); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer read %s: status %d, want 403", path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/run", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(csrfHeader, csrf)
+	if rec := serve(s, req); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer run: status %d, want 403", rec.Code)
+	}
+}
+
+func TestAuthBearerToken(t *testing.T) {
+	s := newAuthServer(AuthConfig{Token: "tok"})
+
+	req := httptest.NewRequest("POST", "/api/run", nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	if rec := serve(s, req); rec.Code != http.StatusOK {
+		t.Errorf("valid token: status %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/run", nil)
+	req.Header.Set("Authorization", "Bearer nope")
+	if rec := serve(s, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token: status %d, want 401", rec.Code)
+	}
+
+	// Prometheus scrapes the metrics with the token
+	if rec := serve(s, httptest.NewRequest("GET", metricsPath, nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("metrics without token: status %d, want 401", rec.Code)
+	}
+	req = httptest.NewRequest("GET", metricsPath, nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	if rec := serve(s, req); rec.Code != http.StatusOK {
+		t.Errorf("metrics with token: status %d", rec.Code)
+	}
+}
+
+func TestAuthLockout(t *testing.T) {
+	s := newAuthServer(AuthConfig{Password: "secret"})
+	for i := 0; i < maxLoginFailures; i++ {
+		serve(s, httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"password":"wrong"}`)))
+	}
+
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"password":"secret"}`))
+	if rec := serve(s, req); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("after %d failures: status %d, want 429", maxLoginFailures, rec.Code)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://example.com", true},
+		{"https://example.com", true},
+		{"http://evil.example", false},
+		{"http://example.com:8080", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/ws", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := sameOrigin(req); got != tt.want {
+			t.Errorf("sameOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
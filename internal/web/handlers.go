@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,8 +10,13 @@ import (
 	"sync"
 
 	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/notify"
 	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/internal/quarantine"
+	"github.com/On-Jun9/ShutterPipe/internal/report"
 	"github.com/On-Jun9/ShutterPipe/pkg/types"
+	"github.com/gorilla/mux"
 )
 
 type BrowseRequest struct {
@@ -27,15 +33,30 @@ type DirEntry struct {
 	Name  string `json:"name"`
 	Path  string `json:"path"`
 	IsDir bool   `json:"is_dir"`
+	// Root marks a shortcut to an allowed source or dest root.
+	Root string `json:"root,omitempty"`
 }
 
 func (s *Server) handleBrowse(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
+	if path == "" && s.roots != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BrowseResponse{Entries: s.rootEntries()})
+		return
+	}
 	if path == "" {
 		homeDir, _ := os.UserHomeDir()
 		path = homeDir
 	}
 
+	path, err := s.allowPath("", path)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(BrowseResponse{Error: err.Error()})
+		return
+	}
+
 	entries, err := os.ReadDir(path)
 	if err != nil {
 		json.NewEncoder(w).Encode(BrowseResponse{Error: err.Error()})
@@ -96,21 +117,50 @@ func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cfg config.Config
-	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+	// Preset names the preset the UI loaded; its notifiers are used
+	var req struct {
+		config.Config
+		Preset string `json:"preset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		runMutex.Unlock()
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	cfg := req.Config
 
 	// DEBUG LOG: Check received configuration
 	fmt.Printf("Received Run Request: Source='%s', Dest='%s'\n", cfg.Source, cfg.Dest)
 
-	if err := cfg.Validate(); err != nil {
+	var stored *types.NotifyConfig
+	if req.Preset != "" {
+		preset, err := loadPreset(req.Preset)
+		if err != nil {
+			runMutex.Unlock()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stored = preset.Notify
+	}
+	notifyCfg, err := webNotify(cfg.Notify, stored)
+	if err != nil {
+		runMutex.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg.Notify = notifyCfg
+
+	clearServerFiles(&cfg)
+	if err := pipeline.Validate(&cfg); err != nil {
 		runMutex.Unlock()
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := s.allowConfig(&cfg); err != nil {
+		runMutex.Unlock()
+		pathError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
@@ -124,33 +174,110 @@ func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
 			}
 		}()
 
-		p, err := pipeline.New(&cfg)
-		if err != nil {
-			s.broadcastProgress(pipeline.ProgressUpdate{Type: "error", Error: err.Error()})
-			return
-		}
+		s.executeRun(req.Preset, &cfg, nil)
+	}()
+}
 
-		fmt.Println("Pipeline initialized")
+// executeRun creates and runs a pipeline, broadcasting its events. preset
+// names the preset the config came from, if any, for the metrics. If
+// started is set it receives the pipeline before the run begins, so the
+// caller can cancel it. The caller must hold runMutex.
+func (s *Server) executeRun(preset string, cfg *config.Config, started func(p *pipeline.Pipeline)) (*types.RunSummary, error) {
+	run := s.metrics.StartRun(preset, cfg.DryRun)
 
-		defer func() {
-			fmt.Println("Closing pipeline...")
-			p.Close()
-			fmt.Println("Pipeline closed")
-		}()
+	// Queued and scheduled runs were checked when created, but the roots or
+	// symlinks may have changed since
+	if err := s.allowConfig(cfg); err != nil {
+		s.broadcastProgress(pipeline.ProgressUpdate{Type: pipeline.EventError, Error: err.Error()})
+		run.Finish(nil, err)
+		return nil, err
+	}
 
-		p.SetProgressCallback(func(update pipeline.ProgressUpdate) {
-			s.broadcastProgress(update)
-		})
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		s.broadcastProgress(pipeline.ProgressUpdate{Type: pipeline.EventError, Error: err.Error()})
+		run.Finish(nil, err)
+		return nil, err
+	}
 
-		fmt.Println("Starting pipeline run...")
-		_, err = p.Run()
-		if err != nil {
-			fmt.Printf("Pipeline run failed: %v\n", err)
-			s.broadcastProgress(pipeline.ProgressUpdate{Type: "error", Error: err.Error()})
-			return
-		}
-		fmt.Println("Pipeline run completed successfully")
+	fmt.Println("Pipeline initialized")
+
+	defer func() {
+		fmt.Println("Closing pipeline...")
+		p.Close()
+		fmt.Println("Pipeline closed")
 	}()
+
+	// Run errors arrive as error events
+	p.Events().Subscribe(s.broadcastProgress)
+	p.Events().Subscribe(run.Observe)
+	if started != nil {
+		started(p)
+	}
+	s.active.Store(p)
+	defer s.active.Store(nil)
+
+	fmt.Println("Starting pipeline run...")
+	summary, err := p.Run()
+	run.Finish(summary, err)
+	if err != nil {
+		fmt.Printf("Pipeline run failed: %v\n", err)
+		return nil, err
+	}
+	fmt.Println("Pipeline run completed successfully")
+	return summary, nil
+}
+
+// Bandwidth handlers
+
+// BandwidthRequest sets the limits of the running copy in bytes per second.
+// Zero means unlimited.
+type BandwidthRequest struct {
+	Limit     int64 `json:"limit"`
+	PerWorker int64 `json:"per_worker"`
+}
+
+type BandwidthStatus struct {
+	Running    bool    `json:"running"`
+	Limit      int64   `json:"limit"`
+	PerWorker  int64   `json:"per_worker"`
+	Throughput float64 `json:"throughput"`
+}
+
+func (s *Server) bandwidthStatus() BandwidthStatus {
+	p := s.active.Load()
+	if p == nil {
+		return BandwidthStatus{}
+	}
+	limit, perWorker, throughput := p.Bandwidth()
+	return BandwidthStatus{Running: true, Limit: limit, PerWorker: perWorker, Throughput: throughput}
+}
+
+func (s *Server) handleGetBandwidth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.bandwidthStatus())
+}
+
+func (s *Server) handleSetBandwidth(w http.ResponseWriter, r *http.Request) {
+	var req BandwidthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 || req.PerWorker < 0 {
+		http.Error(w, "limits must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	p := s.active.Load()
+	if p == nil {
+		http.Error(w, "no backup running", http.StatusConflict)
+		return
+	}
+	p.SetBandwidth(req.Limit, req.PerWorker)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.bandwidthStatus())
 }
 
 func (s *Server) broadcastJSON(v interface{}) {
@@ -161,8 +288,17 @@ func (s *Server) broadcastJSON(v interface{}) {
 	s.hub.broadcast <- data
 }
 
+// broadcastProgress records update in the run state and sends it to every
+// client. Files skipped while planning only count towards the state.
 func (s *Server) broadcastProgress(update pipeline.ProgressUpdate) {
-	s.broadcastJSON(update)
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	seq := s.run.apply(update)
+	if update.Type == pipeline.EventFileSkipped {
+		return
+	}
+	s.broadcastJSON(progressMessage{ProgressUpdate: update, Seq: seq})
 }
 
 // Preset-related handlers
@@ -179,6 +315,9 @@ func (s *Server) handleListPresets(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for i := range presets {
+		presets[i].Notify = notify.Redact(presets[i].Notify)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(presets)
@@ -200,6 +339,10 @@ func (s *Server) handleSavePreset(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "preset name is required", http.StatusBadRequest)
 		return
 	}
+	if err := s.allowConfig(&req.Config); err != nil {
+		pathError(w, err)
+		return
+	}
 
 	pm, err := config.NewPresetManager()
 	if err != nil {
@@ -207,6 +350,17 @@ func (s *Server) handleSavePreset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var stored *types.NotifyConfig
+	if existing, err := pm.LoadPreset(req.Name); err == nil {
+		stored = existing.Notify
+	}
+	notifyCfg, err := webNotify(req.Config.Notify, stored)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Config.Notify = notifyCfg
+
 	preset := config.ConfigToPreset(&req.Config, req.Name, req.Description)
 	if err := pm.SavePreset(preset); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -217,6 +371,39 @@ func (s *Server) handleSavePreset(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// errNotifyCredentials rejects notifier credentials sent by a client. They
+// can only be set in config and preset files.
+var errNotifyCredentials = errors.New("notifier secrets and passwords can't be set through the web API")
+
+// webNotify returns the notifier settings for a config sent by a client.
+// Redacted credentials are taken from stored, which is also kept as a whole
+// if the client sent no notifier settings, as the web UI doesn't edit them.
+func webNotify(cfg, stored *types.NotifyConfig) (*types.NotifyConfig, error) {
+	if notify.HasCredentials(cfg) {
+		return nil, errNotifyCredentials
+	}
+	if cfg == nil {
+		return stored, nil
+	}
+	return notify.RestoreCredentials(cfg, stored), nil
+}
+
+// redactedConfig returns a copy of cfg without notifier credentials.
+func redactedConfig(cfg *config.Config) *config.Config {
+	out := *cfg
+	out.Notify = notify.Redact(cfg.Notify)
+	return &out
+}
+
+// loadPreset reads a saved preset by name.
+func loadPreset(name string) (*types.ConfigPreset, error) {
+	pm, err := config.NewPresetManager()
+	if err != nil {
+		return nil, err
+	}
+	return pm.LoadPreset(name)
+}
+
 func (s *Server) handleLoadPreset(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	if name == "" {
@@ -237,8 +424,13 @@ func (s *Server) handleLoadPreset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg := config.PresetToConfig(preset)
+	if err := s.allowConfig(cfg); err != nil {
+		pathError(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(cfg)
+	json.NewEncoder(w).Encode(redactedConfig(cfg))
 }
 
 func (s *Server) handleDeletePreset(w http.ResponseWriter, r *http.Request) {
@@ -278,6 +470,14 @@ func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Drop paths saved before the roots were configured
+	if _, err := s.allowPath(rootSource, settings.Source); err != nil {
+		settings.Source = ""
+	}
+	if _, err := s.allowPath(rootDest, settings.Dest); err != nil {
+		settings.Dest = ""
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(settings)
 }
@@ -288,6 +488,14 @@ func (s *Server) handleSaveSettings(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, err := s.allowPath(rootSource, settings.Source); err != nil {
+		pathError(w, err)
+		return
+	}
+	if _, err := s.allowPath(rootDest, settings.Dest); err != nil {
+		pathError(w, err)
+		return
+	}
 
 	m, err := config.NewUserDataManager()
 	if err != nil {
@@ -388,3 +596,173 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"version": s.version})
 }
+
+// Quarantine handlers
+
+type QuarantineRequest struct {
+	Dest          string `json:"dest"`
+	QuarantineDir string `json:"quarantine_dir"`
+	IDs           []int  `json:"ids"`
+}
+
+type QuarantineResult struct {
+	Entry quarantine.Entry `json:"entry"`
+	Error string           `json:"error,omitempty"`
+}
+
+func quarantinePath(dest, dir string) string {
+	if dir == "" {
+		dir = "quarantine"
+	}
+	return filepath.Join(dest, dir)
+}
+
+func (s *Server) handleListQuarantine(w http.ResponseWriter, r *http.Request) {
+	dest := r.URL.Query().Get("dest")
+	if dest == "" {
+		http.Error(w, "dest is required", http.StatusBadRequest)
+		return
+	}
+	path, err := s.allowPath(rootDest, quarantinePath(dest, r.URL.Query().Get("quarantine_dir")))
+	if err != nil {
+		pathError(w, err)
+		return
+	}
+
+	m, err := quarantine.Load(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entries := m.List(r.URL.Query().Get("all") != "true")
+	if entries == nil {
+		entries = []quarantine.Entry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (s *Server) handleResolveQuarantine(w http.ResponseWriter, r *http.Request) {
+	var resolve func(*quarantine.Manifest, int) (quarantine.Entry, error)
+	switch mux.Vars(r)["action"] {
+	case "accept":
+		resolve = (*quarantine.Manifest).Accept
+	case "reject":
+		resolve = (*quarantine.Manifest).Reject
+	case "replace":
+		resolve = (*quarantine.Manifest).Replace
+	default:
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	}
+
+	var req QuarantineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Dest == "" || len(req.IDs) == 0 {
+		http.Error(w, "dest and ids are required", http.StatusBadRequest)
+		return
+	}
+	path, err := s.allowPath(rootDest, quarantinePath(req.Dest, req.QuarantineDir))
+	if err != nil {
+		pathError(w, err)
+		return
+	}
+
+	results := make([]QuarantineResult, 0, len(req.IDs))
+	err = quarantine.Update(path, func(m *quarantine.Manifest) error {
+		for _, id := range req.IDs {
+			e, err := resolve(m, id)
+			res := QuarantineResult{Entry: e}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// Run history handlers
+
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.history.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []history.Run{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.history.Get(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// File results are served separately by /api/runs/{id}/files
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		history.Run
+		Config    config.Config `json:"config"`
+		FileCount int           `json:"file_count"`
+	}{rec.Run, *redactedConfig(&rec.Config), len(rec.Files)})
+}
+
+func (s *Server) handleGetRunFiles(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.history.Get(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	filter := history.Filter{
+		Action: types.CopyAction(r.URL.Query().Get("action")),
+		Error:  r.URL.Query().Get("error"),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filter.Apply(rec.Files))
+}
+
+func (s *Server) handleGetRunReport(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.history.Get(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = report.FormatHTML
+	}
+	if format != report.FormatHTML && format != report.FormatCSV && format != report.FormatJSON {
+		http.Error(w, "unknown report format", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	if format != report.FormatHTML {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "shutterpipe-"+rec.ID+"."+format))
+	}
+	if err := report.Build(rec).Write(w, format); err != nil {
+		fmt.Printf("failed to write report: %v\n", err)
+	}
+}
@@ -0,0 +1,308 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/notify"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// newRunServer returns a server whose default state, log and history files
+// live under a temporary home directory.
+func newRunServer(t *testing.T) *Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	return NewServer()
+}
+
+// postRun sends body to handleRun and waits for the started run to finish.
+func postRun(t *testing.T, s *Server, body map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.handleRun(rec, httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(string(data))))
+	runMutex.Lock()
+	runMutex.Unlock()
+	return rec
+}
+
+func TestHandleRun_IgnoresHooks(t *testing.T) {
+	s := newRunServer(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(dir, "hooked")
+
+	rec := postRun(t, s, map[string]any{
+		"source": src,
+		"dest":   filepath.Join(dir, "dest"),
+		"hooks": map[string]any{
+			"pre_run": []map[string]string{{"command": "touch " + marker}},
+		},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("hook from the request body was executed (stat err = %v)", err)
+	}
+}
+
+func TestHandleRun_IgnoresDesktopCommand(t *testing.T) {
+	s := newRunServer(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(dir, "notified")
+
+	rec := postRun(t, s, map[string]any{
+		"source": src,
+		"dest":   filepath.Join(dir, "dest"),
+		"notify": map[string]any{
+			"desktop": map[string]string{"command": "touch " + marker},
+		},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("desktop command from the request body was executed (stat err = %v)", err)
+	}
+}
+
+func TestHandleRun_IgnoresServerFiles(t *testing.T) {
+	s := newRunServer(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(dir, "elsewhere", "state.json")
+	logFile := filepath.Join(dir, "elsewhere", "run.log")
+
+	rec := postRun(t, s, map[string]any{
+		"source":     src,
+		"dest":       filepath.Join(dir, "dest"),
+		"state_file": stateFile,
+		"log_file":   logFile,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, path := range []string{stateFile, logFile} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s from the request body was used (stat err = %v)", filepath.Base(path), err)
+		}
+	}
+}
+
+// secretNotify returns notifier settings holding credentials that must not
+// appear in API responses.
+func secretNotify() *types.NotifyConfig {
+	return &types.NotifyConfig{
+		Webhooks: []types.WebhookNotify{{URL: "https://example.com/hook", Secret: "webhook-secret"}},
+		Email:    &types.EmailNotify{Host: "smtp.example.com", Password: "smtp-password", From: "a@example.com", To: []string{"b@example.com"}},
+	}
+}
+
+func assertRedacted(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "https://example.com/hook") {
+		t.Errorf("response has no notifier settings: %s", body)
+	}
+	for _, secret := range []string{"webhook-secret", "smtp-password"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response contains %q: %s", secret, body)
+		}
+	}
+}
+
+func get(s *Server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHandleGetRun_RedactsNotify(t *testing.T) {
+	s := newRunServer(t)
+	cfg := config.DefaultConfig()
+	cfg.Notify = secretNotify()
+	if err := s.history.Save(&history.Record{Run: history.Run{ID: "run1"}, Config: *cfg}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertRedacted(t, get(s, "/api/runs/run1"))
+}
+
+func TestHandlePresets_RedactNotify(t *testing.T) {
+	s := newRunServer(t)
+	pm, err := config.NewPresetManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Notify = secretNotify()
+	if err := pm.SavePreset(config.ConfigToPreset(cfg, "secret", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	assertRedacted(t, get(s, "/api/presets"))
+	assertRedacted(t, get(s, "/api/presets/load?name=secret"))
+}
+
+func TestHandleJobs_RedactNotify(t *testing.T) {
+	s := newRunServer(t)
+	m, err := NewJobManager(s, filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Notify = secretNotify()
+	m.jobs["job1"] = &Job{ID: "job1", Config: cfg, Status: JobStatusCompleted}
+	s.jobs = m
+
+	assertRedacted(t, get(s, "/api/jobs"))
+	assertRedacted(t, get(s, "/api/jobs/job1"))
+	if cfg.Notify.Webhooks[0].Secret != "webhook-secret" {
+		t.Error("redacting the response changed the stored job")
+	}
+}
+
+func post(t *testing.T, s *Server, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(data))))
+	return rec
+}
+
+// savedSecretPreset stores a preset with notifier credentials and returns
+// its manager.
+func savedSecretPreset(t *testing.T) *config.PresetManager {
+	t.Helper()
+	pm, err := config.NewPresetManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Notify = secretNotify()
+	if err := pm.SavePreset(config.ConfigToPreset(cfg, "secret", "")); err != nil {
+		t.Fatal(err)
+	}
+	return pm
+}
+
+func assertStoredCredentials(t *testing.T, pm *config.PresetManager) {
+	t.Helper()
+	preset, err := pm.LoadPreset("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := preset.Notify
+	if n == nil || len(n.Webhooks) != 1 || n.Webhooks[0].Secret != "webhook-secret" || n.Email == nil || n.Email.Password != "smtp-password" {
+		t.Errorf("stored notifier credentials lost: %+v", n)
+	}
+}
+
+func TestHandleSavePreset_KeepsNotifyCredentials(t *testing.T) {
+	s := newRunServer(t)
+	pm := savedSecretPreset(t)
+
+	// The web UI sends no notifier settings
+	rec := post(t, s, "/api/presets", map[string]any{
+		"name":   "secret",
+		"config": map[string]any{"source": "/card", "dest": "/nas"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	assertStoredCredentials(t, pm)
+
+	// Saving a loaded preset sends its redacted notifier settings back
+	loaded := get(s, "/api/presets/load?name=secret")
+	var cfg map[string]any
+	if err := json.NewDecoder(loaded.Body).Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	rec = post(t, s, "/api/presets", map[string]any{"name": "secret", "config": cfg})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	assertStoredCredentials(t, pm)
+
+	rec = post(t, s, "/api/presets", map[string]any{
+		"name": "secret",
+		"config": map[string]any{"notify": map[string]any{
+			"webhooks": []map[string]string{{"url": "https://example.com/hook", "secret": "replaced"}},
+		}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("credentials from the web: status = %d, want 400", rec.Code)
+	}
+	assertStoredCredentials(t, pm)
+}
+
+func TestHandleRun_UsesPresetNotify(t *testing.T) {
+	s := newRunServer(t)
+	signature := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		signature <- r.Header.Get(notify.SignatureHeader)
+	}))
+	defer hook.Close()
+
+	pm, err := config.NewPresetManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Notify = &types.NotifyConfig{Webhooks: []types.WebhookNotify{{URL: hook.URL, Secret: "webhook-secret"}}}
+	if err := pm.SavePreset(config.ConfigToPreset(cfg, "hooked", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	rec := postRun(t, s, map[string]any{
+		"source": src,
+		"dest":   filepath.Join(dir, "dest"),
+		"preset": "hooked",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	select {
+	case sig := <-signature:
+		if sig == "" {
+			t.Error("webhook was not signed with the preset's secret")
+		}
+	default:
+		t.Error("run did not notify the preset's webhook")
+	}
+}
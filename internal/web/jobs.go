@@ -0,0 +1,560 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/notify"
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/internal/schedule"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+	"github.com/gorilla/mux"
+)
+
+// JobStatus is the lifecycle state of a job.
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+	// JobStatusScheduled marks a recurring job that enqueues runs.
+	JobStatusScheduled JobStatus = "scheduled"
+)
+
+// Job is a queued or scheduled pipeline run. Either Preset or Config is set.
+type Job struct {
+	ID     string         `json:"id"`
+	Name   string         `json:"name,omitempty"`
+	Preset string         `json:"preset,omitempty"`
+	Config *config.Config `json:"config,omitempty"`
+	// Schedule is a cron expression; scheduled jobs enqueue a run each time
+	// it fires and are never run directly.
+	Schedule string `json:"schedule,omitempty"`
+	// ParentID links a run to the scheduled job that created it.
+	ParentID   string            `json:"parent_id,omitempty"`
+	MaxRetries int               `json:"max_retries"`
+	Attempts   int               `json:"attempts"`
+	Status     JobStatus         `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Summary    *types.RunSummary `json:"summary,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  time.Time         `json:"started_at,omitempty"`
+	FinishedAt time.Time         `json:"finished_at,omitempty"`
+	// NextRun is the next schedule trigger or the earliest retry time.
+	NextRun time.Time `json:"next_run,omitempty"`
+}
+
+// redacted returns a copy of j for API responses, without notifier
+// credentials.
+func (j Job) redacted() Job {
+	if j.Config != nil {
+		j.Config = redactedConfig(j.Config)
+	}
+	return j
+}
+
+// retryDelay is multiplied by the attempt number between retries.
+const retryDelay = time.Minute
+
+// errJobsNotSaved is returned when the job queue could not be written.
+var errJobsNotSaved = errors.New("failed to save jobs")
+
+// DefaultJobsFile returns where the web server keeps its job queue.
+func DefaultJobsFile() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".shutterpipe", "jobs.json")
+}
+
+// JobManager keeps a persistent job queue and runs jobs one at a time.
+type JobManager struct {
+	server   *Server
+	filePath string
+	// exec runs a job; tests replace it.
+	exec func(job *Job) (*types.RunSummary, error)
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running *pipeline.Pipeline
+	wake    chan struct{}
+}
+
+// NewJobManager loads the jobs saved at path. Jobs that were running when
+// the server stopped are queued again.
+func NewJobManager(s *Server, path string) (*JobManager, error) {
+	m := &JobManager{
+		server:   s,
+		filePath: path,
+		jobs:     make(map[string]*Job),
+		wake:     make(chan struct{}, 1),
+	}
+	m.exec = m.execute
+
+	data, err := os.ReadFile(m.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read jobs file: %w", err)
+	}
+	if err == nil {
+		var jobs []*Job
+		if err := json.Unmarshal(data, &jobs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal jobs: %w", err)
+		}
+		for _, j := range jobs {
+			if j.Status == JobStatusRunning {
+				j.Status = JobStatusQueued
+			}
+			m.jobs[j.ID] = j
+		}
+	}
+
+	return m, nil
+}
+
+// Start runs the worker and scheduler loops.
+func (m *JobManager) Start() {
+	go m.worker()
+	go m.scheduler()
+}
+
+// Create validates and stores a new job.
+func (m *JobManager) Create(job *Job) error {
+	if job.Preset == "" && job.Config == nil {
+		return fmt.Errorf("preset or config is required")
+	}
+	if job.Config != nil {
+		if err := pipeline.Validate(job.Config); err != nil {
+			return err
+		}
+		if err := m.server.allowConfig(job.Config); err != nil {
+			return err
+		}
+	}
+	if job.MaxRetries < 0 {
+		job.MaxRetries = 0
+	}
+
+	job.ID = newJobID()
+	job.CreatedAt = time.Now()
+	job.Attempts = 0
+	job.Summary = nil
+	job.Error = ""
+
+	if job.Schedule != "" {
+		sched, err := schedule.Parse(job.Schedule)
+		if err != nil {
+			return err
+		}
+		job.Status = JobStatusScheduled
+		job.NextRun = sched.Next(time.Now())
+	} else {
+		job.Status = JobStatusQueued
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	if err := m.saveLocked(); err != nil {
+		delete(m.jobs, job.ID)
+		m.mu.Unlock()
+		return err
+	}
+	m.mu.Unlock()
+
+	m.notify()
+	return nil
+}
+
+// Get returns a copy of a job.
+func (m *JobManager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// List returns all jobs, newest first.
+func (m *JobManager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].CreatedAt.After(jobs[b].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel stops a queued, running or scheduled job. A job that is starting
+// is cancelled as soon as its pipeline exists. The cancel takes effect even
+// if saving the queue fails.
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+
+	switch j.Status {
+	case JobStatusQueued, JobStatusScheduled:
+		j.Status = JobStatusCancelled
+		j.FinishedAt = time.Now()
+	case JobStatusRunning:
+		// Without a pipeline yet, started applies the cancel
+		j.Status = JobStatusCancelled
+		if m.running != nil {
+			m.running.Cancel()
+		}
+	default:
+		return fmt.Errorf("job %s is already %s", id, j.Status)
+	}
+
+	return m.saveLocked()
+}
+
+// QueueDepth returns the number of jobs waiting to run.
+func (m *JobManager) QueueDepth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, j := range m.jobs {
+		if j.Status == JobStatusQueued {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *JobManager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *JobManager) worker() {
+	for {
+		job := m.nextQueued()
+		if job == nil {
+			select {
+			case <-m.wake:
+			case <-time.After(30 * time.Second):
+			}
+			continue
+		}
+		m.runJob(job)
+	}
+}
+
+// nextQueued returns the oldest queued job that is due.
+func (m *JobManager) nextQueued() *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var next *Job
+	for _, j := range m.jobs {
+		if j.Status != JobStatusQueued || j.NextRun.After(now) {
+			continue
+		}
+		if next == nil || j.CreatedAt.Before(next.CreatedAt) {
+			next = j
+		}
+	}
+	return next
+}
+
+func (m *JobManager) runJob(job *Job) {
+	// Wait for any manual run to finish
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	m.mu.Lock()
+	if job.Status != JobStatusQueued {
+		m.mu.Unlock()
+		return
+	}
+	job.Status = JobStatusRunning
+	job.Attempts++
+	job.StartedAt = time.Now()
+	job.Error = ""
+	m.saveInBackground()
+	m.mu.Unlock()
+
+	summary, err := m.exec(job)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.running = nil
+	job.Summary = summary
+	job.FinishedAt = time.Now()
+
+	if job.Status == JobStatusCancelled {
+		m.saveInBackground()
+		return
+	}
+
+	if err == nil && summary != nil && summary.Failed > 0 {
+		err = fmt.Errorf("%d files failed", summary.Failed)
+	}
+
+	switch {
+	case err == nil:
+		job.Status = JobStatusCompleted
+	case job.Attempts <= job.MaxRetries:
+		job.Status = JobStatusQueued
+		job.Error = err.Error()
+		job.NextRun = time.Now().Add(time.Duration(job.Attempts) * retryDelay)
+	default:
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+	}
+	m.saveInBackground()
+}
+
+func (m *JobManager) execute(job *Job) (summary *types.RunSummary, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	cfg, err := m.jobConfig(job)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.server.executeRun(job.Preset, cfg, func(p *pipeline.Pipeline) {
+		m.started(job, p)
+	})
+}
+
+// started records the pipeline of the running job. A cancel that arrived
+// before the pipeline existed is applied now.
+func (m *JobManager) started(job *Job, p *pipeline.Pipeline) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.running = p
+	if job.Status == JobStatusCancelled {
+		p.Cancel()
+	}
+}
+
+func (m *JobManager) jobConfig(job *Job) (*config.Config, error) {
+	if job.Config != nil {
+		cfg := *job.Config
+		return &cfg, pipeline.Validate(&cfg)
+	}
+
+	pm, err := config.NewPresetManager()
+	if err != nil {
+		return nil, err
+	}
+	preset, err := pm.LoadPreset(job.Preset)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.PresetToConfig(preset)
+	return cfg, pipeline.Validate(cfg)
+}
+
+// scheduler enqueues a run for each scheduled job whose time has come.
+func (m *JobManager) scheduler() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if m.fireSchedules(time.Now()) {
+			m.notify()
+		}
+	}
+}
+
+func (m *JobManager) fireSchedules(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fired := false
+	for _, j := range m.jobs {
+		if j.Status != JobStatusScheduled || j.NextRun.IsZero() || j.NextRun.After(now) {
+			continue
+		}
+
+		run := &Job{
+			ID:         newJobID(),
+			Name:       j.Name,
+			Preset:     j.Preset,
+			Config:     j.Config,
+			ParentID:   j.ID,
+			MaxRetries: j.MaxRetries,
+			Status:     JobStatusQueued,
+			CreatedAt:  now,
+		}
+		m.jobs[run.ID] = run
+		fired = true
+
+		if sched, err := schedule.Parse(j.Schedule); err == nil {
+			j.NextRun = sched.Next(now)
+		} else {
+			j.NextRun = time.Time{}
+		}
+	}
+
+	if fired {
+		m.saveInBackground()
+	}
+	return fired
+}
+
+// saveInBackground saves the queue from the worker and scheduler, which have
+// no caller to return the error to. The jobs stay queued in memory.
+func (m *JobManager) saveInBackground() {
+	if err := m.saveLocked(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+func (m *JobManager) saveLocked() error {
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errJobsNotSaved, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.filePath), 0755); err != nil {
+		return fmt.Errorf("%w: %v", errJobsNotSaved, err)
+	}
+
+	// Atomic write. Job configs may hold notifier credentials.
+	tmpFile := m.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("%w: %v", errJobsNotSaved, err)
+	}
+	if err := os.Rename(tmpFile, m.filePath); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("%w: %v", errJobsNotSaved, err)
+	}
+	return nil
+}
+
+func newJobID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Job handlers
+
+// requireJobs reports whether the job queue is available.
+func (s *Server) requireJobs(w http.ResponseWriter) bool {
+	if s.jobs == nil {
+		http.Error(w, "job queue is not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	if !s.requireJobs(w) {
+		return
+	}
+
+	jobs := s.jobs.List()
+	for i := range jobs {
+		jobs[i] = jobs[i].redacted()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	if !s.requireJobs(w) {
+		return
+	}
+
+	var job Job
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if job.Config != nil {
+		clearServerFiles(job.Config)
+		if notify.HasCredentials(job.Config.Notify) {
+			http.Error(w, errNotifyCredentials.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.jobs.Create(&job); err != nil {
+		if errors.Is(err, errJobsNotSaved) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pathError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job.redacted())
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	if !s.requireJobs(w) {
+		return
+	}
+
+	job, ok := s.jobs.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.redacted())
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	if !s.requireJobs(w) {
+		return
+	}
+
+	if err := s.jobs.Cancel(mux.Vars(r)["id"]); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, errJobsNotSaved) {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+	"github.com/On-Jun9/ShutterPipe/internal/copier"
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func newTestJobManager(t *testing.T) (*JobManager, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	m, err := NewJobManager(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, path
+}
+
+func TestJobManager_QueueOrder(t *testing.T) {
+	m, _ := newTestJobManager(t)
+
+	var ran []string
+	m.exec = func(job *Job) (*types.RunSummary, error) {
+		ran = append(ran, job.Preset)
+		return &types.RunSummary{}, nil
+	}
+
+	for _, preset := range []string{"first", "second"} {
+		if err := m.Create(&Job{Preset: preset}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := m.Create(&Job{Preset: "nightly", Schedule: "0 3 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.QueueDepth(); n != 2 {
+		t.Errorf("queue depth = %d, want 2", n)
+	}
+
+	for job := m.nextQueued(); job != nil; job = m.nextQueued() {
+		m.runJob(job)
+	}
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "second" {
+		t.Errorf("ran %v, want [first second]", ran)
+	}
+	for _, job := range m.List() {
+		want := JobStatusCompleted
+		if job.Schedule != "" {
+			want = JobStatusScheduled
+		}
+		if job.Status != want {
+			t.Errorf("%s: status %s, want %s", job.Preset, job.Status, want)
+		}
+	}
+}
+
+func TestJobManager_Retry(t *testing.T) {
+	m, _ := newTestJobManager(t)
+	m.exec = func(job *Job) (*types.RunSummary, error) {
+		return nil, errors.New("card removed")
+	}
+
+	job := &Job{Preset: "sony", MaxRetries: 1}
+	if err := m.Create(job); err != nil {
+		t.Fatal(err)
+	}
+
+	m.runJob(m.nextQueued())
+	got, _ := m.Get(job.ID)
+	if got.Status != JobStatusQueued || got.Attempts != 1 || got.Error != "card removed" {
+		t.Fatalf("after first attempt: %+v", got)
+	}
+	if wait := time.Until(got.NextRun); wait < retryDelay-time.Second || wait > retryDelay {
+		t.Errorf("retry in %v, want %v", wait, retryDelay)
+	}
+	// The retry is not due yet
+	if next := m.nextQueued(); next != nil {
+		t.Fatalf("retry ran early: %+v", next)
+	}
+
+	m.mu.Lock()
+	job.NextRun = time.Now().Add(-time.Second)
+	m.mu.Unlock()
+	m.runJob(m.nextQueued())
+
+	got, _ = m.Get(job.ID)
+	if got.Status != JobStatusFailed || got.Attempts != 2 {
+		t.Errorf("after last attempt: %+v", got)
+	}
+}
+
+func TestJobManager_Reload(t *testing.T) {
+	m, path := newTestJobManager(t)
+
+	queued := &Job{Preset: "queued"}
+	running := &Job{Preset: "running"}
+	for _, job := range []*Job{queued, running} {
+		if err := m.Create(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.mu.Lock()
+	running.Status = JobStatusRunning
+	m.saveLocked()
+	m.mu.Unlock()
+
+	reloaded, err := NewJobManager(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{queued.ID, running.ID} {
+		job, ok := reloaded.Get(id)
+		if !ok {
+			t.Fatalf("job %s was not reloaded", id)
+		}
+		// A run interrupted by a restart is queued again
+		if job.Status != JobStatusQueued {
+			t.Errorf("%s: status %s, want queued", job.Preset, job.Status)
+		}
+	}
+}
+
+func TestJobManager_SaveError(t *testing.T) {
+	m, path := newTestJobManager(t)
+	// The jobs file can't be replaced by a directory
+	os.MkdirAll(filepath.Join(path, "busy"), 0755)
+
+	if err := m.Create(&Job{Preset: "sony"}); !errors.Is(err, errJobsNotSaved) {
+		t.Errorf("create: %v", err)
+	}
+	if n := len(m.List()); n != 0 {
+		t.Errorf("unsaved job kept: %d jobs", n)
+	}
+}
+
+func TestJobManager_CancelBeforeStart(t *testing.T) {
+	m, _ := newTestJobManager(t)
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Source = filepath.Join(dir, "card")
+	cfg.Dest = filepath.Join(dir, "nas")
+	cfg.StateFile = filepath.Join(dir, "state.json")
+	cfg.LogFile = filepath.Join(dir, "shutterpipe.log")
+	cfg.HistoryDir = filepath.Join(dir, "runs")
+	os.MkdirAll(cfg.Source, 0755)
+
+	var runErr error
+	m.exec = func(job *Job) (*types.RunSummary, error) {
+		// The cancel arrives before the pipeline is created
+		if err := m.Cancel(job.ID); err != nil {
+			t.Fatal(err)
+		}
+		p, err := pipeline.New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		m.started(job, p)
+		_, runErr = p.Run()
+		return nil, runErr
+	}
+
+	job := &Job{Preset: "sony"}
+	if err := m.Create(job); err != nil {
+		t.Fatal(err)
+	}
+	m.runJob(m.nextQueued())
+
+	if !errors.Is(runErr, copier.ErrCancelled) {
+		t.Errorf("pipeline ran despite the cancel: %v", runErr)
+	}
+	if got, _ := m.Get(job.ID); got.Status != JobStatusCancelled {
+		t.Errorf("status %s, want cancelled", got.Status)
+	}
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+)
+
+// PathRoots limits the paths the web UI may browse, copy from and copy to.
+// Sources must lie below a source root and destinations below a dest root;
+// browsing is allowed below either. Without roots every path is allowed.
+type PathRoots struct {
+	Source []string
+	Dest   []string
+}
+
+// Root kinds, also reported on browse shortcuts.
+const (
+	rootSource = "source"
+	rootDest   = "dest"
+)
+
+var errPathNotAllowed = errors.New("path is outside the allowed roots")
+
+// SetRoots restricts paths to the given roots. Roots of both kinds are
+// required once any is set. They are resolved through symlinks once, so
+// later checks compare real paths. Call it before Start.
+func (s *Server) SetRoots(roots PathRoots) error {
+	if len(roots.Source) == 0 && len(roots.Dest) == 0 {
+		s.roots = nil
+		return nil
+	}
+	if len(roots.Source) == 0 || len(roots.Dest) == 0 {
+		return fmt.Errorf("both source and dest roots are required")
+	}
+
+	resolved := &PathRoots{}
+	for _, list := range []struct {
+		in  []string
+		out *[]string
+	}{{roots.Source, &resolved.Source}, {roots.Dest, &resolved.Dest}} {
+		for _, root := range list.in {
+			if root == "" {
+				return fmt.Errorf("empty root path")
+			}
+			real, err := resolvePath(root)
+			if err != nil {
+				return fmt.Errorf("root %s: %w", root, err)
+			}
+			*list.out = append(*list.out, real)
+		}
+	}
+	s.roots = resolved
+	return nil
+}
+
+// resolvePath makes path absolute, removes ".." and resolves symlinks. A
+// missing tail, like a destination folder a run will create, is appended
+// to the resolved part that exists.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing := abs
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
+
+// within reports whether path is root or below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// allowPath returns the resolved path if it lies below a root of the given
+// kind, or of any kind when kind is empty. Callers must use the returned
+// path so symlinks can't point them elsewhere after the check. Empty paths
+// and servers without roots pass unchanged.
+func (s *Server) allowPath(kind, path string) (string, error) {
+	if s.roots == nil || path == "" {
+		return path, nil
+	}
+
+	var roots []string
+	if kind != rootDest {
+		roots = append(roots, s.roots.Source...)
+	}
+	if kind != rootSource {
+		roots = append(roots, s.roots.Dest...)
+	}
+
+	real, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range roots {
+		if within(root, real) {
+			return real, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", errPathNotAllowed, path)
+}
+
+// allowConfig checks the source and destination of cfg and replaces them
+// with their resolved paths. The folders a run creates inside the
+// destination must stay inside it.
+func (s *Server) allowConfig(cfg *config.Config) error {
+	source, err := s.allowPath(rootSource, cfg.Source)
+	if err != nil {
+		return err
+	}
+	dest, err := s.allowPath(rootDest, cfg.Dest)
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{cfg.QuarantineDir, cfg.ReviewDir, cfg.ReportDir, cfg.UnclassifiedDir} {
+		if err := s.allowSubdir(dest, dir); err != nil {
+			return err
+		}
+	}
+	cfg.Source, cfg.Dest = source, dest
+	return nil
+}
+
+// allowSubdir checks that dir, relative to the resolved dest, stays inside
+// dest. With roots it is resolved through symlinks first.
+func (s *Server) allowSubdir(dest, dir string) error {
+	path := filepath.Join(dest, dir)
+	if s.roots != nil {
+		real, err := resolvePath(path)
+		if err != nil {
+			return err
+		}
+		path = real
+	}
+	if !within(dest, path) {
+		return fmt.Errorf("%w: %s", errPathNotAllowed, dir)
+	}
+	return nil
+}
+
+// clearServerFiles drops the state and log file from a config sent to the
+// web API, so runs use the server's defaults instead of writing wherever
+// the request points.
+func clearServerFiles(cfg *config.Config) {
+	cfg.StateFile = ""
+	cfg.LogFile = ""
+}
+
+// pathError writes err with 403 for disallowed paths and 400 otherwise.
+func pathError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errPathNotAllowed) {
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// rootEntries lists the roots as browse shortcuts.
+func (s *Server) rootEntries() []DirEntry {
+	var entries []DirEntry
+	for _, root := range s.roots.Source {
+		entries = append(entries, DirEntry{Name: root, Path: root, IsDir: true, Root: rootSource})
+	}
+	for _, root := range s.roots.Dest {
+		entries = append(entries, DirEntry{Name: root, Path: root, IsDir: true, Root: rootDest})
+	}
+	return entries
+}
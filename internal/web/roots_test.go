@@ -0,0 +1,160 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/internal/config"
+)
+
+// newRootsServer creates card and nas roots plus an outside directory with
+// a symlink from the card root pointing to it.
+func newRootsServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, d := range []string{"card/DCIM", "nas/photos", "outside"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(dir, "card", "escape")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	s := &Server{}
+	if err := s.SetRoots(PathRoots{
+		Source: []string{filepath.Join(dir, "card")},
+		Dest:   []string{filepath.Join(dir, "nas")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func TestAllowPath(t *testing.T) {
+	s, dir := newRootsServer(t)
+
+	tests := []struct {
+		kind, path string
+		ok         bool
+	}{
+		{rootSource, filepath.Join(dir, "card"), true},
+		{rootSource, filepath.Join(dir, "card", "DCIM"), true},
+		{rootSource, filepath.Join(dir, "nas", "photos"), false},
+		{rootDest, filepath.Join(dir, "nas", "photos", "2024", "new"), true},
+		{rootDest, filepath.Join(dir, "nas", "..", "outside"), false},
+		{rootSource, filepath.Join(dir, "card", "..", "card", "DCIM"), true},
+		{rootSource, filepath.Join(dir, "card", "escape"), false},
+		{rootSource, filepath.Join(dir, "card", "escape", "missing"), false},
+		{rootSource, filepath.Join(dir, "cardx"), false},
+		{"", filepath.Join(dir, "nas"), true},
+		{"", filepath.Join(dir, "outside"), false},
+		{rootSource, "", true},
+	}
+	for _, tt := range tests {
+		_, err := s.allowPath(tt.kind, tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("allowPath(%q, %q) error = %v, want ok %v", tt.kind, tt.path, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, errPathNotAllowed) {
+			t.Errorf("allowPath(%q, %q) error = %v, want errPathNotAllowed", tt.kind, tt.path, err)
+		}
+	}
+}
+
+func TestAllowConfigResolvesPaths(t *testing.T) {
+	s, dir := newRootsServer(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Source = filepath.Join(dir, "card", "DCIM", "..")
+	cfg.Dest = filepath.Join(dir, "nas", "photos")
+	if err := s.allowConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	realCard, _ := filepath.EvalSymlinks(filepath.Join(dir, "card"))
+	if cfg.Source != realCard {
+		t.Errorf("Source = %q, want %q", cfg.Source, realCard)
+	}
+
+	cfg.Dest = filepath.Join(dir, "card")
+	if err := s.allowConfig(cfg); !errors.Is(err, errPathNotAllowed) {
+		t.Errorf("dest inside source root: error = %v, want errPathNotAllowed", err)
+	}
+}
+
+func TestAllowConfigSubdirs(t *testing.T) {
+	s, dir := newRootsServer(t)
+	dest := filepath.Join(dir, "nas", "photos")
+	if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(dest, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		set  func(cfg *config.Config)
+		ok   bool
+	}{
+		{"defaults", func(cfg *config.Config) {}, true},
+		{"nested", func(cfg *config.Config) { cfg.ReviewDir = "review/similar" }, true},
+		{"quarantine parent", func(cfg *config.Config) { cfg.QuarantineDir = "../../outside" }, false},
+		{"review parent", func(cfg *config.Config) { cfg.ReviewDir = ".." }, false},
+		{"report symlink", func(cfg *config.Config) { cfg.ReportDir = "link/reports" }, false},
+		{"unclassified parent", func(cfg *config.Config) { cfg.UnclassifiedDir = "../unclassified" }, false},
+	}
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.Source = filepath.Join(dir, "card")
+		cfg.Dest = dest
+		tt.set(cfg)
+		err := s.allowConfig(cfg)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, errPathNotAllowed) {
+			t.Errorf("%s: error = %v, want errPathNotAllowed", tt.name, err)
+		}
+	}
+}
+
+func TestSetRootsRequiresBothKinds(t *testing.T) {
+	s := &Server{}
+	if err := s.SetRoots(PathRoots{Source: []string{t.TempDir()}}); err == nil {
+		t.Error("expected an error without dest roots")
+	}
+	if err := s.SetRoots(PathRoots{}); err != nil || s.roots != nil {
+		t.Errorf("no roots: error = %v, roots = %v", err, s.roots)
+	}
+}
+
+func TestBrowseRoots(t *testing.T) {
+	s, dir := newRootsServer(t)
+
+	rec := httptest.NewRecorder()
+	s.handleBrowse(rec, httptest.NewRequest("GET", "/api/browse", nil))
+	var resp BrowseResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 2 || resp.Entries[0].Root != rootSource || resp.Entries[1].Root != rootDest {
+		t.Errorf("shortcuts = %+v, want source and dest roots", resp.Entries)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBrowse(rec, httptest.NewRequest("GET", "/api/browse?path="+filepath.Join(dir, "outside"), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("browse outside roots: status %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBrowse(rec, httptest.NewRequest("GET", "/api/browse?path="+filepath.Join(dir, "card"), nil))
+	resp = BrowseResponse{}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if rec.Code != http.StatusOK || len(resp.Entries) != 2 {
+		t.Errorf("browse card root: status %d, entries %+v", rec.Code, resp.Entries)
+	}
+}
@@ -0,0 +1,168 @@
+package web
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+// Run phases reported in RunState.
+const (
+	PhaseIdle      = pipeline.PhaseIdle
+	PhaseStarting  = pipeline.PhaseStarting
+	PhaseAnalyzing = pipeline.PhaseAnalyzing
+	PhaseCopying   = pipeline.PhaseCopying
+	PhaseComplete  = pipeline.PhaseComplete
+	PhaseFailed    = pipeline.PhaseFailed
+)
+
+// maxRecentEvents is how many status and file events RunState keeps.
+const maxRecentEvents = 200
+
+// RunState is the server's record of the current or last run, so clients
+// that connect late can catch up.
+type RunState struct {
+	Running bool   `json:"running"`
+	Phase   string `json:"phase"`
+	RunID   string `json:"run_id,omitempty"`
+	// Message is the latest status message.
+	Message string                   `json:"message,omitempty"`
+	Current int                      `json:"current"`
+	Total   int                      `json:"total"`
+	Counts  map[types.CopyAction]int `json:"counts"`
+	// Throughput, Limit and PerWorker are from the latest bandwidth report.
+	Throughput float64 `json:"throughput"`
+	Limit      int64   `json:"limit"`
+	PerWorker  int64   `json:"per_worker"`
+	// Transfer is the latest byte-level progress while copying.
+	Transfer   *types.TransferProgress `json:"transfer,omitempty"`
+	Summary    *types.RunSummary       `json:"summary,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	StartedAt  time.Time               `json:"started_at,omitempty"`
+	FinishedAt time.Time               `json:"finished_at,omitempty"`
+	// Events are the latest status messages and finished files, oldest
+	// first, for rebuilding the log and file list.
+	Events []pipeline.ProgressUpdate `json:"events"`
+	// Seq is the number of the last event applied. Broadcast events carry
+	// their number, so clients can drop those already in a snapshot.
+	Seq uint64 `json:"seq"`
+}
+
+// runTracker folds pipeline events into a RunState.
+type runTracker struct {
+	mu    sync.Mutex
+	state RunState
+}
+
+func newRunTracker() *runTracker {
+	return &runTracker{state: RunState{Phase: PhaseIdle, Counts: map[types.CopyAction]int{}}}
+}
+
+// apply records update and returns its sequence number.
+func (t *runTracker) apply(update pipeline.ProgressUpdate) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := &t.state
+	st.Seq++
+	if phase := pipeline.Phase(update.Type); phase != "" {
+		st.Phase = phase
+	}
+
+	switch update.Type {
+	case pipeline.EventRunStarted:
+		*st = RunState{
+			Running:   true,
+			Phase:     PhaseStarting,
+			RunID:     update.RunID,
+			Counts:    map[types.CopyAction]int{},
+			StartedAt: time.Now(),
+			Seq:       st.Seq,
+		}
+		return st.Seq
+	case pipeline.EventStatus:
+		st.Message = update.Message
+		t.record(update)
+	case pipeline.EventAnalysisProgress:
+		st.Message = update.Message
+		st.Current, st.Total = update.Current, update.Total
+	case pipeline.EventFileSkipped:
+		st.Counts[update.Action]++
+	case pipeline.EventProgress:
+		st.Current, st.Total = update.Current, update.Total
+		st.Counts[update.Action]++
+		st.Throughput, st.Limit, st.PerWorker = update.Throughput, update.Limit, update.PerWorker
+		t.record(update)
+	case pipeline.EventTransfer:
+		st.Current, st.Total = update.Current, update.Total
+		st.Throughput, st.Limit, st.PerWorker = update.Throughput, update.Limit, update.PerWorker
+		st.Transfer = update.Transfer
+	case pipeline.EventBandwidth:
+		st.Throughput, st.Limit, st.PerWorker = update.Throughput, update.Limit, update.PerWorker
+	case pipeline.EventComplete:
+		st.Running = false
+		st.Summary = update.Summary
+		st.Transfer = nil
+		st.FinishedAt = time.Now()
+	case pipeline.EventError:
+		st.Running = false
+		st.Error = update.Error
+		st.Transfer = nil
+		st.FinishedAt = time.Now()
+		t.record(update)
+	}
+	return st.Seq
+}
+
+func (t *runTracker) record(update pipeline.ProgressUpdate) {
+	update.Task = nil
+	events := append(t.state.Events, update)
+	if len(events) > maxRecentEvents {
+		events = events[len(events)-maxRecentEvents:]
+	}
+	t.state.Events = events
+}
+
+// snapshot returns a copy that is safe to encode while the run goes on.
+func (t *runTracker) snapshot() RunState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.state
+	st.Counts = maps.Clone(st.Counts)
+	st.Events = append([]pipeline.ProgressUpdate(nil), st.Events...)
+	if st.Events == nil {
+		st.Events = []pipeline.ProgressUpdate{}
+	}
+	return st
+}
+
+// progressMessage is a broadcast event with its sequence number.
+type progressMessage struct {
+	pipeline.ProgressUpdate
+	Seq uint64 `json:"seq"`
+}
+
+// snapshotMessage is the first message of every WebSocket connection.
+type snapshotMessage struct {
+	Type  string   `json:"type"`
+	State RunState `json:"state"`
+}
+
+// snapshotJSON encodes the current state for a new WebSocket client.
+func (s *Server) snapshotJSON() []byte {
+	data, err := json.Marshal(snapshotMessage{Type: "snapshot", State: s.run.snapshot()})
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func (s *Server) handleRunStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.run.snapshot())
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/pkg/types"
+)
+
+func TestRunTracker(t *testing.T) {
+	tr := newRunTracker()
+	if st := tr.snapshot(); st.Running || st.Phase != PhaseIdle || st.Events == nil {
+		t.Fatalf("initial state = %+v", st)
+	}
+
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventRunStarted, RunID: "r1"})
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventStatus, Message: "scanning"})
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventFileSkipped, Action: types.CopyActionSkipped})
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventProgress, Current: 1, Total: 2, Filename: "a.jpg", Action: types.CopyActionCopied})
+	seq := tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventBandwidth, Throughput: 1024, Limit: 2048})
+
+	st := tr.snapshot()
+	if !st.Running || st.Phase != PhaseCopying || st.RunID != "r1" {
+		t.Errorf("running state = %+v", st)
+	}
+	if st.Current != 1 || st.Total != 2 || st.Message != "scanning" {
+		t.Errorf("progress = %d/%d %q", st.Current, st.Total, st.Message)
+	}
+	if st.Counts[types.CopyActionCopied] != 1 || st.Counts[types.CopyActionSkipped] != 1 {
+		t.Errorf("counts = %v", st.Counts)
+	}
+	if st.Throughput != 1024 || st.Limit != 2048 {
+		t.Errorf("bandwidth = %v/%v", st.Throughput, st.Limit)
+	}
+	if len(st.Events) != 2 || st.Events[1].Filename != "a.jpg" {
+		t.Errorf("events = %+v", st.Events)
+	}
+	if st.Seq != seq || seq != 5 {
+		t.Errorf("seq = %d, last apply returned %d", st.Seq, seq)
+	}
+
+	// Snapshots must not share maps or slices with the live state
+	st.Counts[types.CopyActionCopied] = 99
+	st.Events[0].Message = "changed"
+	if again := tr.snapshot(); again.Counts[types.CopyActionCopied] != 1 || again.Events[0].Message != "scanning" {
+		t.Error("snapshot shares state with the tracker")
+	}
+
+	summary := &types.RunSummary{TotalFiles: 2}
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventComplete, Summary: summary})
+	if st := tr.snapshot(); st.Running || st.Phase != PhaseComplete || st.Summary != summary || st.FinishedAt.IsZero() {
+		t.Errorf("complete state = %+v", st)
+	}
+
+	// A new run starts from a clean state but keeps counting events
+	seq = tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventRunStarted, RunID: "r2"})
+	if st := tr.snapshot(); st.Summary != nil || len(st.Events) != 0 || st.Seq != seq || seq != 7 {
+		t.Errorf("restarted state = %+v", st)
+	}
+}
+
+func TestRunTracker_Transfer(t *testing.T) {
+	tr := newRunTracker()
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventRunStarted, RunID: "r1"})
+	tr.apply(pipeline.ProgressUpdate{
+		Type:       pipeline.EventTransfer,
+		Current:    1,
+		Total:      3,
+		Throughput: 4096,
+		Transfer: &types.TransferProgress{
+			BytesDone:  100,
+			BytesTotal: 400,
+			ETA:        time.Second,
+			Files:      []types.FileProgress{{Worker: 1, Filename: "b.mp4", Size: 200, Done: 50}},
+		},
+	})
+
+	st := tr.snapshot()
+	if st.Phase != PhaseCopying || st.Current != 1 || st.Total != 3 || st.Throughput != 4096 {
+		t.Errorf("state = %+v", st)
+	}
+	if st.Transfer == nil || st.Transfer.BytesDone != 100 || len(st.Transfer.Files) != 1 {
+		t.Fatalf("transfer = %+v", st.Transfer)
+	}
+	// Transfers are sampled often, so they don't fill the event log
+	if len(st.Events) != 0 {
+		t.Errorf("events = %+v", st.Events)
+	}
+
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventComplete, Summary: &types.RunSummary{}})
+	if st := tr.snapshot(); st.Transfer != nil {
+		t.Errorf("transfer after completion = %+v", st.Transfer)
+	}
+}
+
+func TestRunTrackerKeepsRecentEvents(t *testing.T) {
+	tr := newRunTracker()
+	tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventRunStarted})
+	for i := 0; i < maxRecentEvents+10; i++ {
+		tr.apply(pipeline.ProgressUpdate{Type: pipeline.EventProgress, Current: i + 1})
+	}
+	st := tr.snapshot()
+	if len(st.Events) != maxRecentEvents || st.Events[len(st.Events)-1].Current != maxRecentEvents+10 {
+		t.Errorf("kept %d events, last %+v", len(st.Events), st.Events[len(st.Events)-1])
+	}
+}
+
+func TestHubSendsSnapshotFirst(t *testing.T) {
+	s := &Server{hub: NewHub(), run: newRunTracker()}
+	s.hub.snapshot = s.snapshotJSON
+	go s.hub.Run()
+
+	s.broadcastProgress(pipeline.ProgressUpdate{Type: pipeline.EventRunStarted, RunID: "r1"})
+	client := &Client{hub: s.hub, send: make(chan []byte, 16)}
+	s.hub.register <- client
+	s.broadcastProgress(pipeline.ProgressUpdate{Type: pipeline.EventStatus, Message: "copying"})
+
+	var first snapshotMessage
+	if err := json.Unmarshal(receive(t, client), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Type != "snapshot" || first.State.RunID != "r1" || !first.State.Running {
+		t.Fatalf("first message = %+v", first)
+	}
+
+	// Events already in the snapshot may still arrive; clients drop them by
+	// sequence number
+	var next progressMessage
+	for next.Seq <= first.State.Seq {
+		if err := json.Unmarshal(receive(t, client), &next); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if next.Type != pipeline.EventStatus || next.Seq != first.State.Seq+1 {
+		t.Errorf("next message = %+v, snapshot seq %d", next, first.State.Seq)
+	}
+}
+
+func receive(t *testing.T, c *Client) []byte {
+	t.Helper()
+	select {
+	case data := <-c.send:
+		return data
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+		return nil
+	}
+}
+
+func TestHandleRunStatus(t *testing.T) {
+	s := &Server{run: newRunTracker()}
+	s.run.apply(pipeline.ProgressUpdate{Type: pipeline.EventError, Error: "boom"})
+
+	rec := httptest.NewRecorder()
+	s.handleRunStatus(rec, httptest.NewRequest("GET", "/api/run/status", nil))
+	var st RunState
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Phase != PhaseFailed || st.Error != "boom" {
+		t.Errorf("status = %+v", st)
+	}
+}
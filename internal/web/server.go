@@ -3,42 +3,101 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
+	"github.com/On-Jun9/ShutterPipe/internal/history"
+	"github.com/On-Jun9/ShutterPipe/internal/metrics"
+	"github.com/On-Jun9/ShutterPipe/internal/pipeline"
+	"github.com/On-Jun9/ShutterPipe/internal/thumb"
 	"github.com/gorilla/mux"
 )
 
+// metricsPath serves the Prometheus metrics. It needs a login or the API
+// token like the API when authentication is enabled.
+const metricsPath = "/metrics"
+
 type Server struct {
 	router  *mux.Router
 	hub     *Hub
+	jobs    *JobManager
+	history *history.Store
 	version string
+	// active is the pipeline of the run in progress, if any.
+	active atomic.Pointer[pipeline.Pipeline]
+	// run is the state of the current or last run. sendMu keeps
+	// broadcasts in the order they were applied to it.
+	run    *runTracker
+	sendMu sync.Mutex
+	// metrics are served at metricsPath for Prometheus.
+	metrics *metrics.Collector
+	// auth is nil when authentication is disabled.
+	auth *authenticator
+	// roots is nil when any path is allowed.
+	roots *PathRoots
+	// tls is nil when serving plain HTTP.
+	tls *TLSConfig
+	// static serves the UI, embedded unless SetStaticDir was called.
+	static http.Handler
+	thumbs *thumb.Generator
 }
 
 func NewServer() *Server {
 	s := &Server{
 		router:  mux.NewRouter(),
 		hub:     NewHub(),
+		history: history.NewStore(history.DefaultDir()),
 		version: "unknown",
+		static:  embeddedFiles(),
+		run:     newRunTracker(),
+		metrics: metrics.NewCollector(),
+		thumbs:  thumb.New(thumb.DefaultCacheDir()),
 	}
 
+	s.hub.snapshot = s.snapshotJSON
 	go s.hub.Run()
 
 	s.setupRoutes()
 	return s
 }
 
+// EnableJobs starts the job queue kept in the file at path. Without it the
+// job API answers 503.
+func (s *Server) EnableJobs(path string) error {
+	jobs, err := NewJobManager(s, path)
+	if err != nil {
+		return err
+	}
+	s.jobs = jobs
+	s.jobs.Start()
+	s.metrics.SetQueueDepth(jobs.QueueDepth)
+	return nil
+}
+
 func (s *Server) SetVersion(v string) {
 	s.version = v
 }
 
 func (s *Server) setupRoutes() {
+	s.router.Use(s.authMiddleware)
+
 	api := s.router.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/version", s.handleVersion).Methods("GET")
 	api.HandleFunc("/browse", s.handleBrowse).Methods("GET")
+	api.HandleFunc("/thumb", s.handleThumb).Methods("GET")
 	api.HandleFunc("/config", s.handleGetConfig).Methods("GET")
 	api.HandleFunc("/config", s.handleSaveConfig).Methods("POST")
 	api.HandleFunc("/run", s.handleRun).Methods("POST")
+	api.HandleFunc("/run/status", s.handleRunStatus).Methods("GET")
+	api.HandleFunc("/run/bandwidth", s.handleGetBandwidth).Methods("GET")
+	api.HandleFunc("/run/bandwidth", s.handleSetBandwidth).Methods("POST")
 	api.HandleFunc("/ws", s.handleWebSocket)
 
+	// Auth routes
+	api.HandleFunc("/auth/status", s.handleAuthStatus).Methods("GET")
+	api.HandleFunc("/auth/login", s.handleLogin).Methods("POST")
+	api.HandleFunc("/auth/logout", s.handleLogout).Methods("POST")
+
 	// Preset routes
 	api.HandleFunc("/presets", s.handleListPresets).Methods("GET")
 	api.HandleFunc("/presets", s.handleSavePreset).Methods("POST")
@@ -53,10 +112,53 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/path-history", s.handleGetPathHistory).Methods("GET")
 	api.HandleFunc("/path-history", s.handleSavePathHistory).Methods("POST")
 
-	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("web/static")))
+	// Quarantine routes
+	api.HandleFunc("/quarantine", s.handleListQuarantine).Methods("GET")
+	api.HandleFunc("/quarantine/{action}", s.handleResolveQuarantine).Methods("POST")
+
+	// Run history routes
+	api.HandleFunc("/runs", s.handleListRuns).Methods("GET")
+	api.HandleFunc("/runs/{id}", s.handleGetRun).Methods("GET")
+	api.HandleFunc("/runs/{id}/files", s.handleGetRunFiles).Methods("GET")
+	api.HandleFunc("/runs/{id}/report", s.handleGetRunReport).Methods("GET")
+
+	// Job routes
+	api.HandleFunc("/jobs", s.handleListJobs).Methods("GET")
+	api.HandleFunc("/jobs", s.handleCreateJob).Methods("POST")
+	api.HandleFunc("/jobs/{id}", s.handleGetJob).Methods("GET")
+	api.HandleFunc("/jobs/{id}/cancel", s.handleCancelJob).Methods("POST")
+
+	s.router.Handle(metricsPath, s.metrics).Methods("GET")
+
+	s.router.PathPrefix("/").HandlerFunc(s.serveStatic)
 }
 
 func (s *Server) Start(addr string) error {
-	fmt.Printf("Starting ShutterPipe Web UI at http://%s\n", addr)
-	return http.ListenAndServe(addr, s.router)
+	if s.auth == nil && !isLoopback(addr) {
+		fmt.Printf("Warning: %s is reachable from the network without a password, set -password\n", addr)
+	}
+	if s.tls == nil {
+		fmt.Printf("Starting ShutterPipe Web UI at http://%s\n", addr)
+		return http.ListenAndServe(addr, s.router)
+	}
+
+	certFile, keyFile, err := s.certFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if s.tls.SelfSigned {
+		fmt.Printf("Self-signed certificate SHA-256 fingerprint: %s\n", certFingerprint(certFile))
+	}
+
+	if s.tls.RedirectAddr != "" {
+		go func() {
+			fmt.Printf("Redirecting http://%s to HTTPS\n", s.tls.RedirectAddr)
+			if err := http.ListenAndServe(s.tls.RedirectAddr, redirectHandler(addr)); err != nil {
+				fmt.Printf("Warning: HTTP redirect stopped: %v\n", err)
+			}
+		}()
+	}
+
+	fmt.Printf("Starting ShutterPipe Web UI at https://%s\n", addr)
+	return http.ListenAndServeTLS(addr, certFile, keyFile, s.router)
 }
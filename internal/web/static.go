@@ -1 +1,112 @@
 package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/On-Jun9/ShutterPipe/web/static"
+)
+
+// staticFiles serves the embedded UI. The files never change while the
+// server runs, so their ETags are computed once.
+type staticFiles struct {
+	fsys  fs.FS
+	etags map[string]string
+}
+
+func newStaticFiles(fsys fs.FS) (*staticFiles, error) {
+	h := &staticFiles{fsys: fsys, etags: make(map[string]string)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		h.etags[name] = `"` + hex.EncodeToString(sum[:8]) + `"`
+		return nil
+	})
+	return h, err
+}
+
+func (h *staticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	etag, ok := h.etags[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "file is not seekable", http.StatusInternalServerError)
+		return
+	}
+
+	// Asset names carry no version, so browsers must revalidate after an
+	// upgrade; the ETag turns that into a 304 while nothing changed
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// devFiles serves the UI from a directory during development and keeps
+// browsers from caching it.
+func devFiles(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// SetStaticDir serves the UI from dir instead of the embedded copy, so
+// edits show up on reload. Call it before Start.
+func (s *Server) SetStaticDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.html")); err != nil {
+		return err
+	}
+	s.static = devFiles(dir)
+	return nil
+}
+
+func (s *Server) serveStatic(w http.ResponseWriter, r *http.Request) {
+	s.static.ServeHTTP(w, r)
+}
+
+// embeddedFiles returns the handler for the UI compiled into the binary.
+func embeddedFiles() http.Handler {
+	h, err := newStaticFiles(static.FS)
+	if err != nil {
+		// The embedded files are fixed at build time, so this can't happen
+		// in a binary that built
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFiles(t *testing.T) {
+	h, err := newStaticFiles(fstest.MapFS{
+		"index.html":   {Data: []byte("<html>ShutterPipe</html>")},
+		"js/core.js":   {Data: []byte("let ws = null;")},
+		"css/base.css": {Data: []byte("body {}")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ShutterPipe") {
+		t.Fatalf("GET /: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control = %q, want no-cache", cc)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/js/core.js", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET /js/core.js: status %d, ETag %q", rec.Code, etag)
+	}
+
+	req := httptest.NewRequest("GET", "/js/core.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("matching If-None-Match: status %d, want 304", rec.Code)
+	}
+
+	for _, p := range []string{"/missing.js", "/js/", "/../static.go"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", p, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", p, rec.Code)
+		}
+	}
+}
+
+func TestEmbeddedFiles(t *testing.T) {
+	h := embeddedFiles().(*staticFiles)
+	for _, name := range []string{"index.html", "js/core.js", "css/base.css"} {
+		if _, ok := h.etags[name]; !ok {
+			t.Errorf("%s is not embedded", name)
+		}
+	}
+	if _, ok := h.etags["static.go"]; ok {
+		t.Error("Go source is embedded")
+	}
+}
+
+func TestSetStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	s := &Server{static: embeddedFiles()}
+	if err := s.SetStaticDir(dir); err == nil {
+		t.Error("expected an error for a directory without index.html")
+	}
+
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("dev build"), 0644)
+	if err := s.SetStaticDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.serveStatic(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "dev build") || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("dev dir: body %q, Cache-Control %q", rec.Body.String(), rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/On-Jun9/ShutterPipe/internal/thumb"
+)
+
+// handleThumb returns a JPEG thumbnail of a photo or clip below an allowed
+// root, so cards can be reviewed before an ingest.
+func (s *Server) handleThumb(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	path, err := s.allowPath("", path)
+	if err != nil {
+		pathError(w, err)
+		return
+	}
+
+	data, key, err := s.thumbs.Thumbnail(path)
+	switch {
+	case errors.Is(err, thumb.ErrNoThumbnail), errors.Is(err, fs.ErrNotExist):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The same path may hold another file later, so browsers revalidate
+	// by content fingerprint
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/On-Jun9/ShutterPipe/internal/thumb"
+)
+
+func TestHandleThumb(t *testing.T) {
+	s, dir := newRootsServer(t)
+	s.thumbs = thumb.New(filepath.Join(dir, "cache"))
+
+	photo := filepath.Join(dir, "card", "DCIM", "DSC00001.JPG")
+	f, err := os.Create(photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 64, 48)), nil)
+	f.Close()
+	os.WriteFile(filepath.Join(dir, "card", "DCIM", "notes.txt"), []byte("hi"), 0644)
+	os.WriteFile(filepath.Join(dir, "outside", "secret.jpg"), []byte("x"), 0644)
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/thumb?path="+url.QueryEscape(path), nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		s.handleThumb(rec, req)
+		return rec
+	}
+
+	rec := get(photo, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("photo: status %d, type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+	if rec := get(photo, etag); rec.Code != http.StatusNotModified {
+		t.Errorf("revalidation: status %d, want 304", rec.Code)
+	}
+
+	tests := map[string]int{
+		"": http.StatusBadRequest,
+		filepath.Join(dir, "card", "escape", "secret.jpg"): http.StatusForbidden,
+		filepath.Join(dir, "card", "DCIM", "notes.txt"):    http.StatusNotFound,
+		filepath.Join(dir, "card", "DCIM", "missing.jpg"):  http.StatusNotFound,
+	}
+	for path, want := range tests {
+		if rec := get(path, ""); rec.Code != want {
+			t.Errorf("%q: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
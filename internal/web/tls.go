@@ -0,0 +1,239 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TLSConfig enables HTTPS. Either CertFile and KeyFile are set, or
+// SelfSigned generates a certificate that is kept in CertDir.
+type TLSConfig struct {
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool
+	// Hosts are extra names or addresses the self-signed certificate must
+	// cover. Adding one regenerates a certificate that lacks it.
+	Hosts []string
+	// CertDir holds the self-signed certificate. It defaults to
+	// DefaultCertDir.
+	CertDir string
+	// RedirectAddr, if set, serves plain HTTP there that redirects to HTTPS.
+	RedirectAddr string
+}
+
+const (
+	selfSignedValidity = 2 * 365 * 24 * time.Hour
+	// selfSignedRenewal regenerates certificates this close to expiry.
+	selfSignedRenewal = 30 * 24 * time.Hour
+)
+
+// DefaultCertDir returns where the self-signed certificate is kept.
+func DefaultCertDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".shutterpipe", "tls")
+}
+
+// SetTLS enables HTTPS. Call it before Start.
+func (s *Server) SetTLS(cfg TLSConfig) error {
+	if cfg.SelfSigned && (cfg.CertFile != "" || cfg.KeyFile != "") {
+		return fmt.Errorf("self-signed mode can't be combined with a certificate file")
+	}
+	if !cfg.SelfSigned && len(cfg.Hosts) > 0 {
+		return fmt.Errorf("extra hosts only apply to the self-signed certificate")
+	}
+	for _, host := range cfg.Hosts {
+		if host == "" {
+			return fmt.Errorf("empty certificate host")
+		}
+	}
+	if !cfg.SelfSigned && (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return fmt.Errorf("both a certificate and a key file are required")
+	}
+	if !cfg.SelfSigned && cfg.CertFile == "" {
+		if cfg.RedirectAddr != "" {
+			return fmt.Errorf("redirecting to HTTPS requires a certificate")
+		}
+		s.tls = nil
+		return nil
+	}
+	if cfg.CertDir == "" {
+		cfg.CertDir = DefaultCertDir()
+	}
+	s.tls = &cfg
+	return nil
+}
+
+// certFiles returns the certificate and key to serve, creating the
+// self-signed pair if needed.
+func (s *Server) certFiles() (string, string, error) {
+	if !s.tls.SelfSigned {
+		// Fail before listening rather than on the first handshake
+		if _, err := tls.LoadX509KeyPair(s.tls.CertFile, s.tls.KeyFile); err != nil {
+			return "", "", err
+		}
+		return s.tls.CertFile, s.tls.KeyFile, nil
+	}
+	return ensureSelfSigned(s.tls.CertDir, append(certHosts(), s.tls.Hosts...), s.tls.Hosts)
+}
+
+// certHosts lists the names and addresses browsers on the network may use
+// to reach this machine.
+func certHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+		if !strings.Contains(name, ".") {
+			hosts = append(hosts, name+".local")
+		}
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	return hosts
+}
+
+// ensureSelfSigned returns the certificate in dir, generating one for hosts
+// when there is none, it is about to expire or it doesn't cover a host in
+// required. Browsers have to trust every new certificate again, so hosts
+// that only the new certificate would add, like a changed IP address, are
+// just reported.
+func ensureSelfSigned(dir string, hosts, required []string) (string, string, error) {
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	reason := "none found"
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if cert, err := x509.ParseCertificate(pair.Certificate[0]); err == nil {
+			if reason = renewReason(cert, required); reason == "" {
+				for _, host := range hosts {
+					if cert.VerifyHostname(host) != nil {
+						fmt.Printf("Warning: self-signed certificate does not cover %s; add it with -tls-host to regenerate\n", host)
+					}
+				}
+				return certFile, keyFile, nil
+			}
+		}
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned(hosts, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return "", "", err
+	}
+	fmt.Printf("Generated self-signed certificate %s (%s)\n", certFile, reason)
+	return certFile, keyFile, nil
+}
+
+// renewReason returns why cert has to be replaced, or "" if it is valid for
+// a while yet and names every host.
+func renewReason(cert *x509.Certificate, hosts []string) string {
+	if time.Until(cert.NotAfter) < selfSignedRenewal {
+		return "expires " + cert.NotAfter.Format("2006-01-02")
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return "missing " + host
+		}
+	}
+	return ""
+}
+
+func generateSelfSigned(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"ShutterPipe"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certFingerprint returns the SHA-256 fingerprint users can compare with
+// the one their browser shows for a self-signed certificate.
+func certFingerprint(certFile string) string {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return ""
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return ""
+	}
+	sum := sha256.Sum256(block.Bytes)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// redirectHandler sends plain HTTP requests to the HTTPS port.
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := (&url.URL{Host: r.Host}).Hostname()
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	hosts := []string{"localhost", "127.0.0.1", "studio.local"}
+
+	certFile, keyFile, err := ensureSelfSigned(dir, hosts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("certificate doesn't cover %s: %v", host, err)
+		}
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// A second start reuses the certificate so browsers keep trusting it
+	before, _ := os.ReadFile(certFile)
+	if _, _, err := ensureSelfSigned(dir, hosts, nil); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(certFile)
+	if string(before) != string(after) {
+		t.Error("certificate was regenerated although it is still valid")
+	}
+
+	// A changed interface address alone keeps the trusted certificate
+	if _, _, err := ensureSelfSigned(dir, append(hosts, "192.168.1.20"), nil); err != nil {
+		t.Fatal(err)
+	}
+	after, _ = os.ReadFile(certFile)
+	if string(before) != string(after) {
+		t.Error("certificate was regenerated for a detected address")
+	}
+
+	// A host the user adds needs a new certificate
+	if _, _, err := ensureSelfSigned(dir, append(hosts, "nas.studio"), []string{"nas.studio"}); err != nil {
+		t.Fatal(err)
+	}
+	after, _ = os.ReadFile(certFile)
+	if string(before) == string(after) {
+		t.Error("certificate was not regenerated for an added host")
+	}
+}
+
+func TestEnsureSelfSigned_RenewsNearExpiry(t *testing.T) {
+	dir := t.TempDir()
+	hosts := []string{"localhost"}
+
+	certPEM, keyPEM, err := generateSelfSigned(hosts, time.Now().Add(-selfSignedValidity+selfSignedRenewal/2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ensureSelfSigned(dir, hosts, nil); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(certFile)
+	if string(after) == string(certPEM) {
+		t.Error("certificate close to expiry was not regenerated")
+	}
+}
+
+func TestSetTLS(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+		ok   bool
+	}{
+		{"disabled", TLSConfig{}, true},
+		{"files", TLSConfig{CertFile: "c.pem", KeyFile: "k.pem"}, true},
+		{"self-signed", TLSConfig{SelfSigned: true}, true},
+		{"missing key", TLSConfig{CertFile: "c.pem"}, false},
+		{"both modes", TLSConfig{SelfSigned: true, CertFile: "c.pem", KeyFile: "k.pem"}, false},
+		{"redirect without TLS", TLSConfig{RedirectAddr: ":80"}, false},
+		{"self-signed hosts", TLSConfig{SelfSigned: true, Hosts: []string{"nas.studio"}}, true},
+		{"hosts with files", TLSConfig{CertFile: "c.pem", KeyFile: "k.pem", Hosts: []string{"nas.studio"}}, false},
+		{"empty host", TLSConfig{SelfSigned: true, Hosts: []string{""}}, false},
+	}
+	for _, tt := range tests {
+		s := &Server{}
+		if err := s.SetTLS(tt.cfg); (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr, host, want string
+	}{
+		{":8443", "studio.local", "https://studio.local:8443/api/runs?limit=5"},
+		{"0.0.0.0:8443", "192.168.1.20:8080", "https://192.168.1.20:8443/api/runs?limit=5"},
+		{":443", "studio.local:80", "https://studio.local/api/runs?limit=5"},
+		{":443", "[::1]:80", "https://[::1]/api/runs?limit=5"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/runs?limit=5", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		redirectHandler(tt.httpsAddr).ServeHTTP(rec, req)
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("redirect %s via %s = %q, want %q", tt.host, tt.httpsAddr, got, tt.want)
+		}
+	}
+}
@@ -7,13 +7,17 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// upgrader only accepts pages served by this server, so other sites can't
+// open a socket with the user's session cookie.
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin: sameOrigin,
 }
 
 type Hub struct {
+	// snapshot, if set, returns the first message for new clients. It is
+	// taken in the hub's goroutine, so every later broadcast reaches the
+	// client after it.
+	snapshot   func() []byte
 	clients    map[*Client]bool
 	broadcast  chan []byte
 	register   chan *Client
@@ -34,6 +38,11 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.register:
+			if h.snapshot != nil {
+				if data := h.snapshot(); data != nil {
+					client.send <- data
+				}
+			}
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
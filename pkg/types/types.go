@@ -21,6 +21,18 @@ type FileEntry struct {
 	IsVideo bool
 }
 
+// FileGroup links files that belong to one shot, such as RAW+JPG+XMP or a
+// Sony clip and its XML sidecar. Members are dated, planned and
+// conflict-resolved together.
+type FileGroup struct {
+	// Key identifies the group (directory and lowercase base name).
+	Key string
+	// Base is the base filename shared by all members (e.g. "C0001").
+	Base string
+	// Members are the grouped files, most authoritative first.
+	Members []FileEntry
+}
+
 // MediaMetadata contains extracted metadata from a media file.
 type MediaMetadata struct {
 	// CaptureTime is the shooting/creation time extracted from metadata.
@@ -42,6 +54,21 @@ type CopyTask struct {
 	DestDir string
 	// DestPath is the full destination file path.
 	DestPath string
+	// ConflictPath is the existing file the planned path collided with, if any.
+	ConflictPath string
+	// ConflictReason describes how the conflict was resolved.
+	ConflictReason string
+	// Hash is the SHA-256 of the copied content, set when hash verification
+	// is enabled.
+	Hash string
+	// Method is the mechanism that copied the data.
+	Method CopyMethod
+	// ErrorClass classifies Error.
+	ErrorClass ErrorClass
+	// Attempts is how many times the copy was tried.
+	Attempts int
+	// Duration is how long the copy took, including retries.
+	Duration time.Duration
 	// Status indicates the task status.
 	Status TaskStatus
 	// Error contains error message if task failed.
@@ -70,9 +97,34 @@ const (
 	CopyActionRenamed     CopyAction = "renamed"
 	CopyActionOverwritten CopyAction = "overwritten"
 	CopyActionQuarantined CopyAction = "quarantined"
+	CopyActionReview      CopyAction = "review"
 	CopyActionFailed      CopyAction = "failed"
 )
 
+// CopyMethod is the mechanism that copied a file's data.
+type CopyMethod string
+
+const (
+	// CopyMethodReflink shares the source's extents (FICLONE).
+	CopyMethodReflink CopyMethod = "reflink"
+	// CopyMethodCopyFileRange copies inside the kernel.
+	CopyMethodCopyFileRange CopyMethod = "copy_file_range"
+	// CopyMethodBuffered reads and writes through user space.
+	CopyMethodBuffered CopyMethod = "buffered"
+)
+
+// ErrorClass groups copy failures by cause.
+type ErrorClass string
+
+const (
+	ErrorClassSourceRead  ErrorClass = "source_read"
+	ErrorClassDestIO      ErrorClass = "dest_io"
+	ErrorClassNoSpace     ErrorClass = "no_space"
+	ErrorClassPermission  ErrorClass = "permission"
+	ErrorClassPathTooLong ErrorClass = "path_too_long"
+	ErrorClassCancelled   ErrorClass = "cancelled"
+)
+
 // ConflictPolicy defines how to handle filename conflicts.
 type ConflictPolicy string
 
@@ -81,6 +133,36 @@ const (
 	ConflictPolicyRename     ConflictPolicy = "rename"
 	ConflictPolicyOverwrite  ConflictPolicy = "overwrite"
 	ConflictPolicyQuarantine ConflictPolicy = "quarantine"
+	// ConflictPolicyKeepNewer overwrites only if the source is newer (mtime).
+	ConflictPolicyKeepNewer ConflictPolicy = "keep-newer"
+	// ConflictPolicyKeepLarger overwrites only if the source is larger.
+	ConflictPolicyKeepLarger ConflictPolicy = "keep-larger"
+	// ConflictPolicyRenameHash appends a short content hash to the name.
+	ConflictPolicyRenameHash ConflictPolicy = "rename-hash"
+	// ConflictPolicyIdenticalSkipElseRename skips identical content, renames otherwise.
+	ConflictPolicyIdenticalSkipElseRename ConflictPolicy = "identical-skip-else-rename"
+)
+
+// FsyncPolicy defines when copied files are flushed to disk.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs each file before it is renamed into place and its
+	// directory after the rename.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncBatch syncs copied files and their directories in batches, and
+	// always before the state file is saved.
+	FsyncBatch FsyncPolicy = "batch"
+	// FsyncNever leaves flushing to the operating system.
+	FsyncNever FsyncPolicy = "never"
+)
+
+// Copy defaults, used when the configuration leaves them unset.
+const (
+	// DefaultCopyBufferSize is used for buffered copies.
+	DefaultCopyBufferSize = 1 << 20
+	DefaultRetryAttempts  = 3
+	DefaultRetryDelay     = time.Second
 )
 
 // DedupMethod defines how to detect duplicate files.
@@ -89,6 +171,10 @@ type DedupMethod string
 const (
 	DedupMethodNameSize DedupMethod = "name-size"
 	DedupMethodHash     DedupMethod = "hash"
+	// DedupMethodPerceptual flags visually similar images (resized, re-encoded)
+	// and routes them to the review folder instead of skipping them. Files
+	// already at their destination are compared by name and size.
+	DedupMethodPerceptual DedupMethod = "perceptual"
 )
 
 // OrganizeStrategy defines how files are organized into directories.
@@ -101,8 +187,32 @@ const (
 	OrganizeByEvent OrganizeStrategy = "event"
 )
 
+// FileProgress is the file a copy worker is working on.
+type FileProgress struct {
+	// Worker numbers the copy workers from 1.
+	Worker   int    `json:"worker"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Done     int64  `json:"done"`
+	// Throughput is the worker's smoothed rate in bytes per second.
+	Throughput float64 `json:"throughput"`
+}
+
+// TransferProgress is the byte-level progress of the copy phase.
+type TransferProgress struct {
+	// BytesDone counts finished files and the copied part of files in
+	// progress.
+	BytesDone  int64 `json:"bytes_done"`
+	BytesTotal int64 `json:"bytes_total"`
+	// ETA is the estimated time left, zero while unknown.
+	ETA   time.Duration  `json:"eta"`
+	Files []FileProgress `json:"files"`
+}
+
 // RunSummary contains statistics for a completed run.
 type RunSummary struct {
+	// RunID identifies the run in the run history.
+	RunID          string
 	ScannedFiles   int
 	TotalFiles     int
 	Copied         int
@@ -110,6 +220,7 @@ type RunSummary struct {
 	Renamed        int
 	Overwritten    int
 	Quarantined    int
+	Review         int
 	Failed         int
 	Unclassified   int
 	StartTime      time.Time
@@ -117,28 +228,142 @@ type RunSummary struct {
 	Duration       time.Duration
 	BytesCopied    int64
 	BytesPerSecond float64
+	ScanSkipped    map[string]int
+	// CopyMethods counts copied files per copy mechanism.
+	CopyMethods map[CopyMethod]int
+	// FailuresByClass breaks Failed down by error class.
+	FailuresByClass map[ErrorClass]int
+}
+
+// HookFailurePolicy decides what a failing hook does to the run.
+type HookFailurePolicy string
+
+const (
+	// HookFailureContinue logs the failure and carries on (default).
+	HookFailureContinue HookFailurePolicy = "continue"
+	// HookFailureAbort stops the run. For post_run the run has already
+	// finished, so this only reports the error.
+	HookFailureAbort HookFailurePolicy = "abort"
+)
+
+// Hook is one command to execute. Command is run with "sh -c".
+type Hook struct {
+	Command    string            `yaml:"command" json:"command"`
+	TimeoutSec int               `yaml:"timeout_sec,omitempty" json:"timeout_sec,omitempty"`
+	OnFailure  HookFailurePolicy `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+}
+
+// HooksConfig lists the hooks for each event.
+type HooksConfig struct {
+	PreRun   []Hook `yaml:"pre_run,omitempty" json:"pre_run,omitempty"`
+	PostFile []Hook `yaml:"post_file,omitempty" json:"post_file,omitempty"`
+	PostRun  []Hook `yaml:"post_run,omitempty" json:"post_run,omitempty"`
+	OnError  []Hook `yaml:"on_error,omitempty" json:"on_error,omitempty"`
+}
+
+// NotifyWhen selects which runs send notifications.
+type NotifyWhen string
+
+const (
+	NotifyAlways  NotifyWhen = "always"
+	NotifyFailure NotifyWhen = "failure"
+	NotifySuccess NotifyWhen = "success"
+)
+
+// NotifyConfig configures notifications sent when a run completes or fails.
+// Message templates use Go text/template syntax over the notification
+// (e.g. "{{.Status}}: {{.Summary.Copied}} copied").
+type NotifyConfig struct {
+	// When defaults to always. A run with failed files counts as a failure.
+	When     NotifyWhen      `yaml:"when,omitempty" json:"when,omitempty"`
+	Title    string          `yaml:"title,omitempty" json:"title,omitempty"`
+	Message  string          `yaml:"message,omitempty" json:"message,omitempty"`
+	Webhooks []WebhookNotify `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
+	Email    *EmailNotify    `yaml:"email,omitempty" json:"email,omitempty"`
+	Desktop  *DesktopNotify  `yaml:"desktop,omitempty" json:"desktop,omitempty"`
+}
+
+// WebhookNotify POSTs the run summary as JSON. If Secret is set the body is
+// signed with HMAC-SHA256 in the X-ShutterPipe-Signature header.
+type WebhookNotify struct {
+	URL    string `yaml:"url" json:"url"`
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+}
+
+// EmailNotify sends mail through an SMTP server.
+type EmailNotify struct {
+	Host     string   `yaml:"host" json:"host"`
+	Port     int      `yaml:"port,omitempty" json:"port,omitempty"`
+	Username string   `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string   `yaml:"password,omitempty" json:"password,omitempty"`
+	From     string   `yaml:"from" json:"from"`
+	To       []string `yaml:"to" json:"to"`
+}
+
+// DesktopNotify runs a notify-send compatible command with the title and
+// message as its last two arguments. Command is only read from config files;
+// the web API always uses notify-send.
+type DesktopNotify struct {
+	Command string `yaml:"command,omitempty" json:"-"`
+}
+
+// BandwidthConfig limits copy throughput in bytes per second. Zero means
+// unlimited.
+type BandwidthConfig struct {
+	// Limit caps all workers together.
+	Limit int64 `yaml:"limit,omitempty" json:"limit,omitempty"`
+	// PerWorker caps each copy worker.
+	PerWorker int64 `yaml:"per_worker,omitempty" json:"per_worker,omitempty"`
+	// Schedule overrides Limit during the listed times of day.
+	Schedule []BandwidthWindow `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+}
+
+// BandwidthWindow applies Limit from Start to End (local "HH:MM"). A window
+// whose end is before its start runs past midnight.
+type BandwidthWindow struct {
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+	Limit int64  `yaml:"limit" json:"limit"`
 }
 
 // ConfigPreset represents a saved configuration preset.
 type ConfigPreset struct {
-	Name              string           `json:"name"`
-	Description       string           `json:"description,omitempty"`
-	Source            string           `json:"source,omitempty"`
-	Dest              string           `json:"dest,omitempty"`
-	IncludeExtensions []string         `json:"include_extensions"`
-	Jobs              int              `json:"jobs"`
-	DedupMethod       DedupMethod      `json:"dedup_method"`
-	ConflictPolicy    ConflictPolicy   `json:"conflict_policy"`
-	OrganizeStrategy  OrganizeStrategy `json:"organize_strategy"`
-	EventName         string           `json:"event_name,omitempty"`
-	UnclassifiedDir   string           `json:"unclassified_dir"`
-	QuarantineDir     string           `json:"quarantine_dir"`
-	DryRun            bool             `json:"dry_run"`
-	HashVerify        bool             `json:"hash_verify"`
-	IgnoreState       bool             `json:"ignore_state"`
-	DateFilterStart   string           `json:"date_filter_start,omitempty"`
-	DateFilterEnd     string           `json:"date_filter_end,omitempty"`
-	CreatedAt         time.Time        `json:"created_at"`
+	Name                string           `json:"name"`
+	Description         string           `json:"description,omitempty"`
+	Source              string           `json:"source,omitempty"`
+	Dest                string           `json:"dest,omitempty"`
+	IncludeExtensions   []string         `json:"include_extensions"`
+	IncludePatterns     []string         `json:"include_patterns,omitempty"`
+	ExcludePatterns     []string         `json:"exclude_patterns,omitempty"`
+	MinFileSize         int64            `json:"min_file_size,omitempty"`
+	MaxFileSize         int64            `json:"max_file_size,omitempty"`
+	CardMode            bool             `json:"card_mode,omitempty"`
+	Jobs                int              `json:"jobs"`
+	DedupMethod         DedupMethod      `json:"dedup_method"`
+	ConflictPolicy      ConflictPolicy   `json:"conflict_policy"`
+	OrganizeStrategy    OrganizeStrategy `json:"organize_strategy"`
+	EventName           string           `json:"event_name,omitempty"`
+	UnclassifiedDir     string           `json:"unclassified_dir"`
+	QuarantineDir       string           `json:"quarantine_dir"`
+	ReviewDir           string           `json:"review_dir,omitempty"`
+	ReportFormats       []string         `json:"report_formats,omitempty"`
+	Notify              *NotifyConfig    `json:"notify,omitempty"`
+	Bandwidth           *BandwidthConfig `json:"bandwidth,omitempty"`
+	Fsync               FsyncPolicy      `json:"fsync,omitempty"`
+	CopyBufferSize      int              `json:"copy_buffer_size,omitempty"`
+	RetryAttempts       int              `json:"retry_attempts,omitempty"`
+	RetryDelaySec       int              `json:"retry_delay_sec,omitempty"`
+	Preserve            []string         `json:"preserve,omitempty"`
+	SpaceMargin         *int64           `json:"space_margin,omitempty"`
+	SkipPreflight       bool             `json:"skip_preflight,omitempty"`
+	PerceptualThreshold *int             `json:"perceptual_threshold,omitempty"`
+	DryRun              bool             `json:"dry_run"`
+	HashVerify          bool             `json:"hash_verify"`
+	UndoRetentionDays   int              `json:"undo_retention_days,omitempty"`
+	IgnoreState         bool             `json:"ignore_state"`
+	DateFilterStart     string           `json:"date_filter_start,omitempty"`
+	DateFilterEnd       string           `json:"date_filter_end,omitempty"`
+	CreatedAt           time.Time        `json:"created_at"`
 }
 
 // UserSettings represents the current user settings (migrated from localStorage).
@@ -0,0 +1,124 @@
+// Auth Module
+// 로그인, 세션, CSRF 토큰 및 읽기 전용 모드
+
+let authState = {
+    enabled: false,
+    role: 'admin',
+    csrfToken: ''
+};
+
+// 모든 쓰기 요청에 CSRF 토큰을 붙이고, 세션이 만료되면 로그인 화면 표시
+const originalFetch = window.fetch.bind(window);
+window.fetch = async (input, init = {}) => {
+    const method = (init.method || 'GET').toUpperCase();
+    if (authState.csrfToken && !['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+        init.headers = new Headers(init.headers || {});
+        init.headers.set('X-CSRF-Token', authState.csrfToken);
+    }
+
+    const response = await originalFetch(input, init);
+    const url = typeof input === 'string' ? input : input.url;
+    if (response.status === 401 && authState.enabled && !url.startsWith('/api/auth/')) {
+        showLoginOverlay();
+    }
+    return response;
+};
+
+// 인증 상태 확인
+async function loadAuthStatus() {
+    try {
+        const response = await originalFetch('/api/auth/status');
+        if (!response.ok) {
+            return;
+        }
+        applyAuthStatus(await response.json());
+    } catch (error) {
+        console.error('인증 상태 확인 실패:', error);
+    }
+}
+
+function applyAuthStatus(status) {
+    authState.enabled = status.enabled;
+    authState.role = status.role || 'admin';
+    authState.csrfToken = status.csrf_token || '';
+
+    if (status.enabled && !status.authenticated) {
+        showLoginOverlay();
+        return;
+    }
+
+    document.getElementById('logoutBtn').style.display = status.enabled ? 'block' : 'none';
+    if (authState.role === 'viewer') {
+        document.body.classList.add('read-only');
+        watchProgress();
+    } else {
+        resumeRun();
+    }
+}
+
+function showLoginOverlay() {
+    document.getElementById('loginOverlay').style.display = 'flex';
+    document.getElementById('loginPassword').focus();
+}
+
+// 로그인
+async function login(event) {
+    event.preventDefault();
+    const password = document.getElementById('loginPassword').value;
+    const errorEl = document.getElementById('loginError');
+    errorEl.textContent = '';
+
+    const response = await originalFetch('/api/auth/login', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ password })
+    });
+
+    if (response.status === 429) {
+        errorEl.textContent = '로그인 실패가 너무 많습니다. 잠시 후 다시 시도하세요.';
+        return;
+    }
+    if (!response.ok) {
+        errorEl.textContent = '비밀번호가 올바르지 않습니다.';
+        return;
+    }
+
+    // 설정과 프리셋을 새 세션으로 다시 불러오기
+    window.location.reload();
+}
+
+// 로그아웃
+async function logout() {
+    await fetch('/api/auth/logout', { method: 'POST' });
+    window.location.reload();
+}
+
+// 읽기 전용 사용자는 실행 중인 백업의 진행 상황만 관찰
+function watchProgress() {
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const socket = new WebSocket(`${protocol}//${window.location.host}/api/ws`);
+
+    socket.onopen = () => {
+        addLogEntry('읽기 전용 모드: 진행 상황을 관찰합니다', 'info');
+    };
+
+    socket.onmessage = (event) => {
+        const update = JSON.parse(event.data);
+        if (update.type === 'snapshot') {
+            applySnapshot(update.state, true);
+            return;
+        }
+        if (update.type === 'run_started') {
+            document.getElementById('fileList').innerHTML = '';
+            document.getElementById('summarySection').style.display = 'none';
+        }
+        document.getElementById('progressSection').style.display = 'block';
+        handleProgressUpdate(update);
+    };
+
+    socket.onclose = () => {
+        setTimeout(watchProgress, 3000);
+    };
+}
+
+window.addEventListener('DOMContentLoaded', loadAuthStatus);
@@ -52,9 +52,10 @@ async function startBackup() {
         jobs: parseInt(document.getElementById('jobs').value) || 0,
         unclassified_dir: document.getElementById('unclassifiedDir').value || 'unclassified',
         quarantine_dir: document.getElementById('quarantineDir').value || 'quarantine',
-        state_file: document.getElementById('stateFile').value,
-        log_file: document.getElementById('logFile').value,
-        log_json: document.getElementById('logJson').checked
+        log_json: document.getElementById('logJson').checked,
+
+        // 불러온 프리셋의 알림 설정 사용 (비밀번호는 서버에만 저장됨)
+        preset: typeof selectedPreset !== 'undefined' && selectedPreset ? selectedPreset : undefined
     };
 
     try {
@@ -157,13 +158,83 @@ function connectWebSocket() {
     });
 }
 
+// 마지막으로 반영한 서버 이벤트 번호
+let lastSeq = 0;
+
+// 새로고침 후 실행 중인 백업에 다시 연결
+async function resumeRun() {
+    try {
+        const response = await fetch('/api/run/status');
+        if (!response.ok) {
+            return;
+        }
+        const state = await response.json();
+        if (state.running) {
+            await connectWebSocket();
+        } else {
+            applySnapshot(state, true);
+        }
+    } catch (error) {
+        console.error('실행 상태 확인 실패:', error);
+    }
+}
+
+// 서버의 실행 상태 스냅샷 반영 (새로고침하거나 늦게 접속한 경우)
+function applySnapshot(state, showFinished = false) {
+    lastSeq = state.seq;
+    if (!state.running) {
+        if (showFinished && state.summary) {
+            showSummary(state.summary);
+        }
+        return;
+    }
+
+    isRunning = true;
+    document.getElementById('startBtn').disabled = true;
+    document.getElementById('progressSection').style.display = 'block';
+    document.getElementById('summarySection').style.display = 'none';
+    document.getElementById('fileList').innerHTML = '';
+    addLogEntry('진행 중인 백업에 연결했습니다.', 'info');
+
+    // 최근 상태 메시지와 파일 목록 복원
+    state.events.forEach(handleProgressUpdate);
+
+    const progressBar = document.getElementById('progressBar');
+    if (state.total > 0) {
+        const percent = Math.round((state.current / state.total) * 100);
+        progressBar.classList.remove('pulse');
+        progressBar.style.width = percent + '%';
+        document.getElementById('progressPercent').textContent = percent + '%';
+        document.getElementById('progressText').textContent = `${state.message || '복사 중'} (${state.current}/${state.total})`;
+    }
+    if (state.transfer) {
+        updateTransfer(state.transfer);
+    }
+    updateBandwidth(state);
+}
+
 // 진행 상황 업데이트 처리
 function handleProgressUpdate(update) {
     const progressBar = document.getElementById('progressBar');
     const progressPercent = document.getElementById('progressPercent');
     const progressText = document.getElementById('progressText');
 
-    if (update.type === 'status') {
+    if (update.type === 'snapshot') {
+        applySnapshot(update.state);
+        return;
+    }
+    // 스냅샷에 이미 포함된 이벤트는 무시
+    if (update.seq !== undefined) {
+        if (update.seq <= lastSeq) {
+            return;
+        }
+        lastSeq = update.seq;
+    }
+
+    if (update.type === 'run_started') {
+        resetTransfer();
+
+    } else if (update.type === 'status') {
         progressText.textContent = update.message;
         progressBar.style.width = '100%';
         progressBar.classList.add('pulse');
@@ -182,21 +253,34 @@ function handleProgressUpdate(update) {
         }
 
     } else if (update.type === 'progress') {
-        const percent = Math.round((update.current / update.total) * 100);
-        progressBar.classList.remove('pulse');
-        progressBar.style.width = percent + '%';
-        progressPercent.textContent = percent + '%';
+        // 바이트 진행률을 받은 뒤에는 파일 수 대신 바이트 기준으로 표시
+        if (!transferKnown) {
+            const percent = Math.round((update.current / update.total) * 100);
+            progressBar.classList.remove('pulse');
+            progressBar.style.width = percent + '%';
+            progressPercent.textContent = percent + '%';
+        }
         progressText.textContent = `복사 중: ${update.filename} (${update.current}/${update.total})`;
 
         addFileToList(update.filename, update.action);
+        updateBandwidth(update);
 
         // 에러나 특수 동작 로그
         if (update.action === 'failed') {
             addLogEntry(`실패: ${update.filename} - ${update.error || 'Unknown error'}`, 'error');
         } else if (update.action === 'quarantined') {
             addLogEntry(`격리됨: ${update.filename}`, 'warning');
+        } else if (update.action === 'review') {
+            addLogEntry(`유사 이미지 검토 필요: ${update.filename}`, 'warning');
         }
 
+    } else if (update.type === 'transfer') {
+        updateTransfer(update.transfer);
+        updateBandwidth(update);
+
+    } else if (update.type === 'bandwidth') {
+        updateBandwidth(update);
+
     } else if (update.type === 'complete') {
         isRunning = false;
         document.getElementById('startBtn').disabled = false;
@@ -204,6 +288,7 @@ function handleProgressUpdate(update) {
         progressBar.style.width = '100%';
         progressPercent.textContent = '100%';
         progressText.textContent = '완료!';
+        resetTransfer();
 
         addLogEntry('백업 작업이 완료되었습니다.', 'success');
         showSummary(update.summary);
@@ -216,6 +301,7 @@ function handleProgressUpdate(update) {
         isRunning = false;
         document.getElementById('startBtn').disabled = false;
         progressBar.classList.remove('pulse');
+        resetTransfer();
         alert('오류: ' + update.error);
         addLogEntry('오류 발생: ' + update.error, 'error');
 
@@ -226,6 +312,78 @@ function handleProgressUpdate(update) {
     }
 }
 
+// 바이트 단위 진행률을 받았는지 여부
+let transferKnown = false;
+
+// 복사한 바이트, 남은 시간과 워커별 진행 중인 파일 표시
+function updateTransfer(transfer) {
+    transferKnown = true;
+
+    if (transfer.bytes_total > 0) {
+        const percent = Math.floor((transfer.bytes_done / transfer.bytes_total) * 100);
+        const progressBar = document.getElementById('progressBar');
+        progressBar.classList.remove('pulse');
+        progressBar.style.width = percent + '%';
+        document.getElementById('progressPercent').textContent = percent + '%';
+    }
+
+    let text = `${formatBytes(transfer.bytes_done)} / ${formatBytes(transfer.bytes_total)}`;
+    if (transfer.eta > 0) {
+        text += ` · 남은 시간 약 ${formatDuration(Math.round(transfer.eta / 1e9))}`;
+    }
+    document.getElementById('transferText').textContent = text;
+
+    const workerList = document.getElementById('workerList');
+    workerList.innerHTML = '';
+    (transfer.files || []).forEach(file => {
+        const percent = file.size > 0 ? Math.floor((file.done / file.size) * 100) : 100;
+        const item = document.createElement('div');
+        item.className = 'worker-item';
+        item.innerHTML = `
+            <span class="worker-name"></span>
+            <div class="worker-bar"><div style="width: ${percent}%"></div></div>
+            <span class="worker-speed">${formatSpeed(file.throughput || 0)}</span>
+        `;
+        item.querySelector('.worker-name').textContent = `#${file.worker} ${file.filename} (${percent}%)`;
+        workerList.appendChild(item);
+    });
+}
+
+function resetTransfer() {
+    transferKnown = false;
+    document.getElementById('transferText').textContent = '';
+    document.getElementById('workerList').innerHTML = '';
+}
+
+let currentPerWorkerLimit = 0;
+
+// 현재 속도와 대역폭 제한 표시
+function updateBandwidth(update) {
+    currentPerWorkerLimit = update.per_worker || 0;
+    document.getElementById('throughputText').textContent = formatSpeed(update.throughput || 0);
+    document.getElementById('bandwidthLimitText').textContent = update.limit ? formatSpeed(update.limit) : '없음';
+}
+
+// 실행 중인 백업의 대역폭 제한 변경
+async function applyBandwidthLimit() {
+    const mbps = parseFloat(document.getElementById('bandwidthLimitInput').value) || 0;
+    try {
+        const response = await fetch('/api/run/bandwidth', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ limit: Math.round(mbps * 1024 * 1024), per_worker: currentPerWorkerLimit })
+        });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        const status = await response.json();
+        updateBandwidth(status);
+        addLogEntry(`대역폭 제한 변경: ${status.limit ? formatSpeed(status.limit) : '무제한'}`, 'info');
+    } catch (error) {
+        alert('대역폭 제한 변경 실패: ' + error.message);
+    }
+}
+
 // 파일 목록에 추가
 function addFileToList(filename, action) {
     const fileList = document.getElementById('fileList');
@@ -240,6 +398,7 @@ function addFileToList(filename, action) {
         'renamed': '[이름변경]',
         'overwritten': '[덮어쓰기]',
         'quarantined': '[격리]',
+        'review': '[검토]',
         'failed': '[실패]'
     };
 
@@ -303,6 +462,21 @@ function formatDuration(seconds) {
 }
 
 // 요약 표시
+const copyMethodLabels = {
+    'reflink': 'reflink 복제',
+    'copy_file_range': '커널 복사',
+    'buffered': '버퍼 복사'
+};
+
+const errorClassLabels = {
+    'source_read': '원본 읽기 오류',
+    'dest_io': '대상 입출력 오류',
+    'no_space': '공간 부족',
+    'permission': '권한 없음',
+    'path_too_long': '경로가 너무 김',
+    'cancelled': '취소됨'
+};
+
 function showSummary(summary) {
     const summarySection = document.getElementById('summarySection');
     const summaryContent = document.getElementById('summaryContent');
@@ -355,6 +529,18 @@ function showSummary(summary) {
             </div>
         </div>
 
+        ${Object.keys(summary.FailuresByClass || {}).length ? `
+        <div class="summary-section">
+            <h3 class="summary-section-title">실패 원인</h3>
+            <div class="summary-grid">
+                ${Object.entries(summary.FailuresByClass).map(([cls, count]) => `
+                <div class="summary-item" data-type="error">
+                    <div class="summary-label">${errorClassLabels[cls] || cls}</div>
+                    <div class="summary-value">${count}</div>
+                </div>`).join('')}
+            </div>
+        </div>` : ''}
+
         <div class="summary-section">
             <h3 class="summary-section-title">성능</h3>
             <div class="summary-grid">
@@ -370,8 +556,22 @@ function showSummary(summary) {
                     <div class="summary-label">속도</div>
                     <div class="summary-value">${speed}</div>
                 </div>
+                ${Object.entries(summary.CopyMethods || {}).map(([method, count]) => `
+                <div class="summary-item" data-type="neutral">
+                    <div class="summary-label">${copyMethodLabels[method] || method}</div>
+                    <div class="summary-value">${count}</div>
+                </div>`).join('')}
             </div>
         </div>
+        ${summary.RunID ? `
+        <div class="summary-section">
+            <h3 class="summary-section-title">보고서</h3>
+            <div class="summary-grid">
+                <a class="summary-item" data-type="info" href="/api/runs/${summary.RunID}/report?format=html" target="_blank">HTML</a>
+                <a class="summary-item" data-type="info" href="/api/runs/${summary.RunID}/report?format=csv">CSV</a>
+                <a class="summary-item" data-type="info" href="/api/runs/${summary.RunID}/report?format=json">JSON</a>
+            </div>
+        </div>` : ''}
     `;
 
     summarySection.style.display = 'block';
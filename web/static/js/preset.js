@@ -2,6 +2,7 @@
 // 설정 프리셋 관리 기능
 
 let currentPresets = [];
+// 마지막으로 불러오거나 저장한 프리셋 (실행 시 이 프리셋의 알림 설정 사용)
 let selectedPreset = null;
 
 // 사이드바 토글
@@ -56,6 +57,8 @@ function renderPresetList() {
         // 중복 검사 방식
         if (preset.dedup_method === 'hash') {
             tags.push('해시 검사');
+        } else if (preset.dedup_method === 'perceptual') {
+            tags.push('유사 이미지');
         } else {
             tags.push('이름+크기');
         }
@@ -136,6 +139,8 @@ async function loadPresetByName(presetName) {
             updateBookmarkButtons();
         }
 
+        selectedPreset = presetName;
+
         // 사이드바 닫기
         togglePresetSidebar();
 
@@ -210,6 +215,7 @@ async function savePreset() {
             throw new Error('Failed to save preset');
         }
 
+        selectedPreset = name;
         showNotification(`프리셋 "${name}"을 저장했습니다`, 'success');
         hideSavePresetDialog();
         loadPresetList(); // 목록 새로고침
@@ -234,6 +240,9 @@ async function deletePresetByName(presetName) {
             throw new Error('Failed to delete preset');
         }
 
+        if (selectedPreset === presetName) {
+            selectedPreset = null;
+        }
         showNotification(`프리셋 "${presetName}"을 삭제했습니다`, 'success');
         loadPresetList(); // 목록 새로고침
     } catch (error) {
@@ -25,8 +25,6 @@ async function loadSettings() {
         document.getElementById('jobs').value = config.jobs || 0;
         document.getElementById('unclassifiedDir').value = config.unclassified_dir || 'unclassified';
         document.getElementById('quarantineDir').value = config.quarantine_dir || 'quarantine';
-        document.getElementById('stateFile').value = config.state_file || '';
-        document.getElementById('logFile').value = config.log_file || '';
         document.getElementById('logJson').checked = config.log_json || false;
 
         // 확장자 목록 로드
@@ -101,8 +99,6 @@ async function saveSettings() {
         include_extensions: includeExtensions,
         unclassified_dir: document.getElementById('unclassifiedDir').value || 'unclassified',
         quarantine_dir: document.getElementById('quarantineDir').value || 'quarantine',
-        state_file: document.getElementById('stateFile').value,
-        log_file: document.getElementById('logFile').value,
         log_json: document.getElementById('logJson').checked
     };
     await saveSettingsToServer(config);
@@ -0,0 +1,10 @@
+// Package static holds the web UI assets so they are compiled into the
+// server binary.
+package static
+
+import "embed"
+
+// FS contains index.html and the css and js directories.
+//
+//go:embed index.html css js
+var FS embed.FS